@@ -0,0 +1,15 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// dbCmd groups the commands administrating the database
+var dbCmd = &cobra.Command{
+	Use:   "db",
+	Short: "Administrate the database",
+}
+
+func init() {
+	rootCmd.AddCommand(dbCmd)
+}
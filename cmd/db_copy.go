@@ -0,0 +1,171 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+
+	"github.com/adeo/turbine-go-api-skeleton/storage/dao"
+	"github.com/adeo/turbine-go-api-skeleton/storage/dao/migrate"
+	"github.com/adeo/turbine-go-api-skeleton/utils"
+	"github.com/spf13/cobra"
+)
+
+const (
+	parameterCopyFrom       = "from"
+	parameterCopyTo         = "to"
+	parameterCopyBatchSize  = "batch-size"
+	parameterCopyCheckpoint = "checkpoint"
+	parameterCopyConflict   = "conflict"
+	parameterCopyVerify     = "verify"
+	parameterCopyTenants    = "tenants"
+)
+
+var errCopyVerification = errors.New("the databases differ after the copy")
+
+var copyOptions struct {
+	from       string
+	to         string
+	batchSize  int
+	checkpoint string
+	conflict   string
+	verify     bool
+	tenants    []string
+}
+
+var dbCopyCmd = &cobra.Command{
+	Use:   "copy",
+	Short: "Copy all the entities from a database to another, keeping their ids",
+	Long: `Copy all the entities from a database to another, keeping their ids.
+The databases are given by their connection uri: postgresql://..., mongodb://.../<db name>,
+or memory://<file> for the file of an in memory database.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		utils.InitLogger(config.LogLevel, config.LogFormat)
+
+		conflict := migrate.ConflictStrategy(copyOptions.conflict)
+		switch conflict {
+		case migrate.ConflictFail, migrate.ConflictSkip, migrate.ConflictOverwrite:
+		default:
+			return fmt.Errorf("unknown --%s %q", parameterCopyConflict, copyOptions.conflict)
+		}
+		if copyOptions.batchSize <= 0 {
+			return fmt.Errorf("--%s must be positive", parameterCopyBatchSize)
+		}
+
+		opts := migrate.Options{BatchSize: copyOptions.batchSize, Conflict: conflict}
+		if copyOptions.checkpoint != "" {
+			checkpoint, err := migrate.LoadCheckpoint(copyOptions.checkpoint)
+			if err != nil {
+				return err
+			}
+			opts.Checkpoint = checkpoint
+		}
+
+		src, err := openDatabase(copyOptions.from)
+		if err != nil {
+			return err
+		}
+		defer closeDatabase(src)
+		dst, err := openDatabase(copyOptions.to)
+		if err != nil {
+			return err
+		}
+		defer closeDatabase(dst)
+
+		tenants := copyOptions.tenants
+		if len(tenants) == 0 {
+			tenants = []string{""}
+		}
+		differ := false
+		for _, tenant := range tenants {
+			ctx := dao.WithTenant(context.Background(), tenant)
+
+			reports, err := migrate.Copy(ctx, src, dst, opts)
+			for _, report := range reports {
+				fmt.Printf("%s%s: %d copied, %d skipped, %d overwritten, %d unchanged\n",
+					tenantPrefix(tenant), report.Entity, report.Copied, report.Skipped, report.Overwritten, report.Unchanged)
+			}
+			if err != nil {
+				return err
+			}
+
+			if !copyOptions.verify {
+				continue
+			}
+			verifications, err := migrate.Verify(ctx, src, dst, copyOptions.batchSize)
+			if err != nil {
+				return err
+			}
+			for _, v := range verifications {
+				status := "ok"
+				if !v.OK() {
+					status = "DIFFERENT"
+					differ = true
+				}
+				fmt.Printf("%s%s: %s, %d/%d entities, checksums %s/%s\n",
+					tenantPrefix(tenant), v.Entity, status, v.SourceCount, v.DestinationCount, v.SourceChecksum, v.DestinationChecksum)
+			}
+		}
+		if differ {
+			return errCopyVerification
+		}
+		return nil
+	},
+}
+
+func tenantPrefix(tenant string) string {
+	if tenant == "" {
+		return ""
+	}
+	return tenant + "/"
+}
+
+// openDatabase opens the database without its decorators, with the driver registered for the scheme of its uri
+func openDatabase(uri string) (dao.Database, error) {
+	// the in memory databases are opened without import file nor periodic snapshots
+	settings := []interface{}{
+		config.DBPool,      // DAO PG
+		config.DBMongoPool, // DAO MONGO
+	}
+	return dao.Open(dao.Options{
+		ConnectionURI: uri,
+		Name:          databaseNameFromURI(uri),
+		Tenancy:       config.Tenancy,
+		Settings:      append(settings, config.DBDriverSettings...),
+	})
+}
+
+// databaseNameFromURI returns the database name given in the path of the uri, or the configured one
+func databaseNameFromURI(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || strings.Trim(u.Path, "/") == "" {
+		return config.DBName
+	}
+	return strings.Trim(u.Path, "/")
+}
+
+// closeDatabase releases the database, saving it for the in memory one
+func closeDatabase(db dao.Database) {
+	if closer, ok := db.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			utils.GetLogger().WithError(err).Error("error while closing database")
+		}
+	}
+}
+
+func init() {
+	dbCopyCmd.Flags().StringVar(&copyOptions.from, parameterCopyFrom, "", "Use this flag to set the connection uri of the database to copy")
+	dbCopyCmd.Flags().StringVar(&copyOptions.to, parameterCopyTo, "", "Use this flag to set the connection uri of the database to copy to")
+	dbCopyCmd.Flags().IntVar(&copyOptions.batchSize, parameterCopyBatchSize, 500, "Use this flag to set the number of entities read at once")
+	dbCopyCmd.Flags().StringVar(&copyOptions.checkpoint, parameterCopyCheckpoint, "", "Use this flag to record the progress of the copy in this file, and to resume it from this file")
+	dbCopyCmd.Flags().StringVar(&copyOptions.conflict, parameterCopyConflict, string(migrate.ConflictFail), "Use this flag to set what to do when an entity already exists: fail, skip or overwrite")
+	dbCopyCmd.Flags().BoolVar(&copyOptions.verify, parameterCopyVerify, true, "Use this flag to compare the counts and checksums of the entities of both databases after the copy")
+	dbCopyCmd.Flags().StringSliceVar(&copyOptions.tenants, parameterCopyTenants, nil, "Use this flag to set the tenants to copy, when the multi-tenancy is enabled")
+	_ = dbCopyCmd.MarkFlagRequired(parameterCopyFrom)
+	_ = dbCopyCmd.MarkFlagRequired(parameterCopyTo)
+
+	dbCmd.AddCommand(dbCopyCmd)
+}
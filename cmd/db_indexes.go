@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/adeo/turbine-go-api-skeleton/storage/dao"
+	"github.com/adeo/turbine-go-api-skeleton/storage/dao/mongodb"
+	"github.com/adeo/turbine-go-api-skeleton/utils"
+	"github.com/spf13/cobra"
+)
+
+const (
+	indexesTimeout = 5 * time.Minute
+
+	parameterIndexesTenant = "tenant"
+)
+
+var indexesTenant string
+
+var errIndexesDrift = errors.New("the indexes differ from their declaration")
+
+var dbIndexesCmd = &cobra.Command{
+	Use:   "indexes",
+	Short: "Manage the MongoDB indexes declared by the entities",
+}
+
+var dbIndexesDiffCmd = &cobra.Command{
+	Use:   "diff",
+	Short: "Print the missing, different and extra indexes, exit with an error if any",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		drifts, err := runIndexes(func(ctx context.Context, db *mongodb.DatabaseMongoDB) ([]mongodb.IndexDrift, error) {
+			return db.DiffIndexes(ctx)
+		})
+		if err != nil {
+			return err
+		}
+		printIndexDrifts(drifts, "missing", "to drop")
+		for _, drift := range drifts {
+			if !drift.Empty() {
+				return errIndexesDrift
+			}
+		}
+		return nil
+	},
+}
+
+var dbIndexesSyncCmd = &cobra.Command{
+	Use:   "sync",
+	Short: "Create the missing indexes and drop the ones they replace, print the different and extra ones without dropping them",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		drifts, err := runIndexes(func(ctx context.Context, db *mongodb.DatabaseMongoDB) ([]mongodb.IndexDrift, error) {
+			return db.SyncIndexes(ctx)
+		})
+		if err != nil {
+			return err
+		}
+		printIndexDrifts(drifts, "created", "dropped")
+		return nil
+	},
+}
+
+func runIndexes(run func(ctx context.Context, db *mongodb.DatabaseMongoDB) ([]mongodb.IndexDrift, error)) ([]mongodb.IndexDrift, error) {
+	utils.InitLogger(config.LogLevel, config.LogFormat)
+	if !strings.HasPrefix(config.DBConnectionURI, "mongodb") {
+		return nil, fmt.Errorf("--%s must be a mongodb connection uri", parameterDBConnectionURI)
+	}
+
+	db, err := mongodb.ConnectMongoDB(config.DBConnectionURI, config.DBName, config.DBMongoPool, config.Tenancy)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), indexesTimeout)
+	defer cancel()
+	if indexesTenant != "" {
+		ctx = dao.WithTenant(ctx, indexesTenant)
+	}
+	return run(ctx, db)
+}
+
+// printIndexDrifts prints the drift of each collection, the missing indexes being prefixed with missingLabel
+// and the replaced ones with replacedLabel
+func printIndexDrifts(drifts []mongodb.IndexDrift, missingLabel, replacedLabel string) {
+	for _, drift := range drifts {
+		if drift.Empty() {
+			fmt.Printf("%s: up to date\n", drift.Collection)
+			continue
+		}
+		for _, index := range drift.Missing {
+			fmt.Printf("%s: %s %s\n", drift.Collection, missingLabel, index)
+		}
+		for _, change := range drift.Different {
+			fmt.Printf("%s: different %s, existing %s\n", drift.Collection, change.Declared, change.Existing)
+		}
+		for _, index := range drift.Extra {
+			fmt.Printf("%s: extra %s\n", drift.Collection, index)
+		}
+		for _, index := range drift.Replaced {
+			fmt.Printf("%s: %s replaced %s\n", drift.Collection, replacedLabel, index)
+		}
+	}
+}
+
+func init() {
+	dbIndexesCmd.PersistentFlags().StringVar(&indexesTenant, parameterIndexesTenant, "", "Use this flag to manage the indexes of the database of this tenant, with the isolated tenancy")
+
+	dbIndexesCmd.AddCommand(dbIndexesDiffCmd, dbIndexesSyncCmd)
+	dbCmd.AddCommand(dbIndexesCmd)
+}
@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/adeo/turbine-go-api-skeleton/storage/dao"
+	"github.com/adeo/turbine-go-api-skeleton/storage/dao/encryption"
+	"github.com/adeo/turbine-go-api-skeleton/utils"
+	"github.com/spf13/cobra"
+)
+
+const (
+	parameterReencryptBatchSize = "batch-size"
+	parameterReencryptTenants   = "tenants"
+)
+
+var reencryptOptions struct {
+	batchSize int
+	tenants   []string
+}
+
+var dbReencryptCmd = &cobra.Command{
+	Use:   "reencrypt",
+	Short: "Encrypt with the current key the encrypted fields of all the entities",
+	Long: `Encrypt with the current key the encrypted fields of all the entities of the db connection uri
+and of the datasources, which are encrypted with a previous key or not encrypted yet.
+Run it after a key rotation, before removing the previous key from the --` + parameterEncryptionKeys + `.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		utils.InitLogger(config.LogLevel, config.LogFormat)
+
+		if len(config.EncryptionKeys) == 0 {
+			return fmt.Errorf("no --%s given", parameterEncryptionKeys)
+		}
+		if config.DBConnectionURI == "" {
+			return errors.New("no db connection uri given")
+		}
+		if reencryptOptions.batchSize <= 0 {
+			return fmt.Errorf("--%s must be positive", parameterReencryptBatchSize)
+		}
+		keyring, err := encryption.NewKeyring(config.EncryptionKeys, config.EncryptionKeyID)
+		if err != nil {
+			return err
+		}
+
+		uris := map[string]string{"": config.DBConnectionURI}
+		names := []string{""}
+		for name, uri := range config.DBDatasources {
+			uris[name] = uri
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		tenants := reencryptOptions.tenants
+		if len(tenants) == 0 {
+			tenants = []string{""}
+		}
+		for _, name := range names {
+			if err := reencrypt(uris[name], tenantPrefix(name), keyring, tenants); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
+
+// reencrypt re-encrypts the entities of each tenant of the database of the given uri, the reports being
+// printed with the given prefix
+func reencrypt(uri, prefix string, keyring *encryption.Keyring, tenants []string) error {
+	db, err := openDatabase(uri)
+	if err != nil {
+		return err
+	}
+	defer closeDatabase(db)
+
+	encrypted := encryption.NewDatabaseEncryption(db, keyring)
+	for _, tenant := range tenants {
+		ctx := dao.WithTenant(context.Background(), tenant)
+		reports, err := encryption.Reencrypt(ctx, encrypted, reencryptOptions.batchSize)
+		for _, report := range reports {
+			fmt.Printf("%s%s%s: %d scanned, %d re-encrypted\n",
+				prefix, tenantPrefix(tenant), report.Entity, report.Scanned, report.Reencrypted)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func init() {
+	dbReencryptCmd.Flags().IntVar(&reencryptOptions.batchSize, parameterReencryptBatchSize, 500, "Use this flag to set the number of entities read at once")
+	dbReencryptCmd.Flags().StringSliceVar(&reencryptOptions.tenants, parameterReencryptTenants, nil, "Use this flag to set the tenants to re-encrypt, when the multi-tenancy is enabled")
+
+	dbCmd.AddCommand(dbReencryptCmd)
+}
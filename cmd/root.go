@@ -1,15 +1,23 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
+	"net/http"
 	"os"
+	"os/signal"
 	"strings"
+	"syscall"
+	"time"
 
 	cfg "github.com/adeo/turbine-go-api-skeleton/config"
 	"github.com/adeo/turbine-go-api-skeleton/handlers"
+	"github.com/adeo/turbine-go-api-skeleton/middlewares"
+	"github.com/adeo/turbine-go-api-skeleton/storage/dao"
 	"github.com/adeo/turbine-go-api-skeleton/utils"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 )
 
@@ -19,28 +27,94 @@ var (
 )
 
 const (
-	parameterConfigurationFile         = "config"
-	parameterLogLevel                  = "log-level"
-	parameterLogFormat                 = "log-format"
-	parameterDBConnectionURI           = "db-connection-uri"
-	parameterDBInMemory                = "db-in-memory"             // DAO IN MEMORY
-	parameterDBInMemoryImportFile      = "db-in-memory-import-file" // DAO IN MEMORY
-	parameterDBName                    = "db-name"
-	parameterPortAPI                   = "port-api"
-	parameterPortMonitoring            = "port-monitoring"
-	parameterAuthenticationServiceFake = "authentication-service-fake"
-	parameterAuthenticationServiceURI  = "authentication-service-uri"
-	parameterInsecure                  = "insecure"
+	shutdownTimeout = 10 * time.Second
+)
+
+const (
+	parameterConfigurationFile          = "config"
+	parameterLogLevel                   = "log-level"
+	parameterLogFormat                  = "log-format"
+	parameterDBConnectionURI            = "db-connection-uri"
+	parameterDBReadConnectionURI        = "db-read-connection-uri"
+	parameterDBInMemory                 = "db-in-memory"                   // DAO IN MEMORY
+	parameterDBInMemoryImportFile       = "db-in-memory-import-file"       // DAO IN MEMORY
+	parameterDBInMemorySnapshotFile     = "db-in-memory-snapshot-file"     // DAO IN MEMORY
+	parameterDBInMemorySnapshotInterval = "db-in-memory-snapshot-interval" // DAO IN MEMORY
+	parameterDBName                     = "db-name"
+	parameterDBDatasources              = "db-datasources"
+	parameterDBEntityDatasources        = "db-entity-datasources"
+	parameterDBSecondaryConnectionURI   = "db-secondary-connection-uri"
+	parameterDBSecondaryName            = "db-secondary-name"
+	parameterDBShadowRead               = "db-shadow-read"
+	parameterDBPurgeInterval            = "db-purge-interval"
+	parameterEntityTTL                  = "entity-ttl"
+	parameterDBMaxOpenConnections       = "db-max-open-connections"           // DAO PG
+	parameterDBMaxIdleConnections       = "db-max-idle-connections"           // DAO PG
+	parameterDBConnectionMaxLifetime    = "db-connection-max-lifetime"        // DAO PG
+	parameterDBMongoMaxPoolSize         = "db-mongo-max-pool-size"            // DAO MONGO
+	parameterDBMongoConnectTimeout      = "db-mongo-connect-timeout"          // DAO MONGO
+	parameterDBMongoServerSelection     = "db-mongo-server-selection-timeout" // DAO MONGO
+	parameterDBMongoQueryTimeout        = "db-mongo-query-timeout"            // DAO MONGO
+	parameterDBChaos                    = "db-chaos"
+	parameterDBRetryMaxAttempts         = "db-retry-max-attempts"
+	parameterDBRetryInitialBackoff      = "db-retry-initial-backoff"
+	parameterDBRetryMaxBackoff          = "db-retry-max-backoff"
+	parameterDBBreakerThreshold         = "db-circuit-breaker-threshold"
+	parameterDBBreakerOpenDuration      = "db-circuit-breaker-open-duration"
+	parameterEncryptionKeys             = "encryption-keys"
+	parameterEncryptionKeyID            = "encryption-key-id"
+	parameterTenancy                    = "tenancy"
+	parameterTenantHeader               = "tenant-header"
+	parameterTenantClaim                = "tenant-claim"
+	parameterProblemDetails             = "problem-details"
+	parameterProblemTypeBaseURI         = "problem-type-base-uri"
+	parameterCompressionMinSize         = "compression-min-size"
+	parameterCompressionContentTypes    = "compression-content-types"
+	parameterMaxRequestBodySize         = "max-request-body-size"
+	parameterTranslationsDir            = "translations-dir"
+	parameterDefaultLanguage            = "default-language"
+	parameterPortAPI                    = "port-api"
+	parameterPortMonitoring             = "port-monitoring"
+	parameterAuthenticationServiceFake  = "authentication-service-fake"
+	parameterAuthenticationServiceURI   = "authentication-service-uri"
+	parameterInsecure                   = "insecure"
 )
 
 var (
-	defaultLogLevel             = logrus.WarnLevel.String()
-	defaultLogFormat            = utils.LogFormatText
-	defaultDBInMemoryImportFile = "" // DAO IN MEMORY
-	defaultDBConnectionURI      = ""
-	defaultDBName               = ""
-	defaultPortAPI              = 8080
-	defaultPortMonitoring       = 8081
+	defaultLogLevel                   = logrus.WarnLevel.String()
+	defaultLogFormat                  = utils.LogFormatText
+	defaultDBInMemoryImportFile       = ""              // DAO IN MEMORY
+	defaultDBInMemorySnapshotFile     = ""              // DAO IN MEMORY
+	defaultDBInMemorySnapshotInterval = 1 * time.Minute // DAO IN MEMORY
+	defaultDBConnectionURI            = ""
+	defaultDBReadConnectionURI        = ""
+	defaultDBName                     = ""
+	defaultDBSecondaryConnectionURI   = ""
+	defaultDBSecondaryName            = ""
+	defaultDBPurgeInterval            = 1 * time.Minute
+	defaultDBMaxOpenConnections       = 0                // DAO PG
+	defaultDBMaxIdleConnections       = 0                // DAO PG
+	defaultDBConnectionMaxLifetime    = time.Duration(0) // DAO PG
+	defaultDBMongoMaxPoolSize         = uint64(0)        // DAO MONGO
+	defaultDBMongoConnectTimeout      = 10 * time.Second // DAO MONGO
+	defaultDBMongoServerSelection     = 30 * time.Second // DAO MONGO
+	defaultDBMongoQueryTimeout        = 30 * time.Second // DAO MONGO
+	defaultDBRetryMaxAttempts         = 3
+	defaultDBRetryInitialBackoff      = 50 * time.Millisecond
+	defaultDBRetryMaxBackoff          = 1 * time.Second
+	defaultDBBreakerThreshold         = 5
+	defaultDBBreakerOpenDuration      = 30 * time.Second
+	defaultTenancy                    = ""
+	defaultTenantHeader               = ""
+	defaultTenantClaim                = "tenant"
+	defaultProblemTypeBaseURI         = "urn:problem-type:"
+	defaultCompressionMinSize         = 1024
+	defaultCompressionContentTypes    = middlewares.DefaultCompressedContentTypes
+	defaultMaxRequestBodySize         = int64(10 << 20)
+	defaultTranslationsDir            = ""
+	defaultDefaultLanguage            = "en"
+	defaultPortAPI                    = 8080
+	defaultPortMonitoring             = 8081
 )
 
 var rootCmd = &cobra.Command{
@@ -53,10 +127,44 @@ var rootCmd = &cobra.Command{
 			WithField(parameterLogFormat, config.LogFormat).
 			WithField(parameterPortAPI, config.PortAPI).
 			WithField(parameterPortMonitoring, config.PortMonitoring).
-			WithField(parameterDBInMemory, config.DBInMemory).                     // DAO IN MEMORY
-			WithField(parameterDBInMemoryImportFile, config.DBInMemoryImportFile). // DAO IN MEMORY
+			WithField(parameterDBInMemory, config.DBInMemory).                                 // DAO IN MEMORY
+			WithField(parameterDBInMemoryImportFile, config.DBInMemoryImportFile).             // DAO IN MEMORY
+			WithField(parameterDBInMemorySnapshotFile, config.DBInMemorySnapshotFile).         // DAO IN MEMORY
+			WithField(parameterDBInMemorySnapshotInterval, config.DBInMemorySnapshotInterval). // DAO IN MEMORY
 			WithField(parameterDBConnectionURI, config.DBConnectionURI).
+			WithField(parameterDBReadConnectionURI, config.DBReadConnectionURI).
 			WithField(parameterDBName, config.DBName).
+			WithField(parameterDBDatasources, config.DBDatasources).
+			WithField(parameterDBEntityDatasources, config.DBEntityDatasources).
+			WithField(parameterDBSecondaryConnectionURI, config.DBSecondaryConnectionURI).
+			WithField(parameterDBSecondaryName, config.DBSecondaryName).
+			WithField(parameterDBShadowRead, config.DBShadowRead).
+			WithField(parameterDBPurgeInterval, config.DBPurgeInterval).
+			WithField(parameterEntityTTL, config.EntityTTL).
+			WithField(parameterDBMaxOpenConnections, config.DBPool.MaxOpenConns).                  // DAO PG
+			WithField(parameterDBMaxIdleConnections, config.DBPool.MaxIdleConns).                  // DAO PG
+			WithField(parameterDBConnectionMaxLifetime, config.DBPool.ConnMaxLifetime).            // DAO PG
+			WithField(parameterDBMongoMaxPoolSize, config.DBMongoPool.MaxPoolSize).                // DAO MONGO
+			WithField(parameterDBMongoConnectTimeout, config.DBMongoPool.ConnectTimeout).          // DAO MONGO
+			WithField(parameterDBMongoServerSelection, config.DBMongoPool.ServerSelectionTimeout). // DAO MONGO
+			WithField(parameterDBMongoQueryTimeout, config.DBMongoPool.QueryTimeout).              // DAO MONGO
+			WithField(parameterDBChaos, config.DBChaos).
+			WithField(parameterDBRetryMaxAttempts, config.DBResilience.MaxAttempts).
+			WithField(parameterDBRetryInitialBackoff, config.DBResilience.InitialBackoff).
+			WithField(parameterDBRetryMaxBackoff, config.DBResilience.MaxBackoff).
+			WithField(parameterDBBreakerThreshold, config.DBResilience.FailureThreshold).
+			WithField(parameterDBBreakerOpenDuration, config.DBResilience.OpenDuration).
+			WithField(parameterEncryptionKeyID, config.EncryptionKeyID).
+			WithField(parameterTenancy, config.Tenancy).
+			WithField(parameterTenantHeader, config.TenantHeader).
+			WithField(parameterTenantClaim, config.TenantClaim).
+			WithField(parameterProblemDetails, config.ProblemDetails).
+			WithField(parameterProblemTypeBaseURI, config.ProblemTypeBaseURI).
+			WithField(parameterCompressionMinSize, config.Compression.MinSize).
+			WithField(parameterCompressionContentTypes, config.Compression.ContentTypes).
+			WithField(parameterMaxRequestBodySize, config.MaxRequestBodySize).
+			WithField(parameterTranslationsDir, config.TranslationsDir).
+			WithField(parameterDefaultLanguage, config.DefaultLanguage).
 			WithField(parameterAuthenticationServiceFake, config.AuthenticationServiceFake).
 			WithField(parameterAuthenticationServiceURI, config.AuthenticationServiceURI).
 			WithField(parameterInsecure, config.InsecureSkipVerify).
@@ -64,19 +172,63 @@ var rootCmd = &cobra.Command{
 
 		utils.InitLogger(config.LogLevel, config.LogFormat)
 
-		hc := handlers.NewHandlersContext(config)
+		hc, err := handlers.NewHandlersContext(config)
+		if err != nil {
+			utils.GetLogger().WithError(err).Fatal("unable to initialize the application, exiting")
+		}
 
-		monitoringRouter := handlers.NewMonitoringRouter(hc)
-		go monitoringRouter.Run(fmt.Sprintf(":%d", config.PortMonitoring))
+		monitoringServer := &http.Server{
+			Addr:    fmt.Sprintf(":%d", config.PortMonitoring),
+			Handler: handlers.NewMonitoringRouter(hc),
+		}
+		go func() {
+			err := monitoringServer.ListenAndServe()
+			if err != nil && err != http.ErrServerClosed {
+				utils.GetLogger().WithError(err).Error("error while starting app monitoring router")
+			}
+		}()
 
-		apiRouter := handlers.NewAPIRouter(hc)
-		err := apiRouter.Run(fmt.Sprintf(":%d", config.PortAPI))
-		if err != nil {
-			utils.GetLogger().WithError(err).Error("error while starting app monitoring router")
+		apiServer := &http.Server{
+			Addr:    fmt.Sprintf(":%d", config.PortAPI),
+			Handler: handlers.NewAPIRouter(hc),
+		}
+		apiErr := make(chan error, 1)
+		go func() {
+			apiErr <- apiServer.ListenAndServe()
+		}()
+
+		quit := make(chan os.Signal, 1)
+		signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
+
+		select {
+		case err := <-apiErr:
+			utils.GetLogger().WithError(err).Error("error while starting app api router")
+		case sig := <-quit:
+			utils.GetLogger().WithField("signal", sig.String()).Warn("shutting down")
 		}
+
+		shutdown(hc, apiServer, monitoringServer)
 	},
 }
 
+// shutdown gracefully stops the servers, waiting for the current requests, then releases the resources
+func shutdown(hc *handlers.Context, servers ...*http.Server) {
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	for _, server := range servers {
+		err := server.Shutdown(ctx)
+		if err != nil {
+			utils.GetLogger().WithError(err).WithField("addr", server.Addr).Error("error while shutting down server")
+		}
+	}
+
+	err := hc.Close()
+	if err != nil {
+		utils.GetLogger().WithError(err).Error("error while closing handlers context")
+	}
+}
+
 func Execute() {
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Println(err)
@@ -89,11 +241,11 @@ func init() {
 
 	rootCmd.PersistentFlags().StringVar(&cfgFile, parameterConfigurationFile, "", "Config file. All flags given in command line will override the values from this file.")
 
-	rootCmd.Flags().String(parameterLogLevel, defaultLogLevel, "Use this flag to set the logging level")
-	_ = viper.BindPFlag(parameterLogLevel, rootCmd.Flags().Lookup(parameterLogLevel))
+	rootCmd.PersistentFlags().String(parameterLogLevel, defaultLogLevel, "Use this flag to set the logging level")
+	_ = viper.BindPFlag(parameterLogLevel, rootCmd.PersistentFlags().Lookup(parameterLogLevel))
 
-	rootCmd.Flags().String(parameterLogFormat, defaultLogFormat, "Use this flag to set the logging format")
-	_ = viper.BindPFlag(parameterLogFormat, rootCmd.Flags().Lookup(parameterLogFormat))
+	rootCmd.PersistentFlags().String(parameterLogFormat, defaultLogFormat, "Use this flag to set the logging format")
+	_ = viper.BindPFlag(parameterLogFormat, rootCmd.PersistentFlags().Lookup(parameterLogFormat))
 
 	rootCmd.Flags().Int(parameterPortAPI, defaultPortAPI, "Use this flag to set the listening port of the api")
 	_ = viper.BindPFlag(parameterPortAPI, rootCmd.Flags().Lookup(parameterPortAPI))
@@ -101,11 +253,76 @@ func init() {
 	rootCmd.Flags().Int(parameterPortMonitoring, defaultPortMonitoring, "Use this flag to set the listening port of the monitoring apis")
 	_ = viper.BindPFlag(parameterPortMonitoring, rootCmd.Flags().Lookup(parameterPortMonitoring))
 
-	rootCmd.Flags().String(parameterDBConnectionURI, defaultDBConnectionURI, "Use this flag to set the db connection URI")
-	_ = viper.BindPFlag(parameterDBConnectionURI, rootCmd.Flags().Lookup(parameterDBConnectionURI))
+	rootCmd.PersistentFlags().String(parameterDBConnectionURI, defaultDBConnectionURI, "Use this flag to set the db connection URI")
+	_ = viper.BindPFlag(parameterDBConnectionURI, rootCmd.PersistentFlags().Lookup(parameterDBConnectionURI))
+
+	rootCmd.Flags().String(parameterDBReadConnectionURI, defaultDBReadConnectionURI, "Use this flag to set the db connection URI of the read replicas. This parameter is used when using a PostgreSQL database")
+	_ = viper.BindPFlag(parameterDBReadConnectionURI, rootCmd.Flags().Lookup(parameterDBReadConnectionURI))
+
+	rootCmd.PersistentFlags().String(parameterDBName, defaultDBName, "Use this flag to set the db name. This parameter is used when using a MongoDB database")
+	_ = viper.BindPFlag(parameterDBName, rootCmd.PersistentFlags().Lookup(parameterDBName))
+
+	rootCmd.PersistentFlags().StringToString(parameterDBDatasources, nil, "Use this flag to set the connection URIs of other datasources, as name=uri pairs. The db connection URI is the default datasource")
+
+	rootCmd.PersistentFlags().StringToString(parameterDBEntityDatasources, nil, "Use this flag to set the datasource of the entities not stored in the default datasource, as entity=datasource pairs")
+
+	rootCmd.Flags().String(parameterDBSecondaryConnectionURI, defaultDBSecondaryConnectionURI, "Use this flag to set the db connection URI of a secondary database receiving all the writes, to migrate to another database without downtime")
+	_ = viper.BindPFlag(parameterDBSecondaryConnectionURI, rootCmd.Flags().Lookup(parameterDBSecondaryConnectionURI))
+
+	rootCmd.Flags().String(parameterDBSecondaryName, defaultDBSecondaryName, "Use this flag to set the db name of the secondary database, the db name by default. This parameter is used when using a MongoDB secondary database")
+	_ = viper.BindPFlag(parameterDBSecondaryName, rootCmd.Flags().Lookup(parameterDBSecondaryName))
+
+	rootCmd.Flags().Bool(parameterDBShadowRead, false, "Use this flag to also send the reads to the secondary database in background, and compare their results with the ones of the primary database")
+	_ = viper.BindPFlag(parameterDBShadowRead, rootCmd.Flags().Lookup(parameterDBShadowRead))
+
+	rootCmd.Flags().Duration(parameterDBPurgeInterval, defaultDBPurgeInterval, "Use this flag to set the interval between two purges of the expired entities by the PostgreSQL and in memory databases, MongoDB purging them itself. 0 disables the purges")
+	_ = viper.BindPFlag(parameterDBPurgeInterval, rootCmd.Flags().Lookup(parameterDBPurgeInterval))
+
+	rootCmd.Flags().StringToString(parameterEntityTTL, nil, "Use this flag to set the time to live of the entities created without expiry date, as entity=duration pairs (eg. template=720h)")
+
+	rootCmd.Flags().Int(parameterDBMaxOpenConnections, defaultDBMaxOpenConnections, "Use this flag to set the maximum number of open connections of each PostgreSQL pool. 0 means unlimited") // DAO PG
+	_ = viper.BindPFlag(parameterDBMaxOpenConnections, rootCmd.Flags().Lookup(parameterDBMaxOpenConnections))                                                                                 // DAO PG
+
+	rootCmd.Flags().Int(parameterDBMaxIdleConnections, defaultDBMaxIdleConnections, "Use this flag to set the maximum number of idle connections of each PostgreSQL pool. 0 keeps the default of 2") // DAO PG
+	_ = viper.BindPFlag(parameterDBMaxIdleConnections, rootCmd.Flags().Lookup(parameterDBMaxIdleConnections))                                                                                        // DAO PG
+
+	rootCmd.Flags().Duration(parameterDBConnectionMaxLifetime, defaultDBConnectionMaxLifetime, "Use this flag to set the maximum time a PostgreSQL connection may be reused. 0 means forever") // DAO PG
+	_ = viper.BindPFlag(parameterDBConnectionMaxLifetime, rootCmd.Flags().Lookup(parameterDBConnectionMaxLifetime))                                                                            // DAO PG
+
+	rootCmd.PersistentFlags().Uint64(parameterDBMongoMaxPoolSize, defaultDBMongoMaxPoolSize, "Use this flag to set the maximum number of connections to each MongoDB server. 0 keeps the default of the driver (100)") // DAO MONGO
+	_ = viper.BindPFlag(parameterDBMongoMaxPoolSize, rootCmd.PersistentFlags().Lookup(parameterDBMongoMaxPoolSize))                                                                                                    // DAO MONGO
 
-	rootCmd.Flags().String(parameterDBName, defaultDBName, "Use this flag to set the db name. This parameter is used when using a MongoDB database")
-	_ = viper.BindPFlag(parameterDBName, rootCmd.Flags().Lookup(parameterDBName))
+	rootCmd.PersistentFlags().Duration(parameterDBMongoConnectTimeout, defaultDBMongoConnectTimeout, "Use this flag to set the timeout of the creation of a MongoDB connection") // DAO MONGO
+	_ = viper.BindPFlag(parameterDBMongoConnectTimeout, rootCmd.PersistentFlags().Lookup(parameterDBMongoConnectTimeout))                                                        // DAO MONGO
+
+	rootCmd.PersistentFlags().Duration(parameterDBMongoServerSelection, defaultDBMongoServerSelection, "Use this flag to set how long to wait for an available MongoDB server before failing a call") // DAO MONGO
+	_ = viper.BindPFlag(parameterDBMongoServerSelection, rootCmd.PersistentFlags().Lookup(parameterDBMongoServerSelection))                                                                           // DAO MONGO
+
+	rootCmd.PersistentFlags().Duration(parameterDBMongoQueryTimeout, defaultDBMongoQueryTimeout, "Use this flag to set the timeout of each MongoDB call") // DAO MONGO
+	_ = viper.BindPFlag(parameterDBMongoQueryTimeout, rootCmd.PersistentFlags().Lookup(parameterDBMongoQueryTimeout))                                     // DAO MONGO
+
+	rootCmd.Flags().Int(parameterDBRetryMaxAttempts, defaultDBRetryMaxAttempts, "Use this flag to set the maximum number of attempts of a db call failing because of the db state. Reads are always retried, writes only when it is safe. 1 disables retries")
+	_ = viper.BindPFlag(parameterDBRetryMaxAttempts, rootCmd.Flags().Lookup(parameterDBRetryMaxAttempts))
+
+	rootCmd.Flags().Duration(parameterDBRetryInitialBackoff, defaultDBRetryInitialBackoff, "Use this flag to set the maximum delay before the first retry of a db call, doubled for each retry")
+	_ = viper.BindPFlag(parameterDBRetryInitialBackoff, rootCmd.Flags().Lookup(parameterDBRetryInitialBackoff))
+
+	rootCmd.Flags().Duration(parameterDBRetryMaxBackoff, defaultDBRetryMaxBackoff, "Use this flag to set the maximum delay between two retries of a db call. 0 means no maximum")
+	_ = viper.BindPFlag(parameterDBRetryMaxBackoff, rootCmd.Flags().Lookup(parameterDBRetryMaxBackoff))
+
+	rootCmd.Flags().Int(parameterDBBreakerThreshold, defaultDBBreakerThreshold, "Use this flag to set the number of consecutive db failures opening the circuit breaker. 0 disables the circuit breaker")
+	_ = viper.BindPFlag(parameterDBBreakerThreshold, rootCmd.Flags().Lookup(parameterDBBreakerThreshold))
+
+	rootCmd.Flags().Duration(parameterDBBreakerOpenDuration, defaultDBBreakerOpenDuration, "Use this flag to set the time during which the db calls fail fast once the circuit breaker is open")
+	_ = viper.BindPFlag(parameterDBBreakerOpenDuration, rootCmd.Flags().Lookup(parameterDBBreakerOpenDuration))
+
+	rootCmd.PersistentFlags().StringToString(parameterEncryptionKeys, nil, "Use this flag to set the base64 encoded AES keys encrypting the model fields tagged with encrypt:\"true\", as id=key pairs. Prefer setting them in Vault. Empty disables the encryption")
+
+	rootCmd.PersistentFlags().String(parameterEncryptionKeyID, "", "Use this flag to set the id of the key encrypting the new values, the previous keys only decrypt the existing values. Optional with only one key")
+	_ = viper.BindPFlag(parameterEncryptionKeyID, rootCmd.PersistentFlags().Lookup(parameterEncryptionKeyID))
+
+	rootCmd.Flags().Bool(parameterDBChaos, false, "Use this flag to enable the injection of faults in the db calls, configurable through the /chaos endpoint of the monitoring router. Never enable it in production")
+	_ = viper.BindPFlag(parameterDBChaos, rootCmd.Flags().Lookup(parameterDBChaos))
 
 	rootCmd.Flags().Bool(parameterDBInMemory, false, "Use this flag to enable the db in memory mode") // DAO IN MEMORY
 	_ = viper.BindPFlag(parameterDBInMemory, rootCmd.Flags().Lookup(parameterDBInMemory))             // DAO IN MEMORY
@@ -113,6 +330,42 @@ func init() {
 	rootCmd.Flags().String(parameterDBInMemoryImportFile, defaultDBInMemoryImportFile, "Use this flag to import a dataset in db in memory mode") // DAO IN MEMORY
 	_ = viper.BindPFlag(parameterDBInMemoryImportFile, rootCmd.Flags().Lookup(parameterDBInMemoryImportFile))                                    // DAO IN MEMORY
 
+	rootCmd.Flags().String(parameterDBInMemorySnapshotFile, defaultDBInMemorySnapshotFile, "Use this flag to save the db in memory to this file periodically and on shutdown, and to reload it on startup") // DAO IN MEMORY
+	_ = viper.BindPFlag(parameterDBInMemorySnapshotFile, rootCmd.Flags().Lookup(parameterDBInMemorySnapshotFile))                                                                                           // DAO IN MEMORY
+
+	rootCmd.Flags().Duration(parameterDBInMemorySnapshotInterval, defaultDBInMemorySnapshotInterval, "Use this flag to set the interval between two db in memory snapshots. 0 disables periodic snapshots") // DAO IN MEMORY
+	_ = viper.BindPFlag(parameterDBInMemorySnapshotInterval, rootCmd.Flags().Lookup(parameterDBInMemorySnapshotInterval))                                                                                   // DAO IN MEMORY
+
+	rootCmd.PersistentFlags().String(parameterTenancy, defaultTenancy, "Use this flag to enable the multi-tenancy: shared to store all the tenants in the same database, isolated to store each tenant in its own MongoDB database or PostgreSQL schema. Empty disables it")
+	_ = viper.BindPFlag(parameterTenancy, rootCmd.PersistentFlags().Lookup(parameterTenancy))
+
+	rootCmd.Flags().String(parameterTenantHeader, defaultTenantHeader, "Use this flag to read the tenant of the requests from this header. Only set it when the header is set by a trusted gateway")
+	_ = viper.BindPFlag(parameterTenantHeader, rootCmd.Flags().Lookup(parameterTenantHeader))
+
+	rootCmd.Flags().String(parameterTenantClaim, defaultTenantClaim, "Use this flag to set the field of the authenticated identity holding the tenant of the requests. Empty ignores the identity")
+	_ = viper.BindPFlag(parameterTenantClaim, rootCmd.Flags().Lookup(parameterTenantClaim))
+
+	rootCmd.Flags().Bool(parameterProblemDetails, false, "Use this flag to write all the errors as RFC 7807 problems (application/problem+json). Otherwise only the requests accepting application/problem+json receive them")
+	_ = viper.BindPFlag(parameterProblemDetails, rootCmd.Flags().Lookup(parameterProblemDetails))
+
+	rootCmd.Flags().String(parameterProblemTypeBaseURI, defaultProblemTypeBaseURI, "Use this flag to set the prefix of the error types building the type URIs of the problems, eg. the URL of their documentation")
+	_ = viper.BindPFlag(parameterProblemTypeBaseURI, rootCmd.Flags().Lookup(parameterProblemTypeBaseURI))
+
+	rootCmd.Flags().Int(parameterCompressionMinSize, defaultCompressionMinSize, "Use this flag to set the minimum size in bytes of the compressed responses. Negative disables the compression")
+	_ = viper.BindPFlag(parameterCompressionMinSize, rootCmd.Flags().Lookup(parameterCompressionMinSize))
+
+	rootCmd.Flags().StringSlice(parameterCompressionContentTypes, defaultCompressionContentTypes, "Use this flag to set the media types of the compressed responses, eg. application/json or text/*")
+	_ = viper.BindPFlag(parameterCompressionContentTypes, rootCmd.Flags().Lookup(parameterCompressionContentTypes))
+
+	rootCmd.Flags().Int64(parameterMaxRequestBodySize, defaultMaxRequestBodySize, "Use this flag to set the maximum size in bytes of the request bodies, once decompressed. 0 disables the limit")
+	_ = viper.BindPFlag(parameterMaxRequestBodySize, rootCmd.Flags().Lookup(parameterMaxRequestBodySize))
+
+	rootCmd.Flags().String(parameterTranslationsDir, defaultTranslationsDir, "Use this flag to set the directory of the translations of the error messages, a YAML or JSON file per language named by its tag, eg. fr.yaml. Empty disables the translations")
+	_ = viper.BindPFlag(parameterTranslationsDir, rootCmd.Flags().Lookup(parameterTranslationsDir))
+
+	rootCmd.Flags().String(parameterDefaultLanguage, defaultDefaultLanguage, "Use this flag to set the language of the error messages of the code, sent when the Accept-Language header matches no translation")
+	_ = viper.BindPFlag(parameterDefaultLanguage, rootCmd.Flags().Lookup(parameterDefaultLanguage))
+
 	rootCmd.Flags().Bool(parameterAuthenticationServiceFake, false, "Use this flag to enable authentication service fake")
 	_ = viper.BindPFlag(parameterAuthenticationServiceFake, rootCmd.Flags().Lookup(parameterAuthenticationServiceFake))
 
@@ -154,10 +407,72 @@ func initConfig() {
 	config.PortAPI = viper.GetInt(parameterPortAPI)
 	config.PortMonitoring = viper.GetInt(parameterPortMonitoring)
 	config.DBConnectionURI = viper.GetString(parameterDBConnectionURI)
+	config.DBReadConnectionURI = viper.GetString(parameterDBReadConnectionURI)
 	config.DBName = viper.GetString(parameterDBName)
-	config.DBInMemory = viper.GetBool(parameterDBInMemory)                       // DAO IN MEMORY
-	config.DBInMemoryImportFile = viper.GetString(parameterDBInMemoryImportFile) // DAO IN MEMORY
+	config.DBDatasources = getStringMapString(parameterDBDatasources)
+	config.DBEntityDatasources = getStringMapString(parameterDBEntityDatasources)
+	config.DBSecondaryConnectionURI = viper.GetString(parameterDBSecondaryConnectionURI)
+	config.DBSecondaryName = viper.GetString(parameterDBSecondaryName)
+	if config.DBSecondaryName == "" {
+		config.DBSecondaryName = config.DBName
+	}
+	config.DBShadowRead = viper.GetBool(parameterDBShadowRead)
+	config.DBPurgeInterval = viper.GetDuration(parameterDBPurgeInterval)
+	config.EntityTTL = getStringMapDuration(parameterEntityTTL)
+	config.DBPool.MaxOpenConns = viper.GetInt(parameterDBMaxOpenConnections)                       // DAO PG
+	config.DBPool.MaxIdleConns = viper.GetInt(parameterDBMaxIdleConnections)                       // DAO PG
+	config.DBPool.ConnMaxLifetime = viper.GetDuration(parameterDBConnectionMaxLifetime)            // DAO PG
+	config.DBMongoPool.MaxPoolSize = viper.GetUint64(parameterDBMongoMaxPoolSize)                  // DAO MONGO
+	config.DBMongoPool.ConnectTimeout = viper.GetDuration(parameterDBMongoConnectTimeout)          // DAO MONGO
+	config.DBMongoPool.ServerSelectionTimeout = viper.GetDuration(parameterDBMongoServerSelection) // DAO MONGO
+	config.DBMongoPool.QueryTimeout = viper.GetDuration(parameterDBMongoQueryTimeout)              // DAO MONGO
+	config.DBChaos = viper.GetBool(parameterDBChaos)
+	config.DBResilience.MaxAttempts = viper.GetInt(parameterDBRetryMaxAttempts)
+	config.DBResilience.InitialBackoff = viper.GetDuration(parameterDBRetryInitialBackoff)
+	config.DBResilience.MaxBackoff = viper.GetDuration(parameterDBRetryMaxBackoff)
+	config.DBResilience.FailureThreshold = viper.GetInt(parameterDBBreakerThreshold)
+	config.DBResilience.OpenDuration = viper.GetDuration(parameterDBBreakerOpenDuration)
+	config.EncryptionKeys = getStringMapString(parameterEncryptionKeys)
+	config.EncryptionKeyID = viper.GetString(parameterEncryptionKeyID)
+	config.DBInMemory = viper.GetBool(parameterDBInMemory)                                     // DAO IN MEMORY
+	config.DBInMemoryImportFile = viper.GetString(parameterDBInMemoryImportFile)               // DAO IN MEMORY
+	config.DBInMemorySnapshotFile = viper.GetString(parameterDBInMemorySnapshotFile)           // DAO IN MEMORY
+	config.DBInMemorySnapshotInterval = viper.GetDuration(parameterDBInMemorySnapshotInterval) // DAO IN MEMORY
+	config.Tenancy = dao.Tenancy(viper.GetString(parameterTenancy))
+	config.TenantHeader = viper.GetString(parameterTenantHeader)
+	config.TenantClaim = viper.GetString(parameterTenantClaim)
+	config.ProblemDetails = viper.GetBool(parameterProblemDetails)
+	config.ProblemTypeBaseURI = viper.GetString(parameterProblemTypeBaseURI)
+	config.Compression.MinSize = viper.GetInt(parameterCompressionMinSize)
+	config.Compression.ContentTypes = viper.GetStringSlice(parameterCompressionContentTypes)
+	config.MaxRequestBodySize = viper.GetInt64(parameterMaxRequestBodySize)
+	config.TranslationsDir = viper.GetString(parameterTranslationsDir)
+	config.DefaultLanguage = viper.GetString(parameterDefaultLanguage)
 	config.AuthenticationServiceFake = viper.GetBool(parameterAuthenticationServiceFake)
 	config.AuthenticationServiceURI = viper.GetString(parameterAuthenticationServiceURI)
 	config.InsecureSkipVerify = viper.GetBool(parameterInsecure)
 }
+
+// getStringMapDuration returns the map of durations given by a name=duration pairs flag, or in the configuration file
+func getStringMapDuration(key string) map[string]time.Duration {
+	result := make(map[string]time.Duration)
+	for name, value := range getStringMapString(key) {
+		duration, err := time.ParseDuration(value)
+		if err != nil {
+			utils.GetLogger().WithError(err).WithField(key, name).Fatal("invalid duration")
+		}
+		result[name] = duration
+	}
+	return result
+}
+
+// getStringMapString returns the map given by a name=value pairs flag, or in the configuration file
+func getStringMapString(key string) map[string]string {
+	for _, flags := range []*pflag.FlagSet{rootCmd.Flags(), rootCmd.PersistentFlags()} {
+		if flag := flags.Lookup(key); flag != nil && flag.Changed {
+			result, _ := flags.GetStringToString(key)
+			return result
+		}
+	}
+	return viper.GetStringMapString(key)
+}
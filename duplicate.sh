@@ -127,41 +127,46 @@ createOneEntity()
 
     if [[ "$ENTITY_NAME" = "template" ]]
     then
-        # in this case everything is ok, just change the SQL schema
+        # in this case everything is ok, the template entity is already registered
         DELETE_TEMPLATES=0
-        ${SED_CMD} -i -r "s/schema/${ENTITY_SCHEMA}/g" storage/dao/postgresql/database_postgresql_${ENTITY_NAME}.go
+        if [[ ${DAO_PG} -eq 1 ]]
+        then
+            echo "Create the PostgreSQL table of the templates, their name being stored in the code column:"
+            echo "    CREATE TABLE ${ENTITY_SCHEMA:-schema}.template (id uuid PRIMARY KEY DEFAULT gen_random_uuid(), tenant_id text NOT NULL, created_at timestamptz NOT NULL DEFAULT now(), updated_at timestamptz, code text NOT NULL, expires_at timestamptz, UNIQUE (tenant_id, code));"
+        fi
     else
-        cp handlers/template_handler.go handlers/${ENTITY_NAME}_handler.go
-        ${SED_CMD} -i -r "s/template/${ENTITY_NAME}/g" handlers/${ENTITY_NAME}_handler.go
-        ${SED_CMD} -i -r "s/Template/${ENTITY_NAME_UP}/g" handlers/${ENTITY_NAME}_handler.go
-
-        cp storage/dao/postgresql/database_postgresql_template.go storage/dao/postgresql/database_postgresql_${ENTITY_NAME}.go
-        ${SED_CMD} -i -r "s/template/${ENTITY_NAME}/g" storage/dao/postgresql/database_postgresql_${ENTITY_NAME}.go
-        ${SED_CMD} -i -r "s/Template/${ENTITY_NAME_UP}/g" storage/dao/postgresql/database_postgresql_${ENTITY_NAME}.go
-        ${SED_CMD} -i -r "s/schema/${ENTITY_SCHEMA}/g" storage/dao/postgresql/database_postgresql_${ENTITY_NAME}.go
-
-        cp storage/dao/mongodb/database_mongodb_template.go storage/dao/mongodb/database_mongodb_${ENTITY_NAME}.go
-        ${SED_CMD} -i -r "s/template/${ENTITY_NAME}/g" storage/dao/mongodb/database_mongodb_${ENTITY_NAME}.go
-        ${SED_CMD} -i -r "s/Template/${ENTITY_NAME_UP}/g" storage/dao/mongodb/database_mongodb_${ENTITY_NAME}.go
+        # the model, registered as a generic entity: the DAO of every backend and the CRUD routes come from its registration
+        cp storage/model/template.go storage/model/${ENTITY_NAME}.go
+        ${SED_CMD} -i -r "s/Template/${ENTITY_NAME_UP}/g;s/template/${ENTITY_NAME}/g" storage/model/${ENTITY_NAME}.go
 
-        cp storage/dao/mock/database_mock_template.go storage/dao/mock/database_mock_${ENTITY_NAME}.go
-        ${SED_CMD} -i -r "s/template/${ENTITY_NAME}/g" storage/dao/mock/database_mock_${ENTITY_NAME}.go
-        ${SED_CMD} -i -r "s/Template/${ENTITY_NAME_UP}/g" storage/dao/mock/database_mock_${ENTITY_NAME}.go
+        cat > storage/dao/entity_${ENTITY_NAME}.go <<EOF
+package dao
 
-        cp storage/dao/fake/database_fake_template.go storage/dao/fake/database_fake_${ENTITY_NAME}.go
-        ${SED_CMD} -i -r "s/template/${ENTITY_NAME}/g" storage/dao/fake/database_fake_${ENTITY_NAME}.go
-        ${SED_CMD} -i -r "s/Template/${ENTITY_NAME_UP}/g" storage/dao/fake/database_fake_${ENTITY_NAME}.go
+import "${NEW_PROJECT_FULL_NAME}/storage/model"
 
-        cp storage/model/template.go storage/model/${ENTITY_NAME}.go
-        ${SED_CMD} -i -r "s/Template/${ENTITY_NAME_UP}/g" storage/model/${ENTITY_NAME}.go
-
-        ${SED_CMD} -i -r "/\/\/ start: template routes/{:next;N;/\/\/ end: template routes/{bend};bnext;:end;p;s|template|${ENTITY_NAME}|g;s|Template|${ENTITY_NAME_UP}|g}" handlers/handler.go
+func init() {
+	// stored in the ${ENTITY_NAME} table or collection, with a unique name by tenant
+	RegisterEntity("${ENTITY_NAME}", model.${ENTITY_NAME_UP}{}, "name")
+}
+EOF
 
-        ${SED_CMD} -i -r "/\/\/ start: template dao funcs/{:next;N;/\/\/ end: template dao funcs/{bend};bnext;:end;p;s|template|${ENTITY_NAME}|g;s|Template|${ENTITY_NAME_UP}|g}" storage/dao/database.go
+        cat > handlers/${ENTITY_NAME}_resource.go <<EOF
+package handlers
 
-        ${SED_CMD} -i -r "/\/\/ Template export/{p;s/Template/${ENTITY_NAME_UP}/g}" storage/dao/fake/database_fake.go
+func init() {
+	// the CRUD routes of the ${ENTITY_NAME}s, override their operations with Hooks
+	RegisterResource(Resource{
+		Entity: "${ENTITY_NAME}",
+		Path:   "/${ENTITY_NAME}s",
+	})
+}
+EOF
 
-        ${SED_CMD} -i -r "/\/\/ Template index/{p;s/Template/${ENTITY_NAME_UP}/g}" storage/dao/mongodb/database_mongodb.go
+        if [[ ${DAO_PG} -eq 1 ]]
+        then
+            echo "Create the PostgreSQL table of the ${ENTITY_NAME}s, with a column by field of the model:"
+            echo "    CREATE TABLE ${ENTITY_SCHEMA:-schema}.${ENTITY_NAME} (id uuid PRIMARY KEY DEFAULT gen_random_uuid(), tenant_id text NOT NULL, created_at timestamptz NOT NULL DEFAULT now(), updated_at timestamptz, name text NOT NULL, expires_at timestamptz, UNIQUE (tenant_id, name));"
+        fi
     fi
 }
 
@@ -177,10 +182,7 @@ clean()
     if [[ ${DELETE_TEMPLATES} -eq 1 ]]
     then
         # remove template data
-        ${SED_CMD} -i -r "/\/\/ start: template routes/{:next;N;/\/\/ end: template routes/{bend};bnext;:end;d}" handlers/handler.go
-        ${SED_CMD} -i -r "/\/\/ start: template dao funcs/{:next;N;/\/\/ end: template dao funcs/{bend};bnext;:end;d}" storage/dao/database.go
-        ${SED_CMD} -i -r "/\/\/ Template export/d" storage/dao/fake/database_fake.go
-        ${SED_CMD} -i -r "/\/\/ Template index/d" storage/dao/mongodb/database_mongodb.go
+        ${SED_CMD} -i -r "/\/\/ Template index/d" storage/dao/mongodb/database_mongodb_indexes.go
 
         find . -iname '*template*' -exec rm {} \;
     fi
@@ -188,21 +190,23 @@ clean()
     # remove unwanted DAO
     if [[ ${DAO_MONGO} -eq 0 ]]
     then
-        ${SED_CMD} -i -r '/\/\/ DAO MONGO/d' handlers/handler.go
+        ${SED_CMD} -i -r '/\/\/ DAO MONGO/d' handlers/handler.go cmd/root.go cmd/db_copy.go
         rm -rf ./storage/dao/mongodb
+        rm -f ./cmd/db_indexes.go
     fi
 
     if [[ ${DAO_PG} -eq 0 ]]
     then
-        ${SED_CMD} -i -r '/\/\/ DAO PG/d' handlers/handler.go
+        ${SED_CMD} -i -r '/\/\/ DAO PG/d' handlers/handler.go cmd/root.go cmd/db_copy.go
         rm -rf ./storage/dao/postgresql
     fi
 
     if [[ ${DAO_IN_MEMORY} -eq 0 ]]
     then
-        ${SED_CMD} -i -r '/\/\/ DAO IN MEMORY/d' handlers/handler.go cmd/root.go
+        ${SED_CMD} -i -r '/\/\/ DAO IN MEMORY/d' handlers/handler.go cmd/root.go cmd/db_copy.go
         ${SED_CMD} -i -r '/(start-offline|db-in-memory)/d' Makefile
         rm -rf ./storage/dao/fake
+        rm -f ./handlers/database_fake_handler.go
     fi
 
     rm duplicate.sh
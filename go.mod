@@ -1,10 +1,10 @@
 module github.com/adeo/turbine-go-api-skeleton
 
-go 1.12
+go 1.13
 
 require (
 	github.com/adeo/turbine-auth/pkg/client/v3 v3.0.1
-	github.com/coocood/freecache v1.1.0
+	github.com/andybalholm/brotli v0.0.0-20190621154722-5f990b63d2d6
 	github.com/gin-gonic/gin v1.4.1-0.20191017021444-0ce46610292c
 	github.com/go-playground/locales v0.13.0 // indirect
 	github.com/golang/snappy v0.0.1 // indirect
@@ -24,13 +24,15 @@ require (
 	github.com/satori/go.uuid v1.2.0
 	github.com/sirupsen/logrus v1.4.2
 	github.com/spf13/cobra v0.0.5
+	github.com/spf13/pflag v1.0.3
 	github.com/spf13/viper v1.4.0
 	github.com/stretchr/testify v1.4.0
 	github.com/tidwall/pretty v1.0.0 // indirect
+	github.com/ugorji/go/codec v1.1.7
 	github.com/xdg/scram v0.0.0-20180814205039-7eeb5667e42c // indirect
 	github.com/xdg/stringprep v1.0.0 // indirect
 	go.mongodb.org/mongo-driver v1.1.2
 	golang.org/x/sys v0.0.0-20191020212454-3e7259c5e7c2 // indirect
 	gopkg.in/go-playground/validator.v9 v9.30.0
-	gopkg.in/yaml.v2 v2.2.4 // indirect
+	gopkg.in/yaml.v2 v2.2.4
 )
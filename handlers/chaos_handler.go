@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/adeo/turbine-go-api-skeleton/storage/dao/chaos"
+	"github.com/adeo/turbine-go-api-skeleton/storage/model"
+	"github.com/adeo/turbine-go-api-skeleton/storage/validators"
+	"github.com/adeo/turbine-go-api-skeleton/utils"
+	"github.com/adeo/turbine-go-api-skeleton/utils/httputils"
+	"github.com/gin-gonic/gin"
+)
+
+// GetChaosConfig returns the faults currently injected in the database calls
+func (hc *Context) GetChaosConfig(c *gin.Context) {
+	httputils.JSON(c.Writer, http.StatusOK, hc.chaos.GetConfig())
+}
+
+// UpdateChaosConfig replaces the faults injected in the database calls
+func (hc *Context) UpdateChaosConfig(c *gin.Context) {
+	body, err := c.GetRawData()
+	if err != nil {
+		utils.GetLoggerFromCtx(c).WithError(err).Error("error while updating chaos config, read data fail")
+		httputils.JSONError(c.Writer, model.ErrInternalServer)
+		return
+	}
+
+	config := chaos.Config{}
+	err = json.Unmarshal(body, &config)
+	if err != nil {
+		httputils.JSONError(c.Writer, model.ErrBadRequestFormat)
+		return
+	}
+
+	err = hc.validator.StructCtx(c, config)
+	if err != nil {
+		httputils.JSONError(c.Writer, validators.NewDataValidationAPIError(err))
+		return
+	}
+
+	hc.chaos.SetConfig(config)
+	utils.GetLoggerFromCtx(c).WithField("rules", config.Rules).Warn("chaos config updated")
+	httputils.JSON(c.Writer, http.StatusOK, hc.chaos.GetConfig())
+}
+
+// DeleteChaosConfig stops injecting faults in the database calls
+func (hc *Context) DeleteChaosConfig(c *gin.Context) {
+	hc.chaos.SetConfig(chaos.Config{})
+	utils.GetLoggerFromCtx(c).Warn("chaos config deleted")
+	httputils.JSON(c.Writer, http.StatusNoContent, nil)
+}
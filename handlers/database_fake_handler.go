@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/adeo/turbine-go-api-skeleton/storage/dao"
+	dbFake "github.com/adeo/turbine-go-api-skeleton/storage/dao/fake"
+	"github.com/adeo/turbine-go-api-skeleton/storage/model"
+	"github.com/adeo/turbine-go-api-skeleton/storage/validators"
+	"github.com/adeo/turbine-go-api-skeleton/utils"
+	"github.com/adeo/turbine-go-api-skeleton/utils/httputils"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	importModeReplace = "replace"
+	importModeMerge   = "merge"
+)
+
+// handleDatabaseFakeRoutes adds the routes to export and import the data of the in memory database,
+// used to reset test environments without restarting them
+func handleDatabaseFakeRoutes(hc *Context, router *gin.RouterGroup, db *dbFake.DatabaseFake) {
+	router.Handle(http.MethodGet, "/export", getExport(db))
+	router.Handle(http.MethodOptions, "/export", hc.GetOptionsHandler(httputils.AllowedHeaders, http.MethodGet))
+
+	router.Handle(http.MethodGet, "/export/:entity", getEntityExport(db))
+	router.Handle(http.MethodOptions, "/export/:entity", hc.GetOptionsHandler(httputils.AllowedHeaders, http.MethodGet))
+
+	router.Handle(http.MethodPost, "/import", postImport(hc, db))
+	router.Handle(http.MethodOptions, "/import", hc.GetOptionsHandler(httputils.AllowedHeaders, http.MethodPost))
+}
+
+// getExport returns all the data of the database, in the format of the import file
+func getExport(db *dbFake.DatabaseFake) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		httputils.JSON(c.Writer, http.StatusOK, db.Export())
+	}
+}
+
+// getEntityExport returns the data of one entity, eg. /export/template, or /export/product for a registered entity
+func getEntityExport(db *dbFake.DatabaseFake) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		data, ok := db.Export().Entity(c.Param("entity"))
+		if !ok {
+			httputils.JSONErrorWithMessage(c.Writer, model.ErrNotFound, "Entity not found")
+			return
+		}
+		httputils.JSON(c.Writer, http.StatusOK, data)
+	}
+}
+
+// postImport replaces the data of the database with the body, or merges them when the query parameter mode is merge
+func postImport(hc *Context, db *dbFake.DatabaseFake) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		mode := c.DefaultQuery("mode", importModeReplace)
+		err := hc.validator.VarCtx(c, mode, "oneof="+importModeReplace+" "+importModeMerge)
+		if err != nil {
+			httputils.JSONError(c.Writer, validators.NewDataValidationAPIError(err))
+			return
+		}
+
+		body, err := c.GetRawData()
+		if err != nil {
+			utils.GetLoggerFromCtx(c).WithError(err).Error("error while importing data, read data fail")
+			httputils.JSONError(c.Writer, model.ErrInternalServer)
+			return
+		}
+
+		export := &dbFake.Export{}
+		err = json.Unmarshal(body, export)
+		if err != nil {
+			httputils.JSONError(c.Writer, model.ErrBadRequestFormat)
+			return
+		}
+
+		err = db.Import(export, mode == importModeMerge)
+		if errors.Is(err, dbFake.ErrInvalidExport) {
+			httputils.JSONErrorWithMessage(c.Writer, model.ErrBadRequestFormat, err.Error())
+			return
+		} else if e, ok := dao.AsDAOError(err); ok {
+			switch {
+			case e.Type == dao.ErrTypeDuplicate:
+				httputils.JSONErrorWithMessage(c.Writer, model.ErrAlreadyExists, e.Cause.Error())
+				return
+			default:
+				utils.GetLoggerFromCtx(c).WithError(err).WithField("type", e.Type).Error("error Import: Error type not handled")
+				httputils.JSONError(c.Writer, model.ErrInternalServer)
+				return
+			}
+		} else if err != nil {
+			utils.GetLoggerFromCtx(c).WithError(err).Error("error while importing data")
+			httputils.JSONError(c.Writer, model.ErrInternalServer)
+			return
+		}
+
+		httputils.JSON(c.Writer, http.StatusNoContent, nil)
+	}
+}
@@ -0,0 +1,15 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/adeo/turbine-go-api-skeleton/storage/dao"
+	"github.com/adeo/turbine-go-api-skeleton/utils/httputils"
+	"github.com/gin-gonic/gin"
+)
+
+// GetDatabaseStats returns the statistics of the connection pools of the database
+func (hc *Context) GetDatabaseStats(c *gin.Context) {
+	provider := hc.dbBackend.(dao.PoolStatsProvider)
+	httputils.JSON(c.Writer, http.StatusOK, provider.PoolStats())
+}
@@ -0,0 +1,11 @@
+package handlers
+
+import (
+	"github.com/adeo/turbine-go-api-skeleton/storage/model"
+)
+
+// newAPIError returns the error to send with the given description, eg. naming the entity not found
+func newAPIError(e model.APIError, description string) error {
+	e.Description = description
+	return &e
+}
@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/adeo/turbine-go-api-skeleton/utils/httputils"
+	"github.com/gin-gonic/gin"
+)
+
+// HandlerFunc is a handler returning the status and the body of its response, or the error to send, written by
+// the error middleware, see middlewares.MapError
+type HandlerFunc func(c *gin.Context) (int, interface{}, error)
+
+// ErrorHandlerFunc is a handler writing its response, or returning the error to send like a HandlerFunc
+type ErrorHandlerFunc func(c *gin.Context) error
+
+// Handle returns the gin handler of h, rendering its body in the negotiated format. The 200 responses of the GET
+// requests are conditional, see httputils.RenderOK.
+func Handle(h HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		status, body, err := h(c)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+		if status == http.StatusOK && c.Request.Method == http.MethodGet {
+			httputils.RenderOK(c, body)
+			return
+		}
+		httputils.Render(c, status, body)
+	}
+}
+
+// HandleError returns the gin handler of h
+func HandleError(h ErrorHandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := h(c); err != nil {
+			abortWithError(c, err)
+		}
+	}
+}
+
+func abortWithError(c *gin.Context, err error) {
+	_ = c.Error(err)
+	c.Abort()
+}
@@ -1,20 +1,30 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
-	"strings"
+	"time"
 
 	authentication "github.com/adeo/turbine-auth/pkg/client/v3/http"
 	"github.com/adeo/turbine-auth/pkg/client/v3/middleware"
 	"github.com/adeo/turbine-go-api-skeleton/middlewares"
 	"github.com/adeo/turbine-go-api-skeleton/storage/dao"
+	"github.com/adeo/turbine-go-api-skeleton/storage/dao/chaos"
+	"github.com/adeo/turbine-go-api-skeleton/storage/dao/dualwrite"
+	"github.com/adeo/turbine-go-api-skeleton/storage/dao/encryption"
 	dbFake "github.com/adeo/turbine-go-api-skeleton/storage/dao/fake" // DAO IN MEMORY
+	"github.com/adeo/turbine-go-api-skeleton/storage/dao/metrics"
 	dbMock "github.com/adeo/turbine-go-api-skeleton/storage/dao/mock"
 	"github.com/adeo/turbine-go-api-skeleton/storage/dao/mongodb"    // DAO MONGO
 	"github.com/adeo/turbine-go-api-skeleton/storage/dao/postgresql" // DAO PG
+	"github.com/adeo/turbine-go-api-skeleton/storage/dao/resilience"
+	"github.com/adeo/turbine-go-api-skeleton/storage/dao/routing"
 	"github.com/adeo/turbine-go-api-skeleton/storage/validators"
 	"github.com/adeo/turbine-go-api-skeleton/utils"
 	"github.com/adeo/turbine-go-api-skeleton/utils/httputils"
+	"github.com/adeo/turbine-go-api-skeleton/utils/i18n"
 	"github.com/gin-gonic/gin"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"gopkg.in/go-playground/validator.v9"
@@ -32,39 +42,180 @@ var (
 )
 
 type Config struct {
-	Mock                      bool
-	DBInMemory                bool   // DAO IN MEMORY
-	DBInMemoryImportFile      string // DAO IN MEMORY
-	DBConnectionURI           string
-	DBName                    string
-	PortAPI                   int
-	PortMonitoring            int
-	LogLevel                  string
-	LogFormat                 string
-	AuthenticationServiceFake bool
-	AuthenticationServiceURI  string
-	InsecureSkipVerify        bool
+	Mock                       bool
+	DBInMemory                 bool          // DAO IN MEMORY
+	DBInMemoryImportFile       string        // DAO IN MEMORY
+	DBInMemorySnapshotFile     string        // DAO IN MEMORY
+	DBInMemorySnapshotInterval time.Duration // DAO IN MEMORY
+	DBConnectionURI            string
+	DBReadConnectionURI        string
+	DBName                     string
+	DBDatasources              map[string]string // connection uri of the other datasources, by name
+	DBEntityDatasources        map[string]string // datasource of the entities not stored in the default one
+	DBDriverSettings           []interface{}     // settings of the drivers registered by the project, see dao.Register
+	DBSecondaryConnectionURI   string            // empty when the dual writes are disabled
+	DBSecondaryName            string
+	DBShadowRead               bool
+	DBPurgeInterval            time.Duration            // interval between two purges of the expired entities, 0 disables them
+	EntityTTL                  map[string]time.Duration // time to live of the entities created without expiry date, by entity
+	DBPool                     postgresql.PoolConfig    // DAO PG
+	DBMongoPool                mongodb.PoolConfig       // DAO MONGO
+	DBChaos                    bool
+	DBResilience               resilience.Config
+	EncryptionKeys             map[string]string // base64 AES keys encrypting the fields tagged with encrypt:"true", by id
+	EncryptionKeyID            string            // id of the key encrypting the new values, optional with only one key
+	Tenancy                    dao.Tenancy       // empty when the tenancy is disabled
+	TenantHeader               string
+	TenantClaim                string
+	ProblemDetails             bool   // write all the errors as RFC 7807 problems, not only for the requests accepting them
+	ProblemTypeBaseURI         string // prefix of the error types building the problem types
+	Compression                middlewares.CompressionConfig
+	MaxRequestBodySize         int64  // maximum size of the request bodies in bytes once decompressed, 0 for no limit
+	TranslationsDir            string // directory of the translations of the error messages, empty when disabled
+	DefaultLanguage            string // language of the error messages of the code
+	PortAPI                    int
+	PortMonitoring             int
+	LogLevel                   string
+	LogFormat                  string
+	AuthenticationServiceFake  bool
+	AuthenticationServiceURI   string
+	InsecureSkipVerify         bool
 }
 
 type Context struct {
 	db                    dao.Database
+	dbBackend             dao.Database            // the database without its decorators
+	datasources           map[string]dao.Database // the datasources without their decorators, by name
+	chaos                 *chaos.DatabaseChaos
 	authenticationService authentication.Service
 	validator             *validator.Validate
+	tenancy               dao.Tenancy
+	tenantHeader          string
+	tenantClaim           string
+	entityTTL             map[string]time.Duration
+	problemDetails        bool
+	problemTypeBaseURI    string
+	compression           middlewares.CompressionConfig
+	maxRequestBodySize    int64
+	catalog               *i18n.Catalog // nil when the translations are disabled
 }
 
-func NewHandlersContext(config *Config) *Context {
-	hc := &Context{}
+// NewHandlersContext opens the database and creates the services used by the handlers
+func NewHandlersContext(config *Config) (*Context, error) {
+	hc := &Context{
+		tenancy:            config.Tenancy,
+		tenantHeader:       config.TenantHeader,
+		tenantClaim:        config.TenantClaim,
+		entityTTL:          config.EntityTTL,
+		problemDetails:     config.ProblemDetails,
+		problemTypeBaseURI: config.ProblemTypeBaseURI,
+		compression:        config.Compression,
+		maxRequestBodySize: config.MaxRequestBodySize,
+	}
+
+	switch config.Tenancy {
+	case "", dao.TenancyShared, dao.TenancyIsolated:
+	default:
+		return nil, fmt.Errorf("unknown tenancy %q", config.Tenancy)
+	}
+	if config.Tenancy != "" && config.TenantHeader == "" && config.TenantClaim == "" {
+		return nil, errors.New("tenancy enabled without tenant header nor claim to resolve the tenant")
+	}
+
+	keyring, err := config.keyring()
+	if err != nil {
+		return nil, err
+	}
 
+	if config.TranslationsDir != "" {
+		hc.catalog, err = i18n.Load(config.TranslationsDir, config.DefaultLanguage)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	backend := ""
 	if config.Mock {
-		hc.db = dbMock.NewDatabaseMock()
-	} else if config.DBInMemory { // DAO IN MEMORY
-		hc.db = dbFake.NewDatabaseFake(config.DBInMemoryImportFile) // DAO IN MEMORY
-	} else if strings.HasPrefix(config.DBConnectionURI, "postgresql://") { // DAO PG
-		hc.db = postgresql.NewDatabasePostgreSQL(config.DBConnectionURI) // DAO PG
-	} else if strings.HasPrefix(config.DBConnectionURI, "mongodb") { // DAO MONGO
-		hc.db = mongodb.NewDatabaseMongoDB(config.DBConnectionURI, config.DBName) // DAO MONGO
+		hc.db, backend = dbMock.NewDatabaseMock(), "mock"
 	} else {
-		utils.GetLogger().Fatal("no db connection uri given or not handled, and no db in memory mode enabled, exiting")
+		connectionURI := config.DBConnectionURI
+		if config.DBInMemory { // DAO IN MEMORY
+			connectionURI = dbFake.URIPrefix + config.DBInMemorySnapshotFile // DAO IN MEMORY
+		} // DAO IN MEMORY
+		if connectionURI == "" {
+			return nil, errors.New("no db connection uri given, and no db in memory mode enabled")
+		}
+
+		hc.db, err = dao.Open(config.databaseOptions(connectionURI, config.DBReadConnectionURI, config.DBName))
+		if err != nil {
+			return nil, err
+		}
+		backend = dao.Scheme(connectionURI)
+	}
+
+	if config.DBSecondaryConnectionURI != "" {
+		secondary, err := dao.Open(config.databaseOptions(config.DBSecondaryConnectionURI, "", config.DBSecondaryName))
+		if err != nil {
+			closeDatabase(hc.db)
+			return nil, fmt.Errorf("secondary database: %v", err)
+		}
+		hc.db = dualwrite.NewDatabaseDualWrite(hc.db, secondary, config.DBShadowRead, ApplicationName, backend, dao.Scheme(config.DBSecondaryConnectionURI))
+	}
+
+	// the datasources without their decorators, by name: the default one, and the ones receiving the routed entities
+	datasources := map[string]dao.Database{routing.DefaultDatasource: hc.db}
+	for name, connectionURI := range config.DBDatasources {
+		if name == routing.DefaultDatasource {
+			closeDatabases(datasources)
+			return nil, fmt.Errorf("the datasource name %s is reserved to the db connection uri", name)
+		}
+		db, err := dao.Open(config.databaseOptions(connectionURI, "", ""))
+		if err != nil {
+			closeDatabases(datasources)
+			return nil, fmt.Errorf("datasource %s: %v", name, err)
+		}
+		datasources[name] = db
+	}
+	routed := len(datasources) > 1 || len(config.DBEntityDatasources) > 0
+
+	decorated := make(map[string]dao.Database, len(datasources))
+	for name, db := range datasources {
+		// with several datasources, their metrics are labeled with their name
+		label := backend
+		if routed {
+			label = name
+		}
+		if provider, ok := db.(dao.PoolStatsProvider); ok {
+			metrics.RegisterPoolStats(provider, ApplicationName, label)
+		}
+		if provider, ok := db.(dao.PurgeStatsProvider); ok {
+			metrics.RegisterPurgeStats(provider, ApplicationName, label)
+		}
+		if keyring != nil {
+			db = encryption.NewDatabaseEncryption(db, keyring)
+		}
+		if config.DBChaos {
+			if hc.chaos == nil {
+				hc.chaos = chaos.NewDatabaseChaos(db)
+				db = hc.chaos
+			} else {
+				db = hc.chaos.Decorate(db)
+			}
+		}
+		db = metrics.NewDatabaseMetrics(db, ApplicationName, label)
+		decorated[name] = resilience.NewDatabaseResilience(db, config.DBResilience, ApplicationName, label)
+	}
+
+	hc.datasources = datasources
+	hc.dbBackend, hc.db = datasources[routing.DefaultDatasource], decorated[routing.DefaultDatasource]
+	if routed {
+		backends, err := routing.NewDatabaseRouting(datasources, config.DBEntityDatasources)
+		if err != nil {
+			closeDatabases(datasources)
+			return nil, err
+		}
+		hc.dbBackend = backends
+		hc.db, _ = routing.NewDatabaseRouting(decorated, config.DBEntityDatasources)
 	}
 
 	if config.AuthenticationServiceFake {
@@ -78,7 +229,75 @@ func NewHandlersContext(config *Config) *Context {
 		})
 	}
 	hc.validator = validators.NewValidator()
-	return hc
+	return hc, nil
+}
+
+// expiresAt returns the expiry date of an entity created now without expiry date, nil if it has no time to live
+func (hc *Context) expiresAt(entity string) *time.Time {
+	ttl, ok := hc.entityTTL[entity]
+	if !ok || ttl <= 0 {
+		return nil
+	}
+	expiresAt := time.Now().Add(ttl)
+	return &expiresAt
+}
+
+// keptExpiresAt returns the expiry date of an entity updated without expiry date: the one it had, or the one of
+// an entity created now when it had none
+func (hc *Context) keptExpiresAt(entity string, previous *time.Time) *time.Time {
+	if previous != nil {
+		return previous
+	}
+	return hc.expiresAt(entity)
+}
+
+// databaseOptions returns the options given to the dao drivers to open the database of the given uri
+func (config *Config) databaseOptions(connectionURI, readConnectionURI, name string) dao.Options {
+	settings := []interface{}{
+		dbFake.Config{ImportFile: config.DBInMemoryImportFile, SnapshotInterval: config.DBInMemorySnapshotInterval}, // DAO IN MEMORY
+		config.DBPool,      // DAO PG
+		config.DBMongoPool, // DAO MONGO
+		dao.ExpiryConfig{PurgeInterval: config.DBPurgeInterval},
+	}
+	return dao.Options{
+		ConnectionURI:     connectionURI,
+		ReadConnectionURI: readConnectionURI,
+		Name:              name,
+		Tenancy:           config.Tenancy,
+		Settings:          append(settings, config.DBDriverSettings...),
+	}
+}
+
+// keyring returns the keyring of the encrypted fields, nil when no encryption key is given
+func (config *Config) keyring() (*encryption.Keyring, error) {
+	if len(config.EncryptionKeys) == 0 {
+		return nil, nil
+	}
+	return encryption.NewKeyring(config.EncryptionKeys, config.EncryptionKeyID)
+}
+
+// closeDatabases releases the given databases
+func closeDatabases(databases map[string]dao.Database) {
+	for _, db := range databases {
+		closeDatabase(db)
+	}
+}
+
+// closeDatabase releases the database, if it holds resources
+func closeDatabase(db dao.Database) {
+	if closer, ok := db.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			utils.GetLogger().WithError(err).Error("error while closing database")
+		}
+	}
+}
+
+// Close releases the resources of the context, it must be called when the application stops
+func (hc *Context) Close() error {
+	if closer, ok := hc.dbBackend.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
 }
 
 func NewMonitoringRouter(hc *Context) *gin.Engine {
@@ -89,7 +308,12 @@ func NewMonitoringRouter(hc *Context) *gin.Engine {
 
 	router.Use(gin.Recovery())
 	router.Use(middlewares.GetLoggerMiddleware())
+	router.Use(middlewares.GetCompressionMiddleware(hc.compression))
+	router.Use(middlewares.GetProblemMiddleware(hc.problemDetails, hc.problemTypeBaseURI))
+	router.Use(middlewares.GetLocalizationMiddleware(hc.catalog))
+	router.Use(middlewares.GetRequestBodyMiddleware(hc.maxRequestBodySize))
 	router.Use(middlewares.GetHTTPLoggerMiddleware())
+	router.Use(middlewares.GetErrorMiddleware())
 
 	public := router.Group("/")
 	public.Use(middlewares.GetCORSMiddlewareForOthersHTTPMethods())
@@ -103,11 +327,22 @@ func NewMonitoringRouter(hc *Context) *gin.Engine {
 	public.Handle(http.MethodGet, "/prometheus", gin.WrapH(promhttp.Handler()))
 	public.Handle(http.MethodOptions, "/prometheus", hc.GetOptionsHandler(httputils.AllowedHeaders, http.MethodGet))
 
-	if dbInMemory, ok := hc.db.(*dbFake.DatabaseFake); ok { // DAO IN MEMORY
-		// db in memory mode, add export endpoint // DAO IN MEMORY
-		public.Handle(http.MethodGet, "/export", func(c *gin.Context) { // DAO IN MEMORY
-			httputils.JSON(c.Writer, http.StatusOK, dbInMemory.Export()) // DAO IN MEMORY
-		}) // DAO IN MEMORY
+	if _, ok := hc.dbBackend.(dao.PoolStatsProvider); ok {
+		public.Handle(http.MethodGet, "/db/stats", hc.GetDatabaseStats)
+		public.Handle(http.MethodOptions, "/db/stats", hc.GetOptionsHandler(httputils.AllowedHeaders, http.MethodGet))
+	}
+
+	if hc.chaos != nil {
+		// chaos mode, add the endpoints to configure the faults injected in the database calls
+		public.Handle(http.MethodGet, "/chaos", hc.GetChaosConfig)
+		public.Handle(http.MethodPut, "/chaos", hc.UpdateChaosConfig)
+		public.Handle(http.MethodDelete, "/chaos", hc.DeleteChaosConfig)
+		public.Handle(http.MethodOptions, "/chaos", hc.GetOptionsHandler(httputils.AllowedHeaders, http.MethodGet, http.MethodPut, http.MethodDelete))
+	}
+
+	if dbInMemory, ok := hc.dbBackend.(*dbFake.DatabaseFake); ok { // DAO IN MEMORY
+		// db in memory mode, add export and import endpoints // DAO IN MEMORY
+		handleDatabaseFakeRoutes(hc, public, dbInMemory) // DAO IN MEMORY
 	} // DAO IN MEMORY
 
 	return router
@@ -122,7 +357,12 @@ func NewAPIRouter(hc *Context) *gin.Engine {
 	router.Use(gin.Recovery())
 	router.Use(middlewares.GetPrometheusMiddleware(ApplicationName))
 	router.Use(middlewares.GetLoggerMiddleware())
+	router.Use(middlewares.GetCompressionMiddleware(hc.compression))
+	router.Use(middlewares.GetProblemMiddleware(hc.problemDetails, hc.problemTypeBaseURI))
+	router.Use(middlewares.GetLocalizationMiddleware(hc.catalog))
+	router.Use(middlewares.GetRequestBodyMiddleware(hc.maxRequestBodySize))
 	router.Use(middlewares.GetHTTPLoggerMiddleware())
+	router.Use(middlewares.GetErrorMiddleware())
 
 	handleAPIRoutes(hc, router)
 	handleCORSRoutes(hc, router)
@@ -133,24 +373,25 @@ func NewAPIRouter(hc *Context) *gin.Engine {
 func handleCORSRoutes(hc *Context, router *gin.Engine) {
 	public := router.Group(baseURI)
 
-	// start: template routes
-	public.Handle(http.MethodOptions, "/templates", hc.GetOptionsHandler(httputils.AllowedHeaders, http.MethodGet, http.MethodPost))
-	public.Handle(http.MethodOptions, "/templates/:id", hc.GetOptionsHandler(httputils.AllowedHeaders, http.MethodGet, http.MethodPut, http.MethodDelete))
-	// end: template routes
+	for _, resource := range Resources() {
+		handleResourceCORSRoutes(hc, public, resource)
+	}
 }
 
 func handleAPIRoutes(hc *Context, router *gin.Engine) {
 	public := router.Group(baseURI)
 	public.Use(middlewares.GetCORSMiddlewareForOthersHTTPMethods())
+	public.Use(middlewares.GetContentNegotiationMiddleware())
 
 	secured := public.Group("/")
 	secured.Use(middleware.GetAuthenticationMiddleware(hc.authenticationService))
+	if hc.tenancy != "" {
+		secured.Use(middlewares.GetTenantMiddleware(hc.tenantHeader, hc.tenantClaim))
+	}
+	secured.Use(middlewares.GetCallerMiddleware())
 
-	// start: template routes
-	secured.Handle(http.MethodGet, "/templates", hc.GetAllTemplates)
-	secured.Handle(http.MethodPost, "/templates", hc.CreateTemplate)
-	secured.Handle(http.MethodGet, "/templates/:id", hc.GetTemplate)
-	secured.Handle(http.MethodPut, "/templates/:id", hc.UpdateTemplate)
-	secured.Handle(http.MethodDelete, "/templates/:id", hc.DeleteTemplate)
-	// end: template routes
+	// the registered resources, see RegisterResource
+	for _, resource := range Resources() {
+		handleResourceRoutes(hc, secured, resource)
+	}
 }
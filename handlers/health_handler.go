@@ -1,18 +1,45 @@
 package handlers
 
 import (
+	"context"
 	"net/http"
+	"time"
 
+	"github.com/adeo/turbine-go-api-skeleton/storage/dao"
 	"github.com/adeo/turbine-go-api-skeleton/utils/httputils"
 	"github.com/gin-gonic/gin"
 )
 
+const (
+	datasourceUp = "up"
+
+	healthCheckTimeout = 2 * time.Second
+)
+
 func (hc *Context) GetHealth(c *gin.Context) {
-	conf := map[string]string{
+	conf := map[string]interface{}{
 		"ApplicationName":      ApplicationName,
 		"ApplicationVersion":   ApplicationVersion,
 		"ApplicationGitHash":   ApplicationGitHash,
 		"ApplicationBuildDate": ApplicationBuildDate,
+		"Datasources":          hc.datasourcesHealth(c.Request.Context()),
 	}
 	httputils.JSON(c.Writer, http.StatusOK, conf)
 }
+
+// datasourcesHealth returns the status of each datasource: up, or the error of its ping
+func (hc *Context) datasourcesHealth(ctx context.Context) map[string]string {
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+
+	result := make(map[string]string, len(hc.datasources))
+	for name, db := range hc.datasources {
+		result[name] = datasourceUp
+		if pinger, ok := db.(dao.Pinger); ok {
+			if err := pinger.Ping(ctx); err != nil {
+				result[name] = err.Error()
+			}
+		}
+	}
+	return result
+}
@@ -1,13 +1,236 @@
 package handlers
 
 import (
+	"fmt"
 	"net/http"
+	"sync"
 
 	"github.com/adeo/turbine-go-api-skeleton/api"
+	"github.com/adeo/turbine-go-api-skeleton/storage/dao"
+	"github.com/adeo/turbine-go-api-skeleton/utils"
 	"github.com/adeo/turbine-go-api-skeleton/utils/httputils"
 	"github.com/gin-gonic/gin"
+	"gopkg.in/yaml.v2"
+)
+
+var (
+	openAPISchemaOnce sync.Once
+	openAPISchema     string
 )
 
 func (hc *Context) GetOpenAPISchema(c *gin.Context) {
-	httputils.YAML(c.Writer, http.StatusOK, api.OpenAPISchema)
+	// the resources are registered by init functions, before the first request
+	openAPISchemaOnce.Do(func() {
+		var err error
+		openAPISchema, err = withResourcePaths(api.OpenAPISchema, Resources())
+		if err != nil {
+			utils.GetLogger().WithError(err).Error("unable to add the paths of the resources to the openapi schema")
+			openAPISchema = api.OpenAPISchema
+		}
+	})
+	httputils.YAML(c.Writer, http.StatusOK, openAPISchema)
+}
+
+// withResourcePaths returns the schema completed with the paths of the resources, their models being described
+// by the schemas generated from their @openapi:schema comments
+func withResourcePaths(schema string, resources []Resource) (string, error) {
+	if len(resources) == 0 {
+		return schema, nil
+	}
+
+	var document yaml.MapSlice
+	if err := yaml.Unmarshal([]byte(schema), &document); err != nil {
+		return "", fmt.Errorf("reading openapi schema: %w", err)
+	}
+	pathsIndex := -1
+	paths := yaml.MapSlice{}
+	for i, item := range document {
+		if item.Key == "paths" {
+			pathsIndex = i
+			if existing, ok := item.Value.(yaml.MapSlice); ok {
+				paths = existing
+			}
+		}
+	}
+	for _, resource := range resources {
+		entity, err := dao.LookupEntity(resource.Entity)
+		if err != nil {
+			return "", err
+		}
+		paths = append(paths, resourcePaths(resource, entity)...)
+	}
+	if pathsIndex < 0 {
+		document = append(document, yaml.MapItem{Key: "paths", Value: paths})
+	} else {
+		document[pathsIndex].Value = paths
+	}
+
+	b, err := yaml.Marshal(document)
+	if err != nil {
+		return "", fmt.Errorf("writing openapi schema: %w", err)
+	}
+	return string(b), nil
+}
+
+// resourcePaths returns the openapi paths of the CRUD routes of a resource
+func resourcePaths(resource Resource, entity dao.Entity) yaml.MapSlice {
+	name := entity.Type.Name()
+	model := "#/components/schemas/" + name
+	editable := model
+	if entity.Editable != nil {
+		editable = "#/components/schemas/" + entity.Editable.Name()
+	}
+	collection := resource.Path[1:]
+	tags := []string{collection}
+
+	id := yaml.MapSlice{
+		{Key: "in", Value: "path"},
+		{Key: "name", Value: "id"},
+		{Key: "schema", Value: yaml.MapSlice{{Key: "type", Value: "string"}}},
+		{Key: "required", Value: true},
+		{Key: "description", Value: "The " + entity.Name + " id"},
+	}
+	ifMatch := yaml.MapSlice{
+		{Key: "in", Value: "header"},
+		{Key: "name", Value: "If-Match"},
+		{Key: "schema", Value: yaml.MapSlice{{Key: "type", Value: "string"}}},
+		{Key: "required", Value: true},
+		{Key: "description", Value: "The " + entity.Name + " version to update, given by the ETag header of the GET endpoint"},
+	}
+	body := yaml.MapSlice{
+		{Key: "description", Value: "The " + entity.Name + " data."},
+		{Key: "required", Value: true},
+		{Key: "content", Value: requestContent(yaml.MapSlice{{Key: "$ref", Value: editable}})},
+	}
+	one := yaml.MapSlice{{Key: "$ref", Value: model}}
+	list := yaml.MapSlice{{Key: "type", Value: "array"}, {Key: "items", Value: one}}
+
+	return yaml.MapSlice{
+		{Key: baseURI + resource.Path, Value: yaml.MapSlice{
+			{Key: "get", Value: yaml.MapSlice{
+				{Key: "tags", Value: tags},
+				{Key: "description", Value: "Get all the " + collection},
+				{Key: "responses", Value: responses([]openAPIResponse{
+					{http.StatusOK, "The array containing the " + collection, resourceContent(list, true)},
+					{http.StatusNotAcceptable, "None of the formats of the Accept header can be written", nil},
+					{http.StatusInternalServerError, "Server error", nil},
+					{http.StatusServiceUnavailable, "The database is temporarily unavailable", nil},
+				})},
+			}},
+			{Key: "post", Value: yaml.MapSlice{
+				{Key: "tags", Value: tags},
+				{Key: "description", Value: "Create a new " + entity.Name},
+				{Key: "requestBody", Value: body},
+				{Key: "responses", Value: responses([]openAPIResponse{
+					{http.StatusCreated, "The created " + entity.Name, resourceContent(one, false)},
+					{http.StatusBadRequest, "The request is not correct (bad body format, validation error)", nil},
+					{http.StatusNotAcceptable, "None of the formats of the Accept header can be written", nil},
+					{http.StatusConflict, "The new entity is in conflict with an existing one (duplicated)", nil},
+					{http.StatusRequestEntityTooLarge, "The body is larger than allowed", nil},
+					{http.StatusUnsupportedMediaType, "The Content-Type of the body can not be read", nil},
+					{http.StatusInternalServerError, "Server error", nil},
+					{http.StatusServiceUnavailable, "The database is temporarily unavailable", nil},
+				})},
+			}},
+		}},
+		{Key: baseURI + resource.Path + "/{id}", Value: yaml.MapSlice{
+			{Key: "get", Value: yaml.MapSlice{
+				{Key: "tags", Value: tags},
+				{Key: "description", Value: "Get a " + entity.Name},
+				{Key: "parameters", Value: []interface{}{id}},
+				{Key: "responses", Value: responses([]openAPIResponse{
+					{http.StatusOK, "The " + entity.Name + " with the id", resourceContent(one, false)},
+					{http.StatusNotFound, name + " not found", nil},
+					{http.StatusNotAcceptable, "None of the formats of the Accept header can be written", nil},
+					{http.StatusInternalServerError, "Server error", nil},
+					{http.StatusServiceUnavailable, "The database is temporarily unavailable", nil},
+				})},
+			}},
+			{Key: "put", Value: yaml.MapSlice{
+				{Key: "tags", Value: tags},
+				{Key: "description", Value: "Update a " + entity.Name},
+				{Key: "parameters", Value: []interface{}{id, ifMatch}},
+				{Key: "requestBody", Value: body},
+				{Key: "responses", Value: responses([]openAPIResponse{
+					{http.StatusOK, "The updated " + entity.Name, resourceContent(one, false)},
+					{http.StatusBadRequest, "The request is not correct (bad body format, validation error)", nil},
+					{http.StatusNotFound, name + " not found", nil},
+					{http.StatusNotAcceptable, "None of the formats of the Accept header can be written", nil},
+					{http.StatusConflict, "The entity is in conflict with an existing one (duplicated)", nil},
+					{http.StatusPreconditionFailed, "The version given by If-Match is not the current one", nil},
+					{http.StatusRequestEntityTooLarge, "The body is larger than allowed", nil},
+					{http.StatusUnsupportedMediaType, "The Content-Type of the body can not be read", nil},
+					{http.StatusInternalServerError, "Server error", nil},
+					{http.StatusServiceUnavailable, "The database is temporarily unavailable", nil},
+				})},
+			}},
+			{Key: "delete", Value: yaml.MapSlice{
+				{Key: "tags", Value: tags},
+				{Key: "description", Value: "Delete a " + entity.Name},
+				{Key: "parameters", Value: []interface{}{id}},
+				{Key: "responses", Value: responses([]openAPIResponse{
+					{http.StatusNoContent, name + " deleted", nil},
+					{http.StatusNotFound, name + " not found", nil},
+					{http.StatusNotAcceptable, "None of the formats of the Accept header can be written", nil},
+					{http.StatusInternalServerError, "Server error", nil},
+					{http.StatusServiceUnavailable, "The database is temporarily unavailable", nil},
+				})},
+			}},
+		}},
+	}
+}
+
+// openAPIResponse is a response of an operation, the errors without content being described by the APIError schema
+type openAPIResponse struct {
+	status      int
+	description string
+	content     yaml.MapSlice
+}
+
+// responses returns the responses of an operation, by status
+func responses(all []openAPIResponse) yaml.MapSlice {
+	result := yaml.MapSlice{}
+	for _, r := range all {
+		content := r.content
+		if content == nil && r.status >= http.StatusBadRequest {
+			// the errors are always written in JSON
+			content = yaml.MapSlice{{Key: "application/json", Value: yaml.MapSlice{
+				{Key: "schema", Value: yaml.MapSlice{{Key: "$ref", Value: "#/components/schemas/APIError"}}},
+			}}}
+		}
+		response := yaml.MapSlice{{Key: "description", Value: r.description}}
+		if content != nil {
+			response = append(response, yaml.MapItem{Key: "content", Value: content})
+		}
+		result = append(result, yaml.MapItem{Key: r.status, Value: response})
+	}
+	return result
+}
+
+// requestContent returns the content of a request body of the given schema, in each readable format
+func requestContent(schema yaml.MapSlice) yaml.MapSlice {
+	content := yaml.MapSlice{}
+	for _, mediaType := range httputils.RequestMediaTypes() {
+		content = append(content, yaml.MapItem{Key: mediaType, Value: yaml.MapSlice{{Key: "schema", Value: schema}}})
+	}
+	return content
+}
+
+// resourceContent returns the content of a response of the given schema, a resource or a collection of them, in
+// each format negotiated with the Accept header. The CSV and hypermedia documents have their own structure.
+func resourceContent(schema yaml.MapSlice, collection bool) yaml.MapSlice {
+	content := yaml.MapSlice{}
+	for _, mediaType := range httputils.ResponseMediaTypes(collection) {
+		s := schema
+		switch mediaType {
+		case "text/csv":
+			s = yaml.MapSlice{{Key: "type", Value: "string"}, {Key: "description", Value: "A header line of the JSON names of the fields, then a line per resource"}}
+		case httputils.HeaderValueApplicationHALJSON:
+			s = yaml.MapSlice{{Key: "type", Value: "object"}, {Key: "description", Value: "The HAL document, with the _links of the resources"}}
+		case httputils.HeaderValueApplicationJSONAPI:
+			s = yaml.MapSlice{{Key: "type", Value: "object"}, {Key: "description", Value: "The JSON:API document, the fields being the attributes of its data"}}
+		}
+		content = append(content, yaml.MapItem{Key: mediaType, Value: yaml.MapSlice{{Key: "schema", Value: s}}})
+	}
+	return content
 }
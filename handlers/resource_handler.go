@@ -0,0 +1,258 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/adeo/turbine-go-api-skeleton/middlewares"
+	"github.com/adeo/turbine-go-api-skeleton/storage/dao"
+	"github.com/adeo/turbine-go-api-skeleton/storage/model"
+	"github.com/adeo/turbine-go-api-skeleton/storage/validators"
+	"github.com/adeo/turbine-go-api-skeleton/utils/httputils"
+	"github.com/gin-gonic/gin"
+)
+
+// Resource exposes an entity registered with dao.RegisterEntity through the CRUD routes of its path: list and
+// create on the path, get, update and delete on the path followed by the id. Its operations are overridden by
+// its hooks.
+type Resource struct {
+	Entity string // name of the registered entity
+	Path   string // path of the collection, relative to the base uri, eg. /products
+	Hooks  Hooks
+}
+
+// Hook overrides an operation of a resource. It runs instead of the default operation, given as next, which it
+// calls to keep the default behavior, eg. after checking the request or before changing the response.
+type Hook func(hc *Context, c *gin.Context, next HandlerFunc) (int, interface{}, error)
+
+// Hooks are the hooks of the operations of a resource, nil to keep the default operation
+type Hooks struct {
+	List   Hook
+	Get    Hook
+	Create Hook
+	Update Hook
+	Delete Hook
+}
+
+var (
+	resourcesMu sync.RWMutex
+	resources   = make(map[string]Resource) // by path
+)
+
+// RegisterResource adds the routes of the resource to the API router, and links them from the hypermedia
+// representations of its entity. It is meant to be called from an init function, and panics if the entity is
+// not registered or if the path is already used.
+func RegisterResource(resource Resource) {
+	if _, err := dao.LookupEntity(resource.Entity); err != nil {
+		panic("handlers: RegisterResource: " + err.Error())
+	}
+	if !strings.HasPrefix(resource.Path, "/") {
+		panic("handlers: RegisterResource " + resource.Entity + ": the path must start with /")
+	}
+
+	resourcesMu.Lock()
+	defer resourcesMu.Unlock()
+	if _, exists := resources[resource.Path]; exists {
+		panic("handlers: RegisterResource called twice for path " + resource.Path)
+	}
+	resources[resource.Path] = resource
+}
+
+// Resources returns the registered resources, sorted by path
+func Resources() []Resource {
+	resourcesMu.RLock()
+	defer resourcesMu.RUnlock()
+	result := make([]Resource, 0, len(resources))
+	for _, resource := range resources {
+		result = append(result, resource)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Path < result[j].Path })
+	return result
+}
+
+// resourceHandler runs the default operations of a resource
+type resourceHandler struct {
+	hc       *Context
+	resource Resource
+	entity   dao.Entity
+}
+
+func newResourceHandler(hc *Context, resource Resource) *resourceHandler {
+	// the entity has been checked by RegisterResource
+	entity, _ := dao.LookupEntity(resource.Entity)
+	return &resourceHandler{hc: hc, resource: resource, entity: entity}
+}
+
+// handle returns the gin handler of the operation, run through its hook if any
+func (h *resourceHandler) handle(hook Hook, operation HandlerFunc) gin.HandlerFunc {
+	if hook == nil {
+		return Handle(operation)
+	}
+	return Handle(func(c *gin.Context) (int, interface{}, error) {
+		return hook(h.hc, c, operation)
+	})
+}
+
+// title names the entity in the error descriptions, eg. Product
+func (h *resourceHandler) title() string {
+	return h.entity.Type.Name()
+}
+
+func (h *resourceHandler) list(c *gin.Context) (int, interface{}, error) {
+	values, err := h.hc.db.GetAllEntities(c.Request.Context(), h.entity.Name)
+	if err != nil {
+		return 0, nil, fmt.Errorf("getting %s: %w", h.entity.Name, err)
+	}
+	return http.StatusOK, h.entity.Slice(values), nil
+}
+
+func (h *resourceHandler) create(c *gin.Context) (int, interface{}, error) {
+	editable, err := h.bind(c)
+	if err != nil {
+		return 0, nil, fmt.Errorf("reading %s to create: %w", h.entity.Name, err)
+	}
+
+	v := h.entity.New()
+	h.entity.SetEditable(v, editable)
+	if h.entity.ExpiresAt(v) == nil {
+		h.entity.SetExpiresAt(v, h.hc.expiresAt(h.entity.Name))
+	}
+
+	err = h.hc.db.CreateEntity(c.Request.Context(), h.entity.Name, v)
+	if errors.Is(err, dao.ErrDuplicate) {
+		return 0, nil, newAPIError(model.ErrAlreadyExists, h.title()+" already exists")
+	} else if err != nil {
+		return 0, nil, fmt.Errorf("creating %s: %w", h.entity.Name, err)
+	}
+
+	c.Writer.Header().Set(httputils.HeaderNameLocation, fmt.Sprintf("%s%s/%s", baseURI, h.resource.Path, h.entity.ID(v)))
+	return http.StatusCreated, v, nil
+}
+
+func (h *resourceHandler) get(c *gin.Context) (int, interface{}, error) {
+	c.Set(middlewares.ContextKeyPrometheusURI, baseURI+h.resource.Path+"/:id")
+
+	v, err := h.getByID(c, h.title()+" not found")
+	if err != nil {
+		return 0, nil, err
+	}
+	return http.StatusOK, v, nil
+}
+
+func (h *resourceHandler) update(c *gin.Context) (int, interface{}, error) {
+	c.Set(middlewares.ContextKeyPrometheusURI, baseURI+h.resource.Path+"/:id")
+
+	// check the id given in URL exists
+	v, err := h.getByID(c, h.title()+" to update not found")
+	if err != nil {
+		return 0, nil, err
+	}
+
+	// check versions
+	if !httputils.MatchesVersion(c.GetHeader(httputils.HeaderNameIfMatch), v) {
+		apiErr := model.ErrVersionMismatched
+		return 0, nil, &apiErr
+	}
+
+	editable, err := h.bind(c)
+	if err != nil {
+		return 0, nil, fmt.Errorf("reading %s to update: %w", h.entity.Name, err)
+	}
+	previous := h.entity.ExpiresAt(v)
+	h.entity.SetEditable(v, editable)
+	if h.entity.ExpiresAt(v) == nil {
+		h.entity.SetExpiresAt(v, h.hc.keptExpiresAt(h.entity.Name, previous))
+	}
+
+	err = h.hc.db.UpdateEntity(c.Request.Context(), h.entity.Name, v)
+	if errors.Is(err, dao.ErrNotFound) {
+		return 0, nil, newAPIError(model.ErrNotFound, h.title()+" to update not found")
+	} else if errors.Is(err, dao.ErrDuplicate) {
+		return 0, nil, newAPIError(model.ErrAlreadyExists, h.title()+" already exists")
+	} else if err != nil {
+		return 0, nil, fmt.Errorf("updating %s: %w", h.entity.Name, err)
+	}
+	return http.StatusOK, v, nil
+}
+
+func (h *resourceHandler) delete(c *gin.Context) (int, interface{}, error) {
+	c.Set(middlewares.ContextKeyPrometheusURI, baseURI+h.resource.Path+"/:id")
+
+	// check the id given in URL exists
+	v, err := h.getByID(c, h.title()+" to delete not found")
+	if err != nil {
+		return 0, nil, err
+	}
+
+	err = h.hc.db.DeleteEntity(c.Request.Context(), h.entity.Name, h.entity.ID(v))
+	if errors.Is(err, dao.ErrNotFound) {
+		return 0, nil, newAPIError(model.ErrNotFound, h.title()+" to delete not found")
+	} else if err != nil {
+		return 0, nil, fmt.Errorf("deleting %s: %w", h.entity.Name, err)
+	}
+	return http.StatusNoContent, nil, nil
+}
+
+// bind reads and validates the editable fields of the body
+func (h *resourceHandler) bind(c *gin.Context) (interface{}, error) {
+	editable := h.entity.NewEditable()
+	if err := httputils.Bind(c, editable); err != nil {
+		return nil, err
+	}
+	err := h.hc.validator.StructCtx(validators.NewContextWithValidationContext(c.Request.Context(), h.hc.db), editable)
+	if err != nil {
+		return nil, err
+	}
+	return editable, nil
+}
+
+// getByID returns the entity of the id of the path, or the not found error with the given description
+func (h *resourceHandler) getByID(c *gin.Context, notFound string) (interface{}, error) {
+	id := c.Param("id")
+
+	err := h.hc.validator.VarCtx(c, id, "required")
+	if err != nil {
+		return nil, err
+	}
+
+	v, err := h.hc.db.GetEntityByID(c.Request.Context(), h.entity.Name, id)
+	if errors.Is(err, dao.ErrNotFound) || err == nil && v == nil {
+		return nil, newAPIError(model.ErrNotFound, notFound)
+	} else if err != nil {
+		return nil, fmt.Errorf("getting %s %s: %w", h.entity.Name, id, err)
+	}
+	return v, nil
+}
+
+// registerHypermedia links the CRUD routes of the path in the group, the collection and its items by id, from the
+// hypermedia representations of the model of value. It is called with the routes added to the router, so that the
+// links are the actual routes.
+func registerHypermedia(group *gin.RouterGroup, path string, value interface{}) {
+	collection := strings.TrimSuffix(group.BasePath(), "/") + path
+	httputils.RegisterResource(value, httputils.Resource{
+		Type:       strings.TrimPrefix(path, "/"),
+		Collection: collection,
+		Item:       collection + "/:id",
+	})
+}
+
+// handleResourceRoutes adds the CRUD routes of the resource
+func handleResourceRoutes(hc *Context, group *gin.RouterGroup, resource Resource) {
+	h := newResourceHandler(hc, resource)
+	registerHypermedia(group, resource.Path, h.entity.New())
+	group.Handle(http.MethodGet, resource.Path, h.handle(resource.Hooks.List, h.list))
+	group.Handle(http.MethodPost, resource.Path, h.handle(resource.Hooks.Create, h.create))
+	group.Handle(http.MethodGet, resource.Path+"/:id", h.handle(resource.Hooks.Get, h.get))
+	group.Handle(http.MethodPut, resource.Path+"/:id", h.handle(resource.Hooks.Update, h.update))
+	group.Handle(http.MethodDelete, resource.Path+"/:id", h.handle(resource.Hooks.Delete, h.delete))
+}
+
+// handleResourceCORSRoutes adds the OPTIONS routes of the resource
+func handleResourceCORSRoutes(hc *Context, group *gin.RouterGroup, resource Resource) {
+	group.Handle(http.MethodOptions, resource.Path, hc.GetOptionsHandler(httputils.AllowedHeaders, http.MethodGet, http.MethodPost))
+	group.Handle(http.MethodOptions, resource.Path+"/:id", hc.GetOptionsHandler(httputils.AllowedHeaders, http.MethodGet, http.MethodPut, http.MethodDelete))
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adeo/turbine-go-api-skeleton/middlewares"
+	"github.com/adeo/turbine-go-api-skeleton/storage/dao/daotest"
+	"github.com/adeo/turbine-go-api-skeleton/storage/dao/fake"
+	"github.com/adeo/turbine-go-api-skeleton/storage/validators"
+	"github.com/adeo/turbine-go-api-skeleton/utils/httputils"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestRouter returns a context on an in memory database, with the given times to live, and a router writing
+// the errors of its handlers
+func newTestRouter(entityTTL map[string]time.Duration) (*Context, *gin.Engine) {
+	hc := &Context{
+		db:        fake.NewDatabaseFake("", "", 0),
+		validator: validators.NewValidator(),
+		entityTTL: entityTTL,
+	}
+	router := gin.New()
+	router.Use(middlewares.GetErrorMiddleware())
+	return hc, router
+}
+
+// serveJSON sends a request with the given JSON body to the router, and decodes the body of its response in v.
+// The PUT requests are sent with the version of the resource, read on the same path.
+func serveJSON(t *testing.T, router *gin.Engine, method, path, body string, expectedStatus int, v interface{}) {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set(httputils.HeaderNameContentType, "application/json")
+	w := httptest.NewRecorder()
+	if method == http.MethodPut {
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		req.Header.Set(httputils.HeaderNameIfMatch, w.Header().Get(httputils.HeaderNameETag))
+		w = httptest.NewRecorder()
+	}
+	router.ServeHTTP(w, req)
+	require.Equal(t, expectedStatus, w.Code, w.Body.String())
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), v))
+}
+
+func TestResourceUpdateExpiry(t *testing.T) {
+	hc, router := newTestRouter(map[string]time.Duration{daotest.EntityItem: time.Hour})
+	handleResourceRoutes(hc, router.Group(baseURI), Resource{Entity: daotest.EntityItem, Path: "/items"})
+
+	var created, updated daotest.Item
+	serveJSON(t, router, http.MethodPost, "/items", `{"name": "item-1"}`, http.StatusCreated, &created)
+	require.NotNil(t, created.ExpiresAt)
+
+	// an update without expiry date keeps the one of the item
+	serveJSON(t, router, http.MethodPut, "/items/"+created.ID, `{"name": "item-2"}`, http.StatusOK, &updated)
+	assert.Equal(t, "item-2", updated.Name)
+	require.NotNil(t, updated.ExpiresAt, "the item must still expire")
+	assert.True(t, created.ExpiresAt.Equal(*updated.ExpiresAt))
+
+	// an update with an expiry date replaces it
+	serveJSON(t, router, http.MethodPut, "/items/"+created.ID, `{"name": "item-2", "expires_at": "2100-01-01T00:00:00Z"}`, http.StatusOK, &updated)
+	require.NotNil(t, updated.ExpiresAt)
+	assert.Equal(t, 2100, updated.ExpiresAt.Year())
+}
@@ -0,0 +1,9 @@
+package handlers
+
+func init() {
+	// the CRUD routes of the templates, override their operations with Hooks
+	RegisterResource(Resource{
+		Entity: "template",
+		Path:   "/templates",
+	})
+}
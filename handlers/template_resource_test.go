@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/adeo/turbine-go-api-skeleton/storage/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdateTemplateExpiry(t *testing.T) {
+	hc, router := newTestRouter(map[string]time.Duration{"template": time.Hour})
+	for _, resource := range Resources() {
+		if resource.Entity == "template" {
+			handleResourceRoutes(hc, router.Group(baseURI), resource)
+		}
+	}
+
+	var created, updated model.Template
+	serveJSON(t, router, http.MethodPost, "/templates", `{"name": "template-1"}`, http.StatusCreated, &created)
+	require.NotNil(t, created.ExpiresAt)
+
+	// an update without expiry date keeps the one of the template
+	serveJSON(t, router, http.MethodPut, "/templates/"+created.ID, `{"name": "template-2"}`, http.StatusOK, &updated)
+	assert.Equal(t, "template-2", updated.Name)
+	require.NotNil(t, updated.ExpiresAt, "the template must still expire")
+	assert.True(t, created.ExpiresAt.Equal(*updated.ExpiresAt))
+
+	// a template created before its time to live gets one
+	unexpiring := &model.Template{TemplateEditable: model.TemplateEditable{Name: "template-3"}}
+	require.NoError(t, hc.db.CreateEntity(context.Background(), "template", unexpiring))
+	serveJSON(t, router, http.MethodPut, "/templates/"+unexpiring.ID, `{"name": "template-3"}`, http.StatusOK, &updated)
+	require.NotNil(t, updated.ExpiresAt)
+	assert.True(t, updated.ExpiresAt.After(time.Now().Add(59*time.Minute)))
+}
@@ -0,0 +1,28 @@
+package middlewares
+
+import (
+	"github.com/adeo/turbine-go-api-skeleton/storage/dao"
+	"github.com/gin-gonic/gin"
+)
+
+// callerClaims are the claims of the authenticated identity naming the caller, by preference
+var callerClaims = []string{"sub", "client_id"}
+
+// GetCallerMiddleware stores the caller of the request in the gin context and in the context of the request, where
+// the database reads it to send the reads following its writes to the primary: the subject or client id of the
+// authenticated identity, or the client IP for the anonymous requests.
+func GetCallerMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		caller := ""
+		for _, claim := range callerClaims {
+			if caller = identityClaim(c, claim); caller != "" {
+				break
+			}
+		}
+		if caller == "" {
+			caller = c.ClientIP()
+		}
+		c.Set(dao.ContextKeyCaller, caller)
+		c.Request = c.Request.WithContext(dao.WithCaller(c.Request.Context(), caller))
+	}
+}
@@ -0,0 +1,37 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/adeo/turbine-go-api-skeleton/storage/dao"
+	"github.com/adeo/turbine-go-api-skeleton/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCallerMiddleware(t *testing.T) {
+	tests := []struct {
+		name           string
+		identity       map[string]string
+		expectedCaller string
+	}{
+		{name: "subject", identity: map[string]string{"sub": "user-1", "client_id": "client-1"}, expectedCaller: "user-1"},
+		{name: "client", identity: map[string]string{"client_id": "client-1"}, expectedCaller: "client-1"},
+		{name: "anonymous", expectedCaller: "192.0.2.1"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodGet, "/templates", nil)
+			if test.identity != nil {
+				c.Set(utils.ContextKeyAuthIntrospect, test.identity)
+			}
+
+			GetCallerMiddleware()(c)
+			assert.Equal(t, test.expectedCaller, dao.CallerFromContext(c))
+			assert.Equal(t, test.expectedCaller, dao.CallerFromContext(c.Request.Context()))
+		})
+	}
+}
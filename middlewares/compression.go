@@ -0,0 +1,220 @@
+package middlewares
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/adeo/turbine-go-api-skeleton/utils/httputils"
+	"github.com/andybalholm/brotli"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	encodingBrotli   = "br"
+	encodingGzip     = "gzip"
+	encodingDeflate  = "deflate"
+	encodingIdentity = "identity"
+)
+
+// DefaultCompressedContentTypes are the media types of the compressed responses by default, text/* matching all
+// the text media types
+var DefaultCompressedContentTypes = []string{
+	"application/json",
+	"application/problem+json",
+	"application/hal+json",
+	"application/vnd.api+json",
+	"application/x-yaml",
+	"application/yaml",
+	"application/xml",
+	"text/*",
+}
+
+// CompressionConfig configures the compression of the responses
+type CompressionConfig struct {
+	MinSize      int      // responses smaller than this size in bytes are not compressed, negative disables the compression
+	ContentTypes []string // media types of the compressed responses, eg. application/json or text/*
+}
+
+// encoders are the supported encodings of the responses, by order of preference of the server
+var encoders = []struct {
+	name string
+	pool *sync.Pool
+}{
+	{name: encodingBrotli, pool: &sync.Pool{New: func() interface{} { return brotli.NewWriterLevel(nil, brotli.DefaultCompression) }}},
+	{name: encodingGzip, pool: &sync.Pool{New: func() interface{} { return gzip.NewWriter(nil) }}},
+	{name: encodingDeflate, pool: &sync.Pool{New: func() interface{} {
+		w, _ := flate.NewWriter(nil, flate.DefaultCompression)
+		return w
+	}}},
+}
+
+// encoder is the interface shared by the writers of the encodings
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+// GetCompressionMiddleware compresses the responses with the encoding negotiated with the Accept-Encoding header,
+// when they are large enough and of a compressed content type. It must run before the middlewares replacing the
+// response writer, eg. the problem middleware.
+func GetCompressionMiddleware(config CompressionConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if config.MinSize < 0 {
+			return
+		}
+
+		c.Writer.Header().Add(httputils.HeaderNameVary, httputils.HeaderNameAcceptEncoding)
+		name, pool := negotiateEncoding(c.GetHeader(httputils.HeaderNameAcceptEncoding))
+		if pool == nil {
+			return
+		}
+		w := &compressWriter{ResponseWriter: c.Writer, config: config, encoding: name, pool: pool}
+		c.Writer = w
+		defer w.close()
+		c.Next()
+	}
+}
+
+// negotiateEncoding returns the preferred encoding of the Accept-Encoding header, nil if the response must not
+// be compressed
+func negotiateEncoding(acceptEncoding string) (string, *sync.Pool) {
+	qualities := make(map[string]float64)
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		fields := strings.Split(part, ";")
+		name := strings.ToLower(strings.TrimSpace(fields[0]))
+		if name == "" {
+			continue
+		}
+		q := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if value, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = value
+				}
+			}
+		}
+		qualities[name] = q
+	}
+
+	var best *sync.Pool
+	bestName, bestQ := "", 0.0
+	for _, e := range encoders {
+		q, ok := qualities[e.name]
+		if !ok {
+			q = qualities["*"]
+		}
+		if q > bestQ {
+			best, bestName, bestQ = e.pool, e.name, q
+		}
+	}
+	return bestName, best
+}
+
+// compressWriter buffers the beginning of the response until it knows if the response must be compressed
+type compressWriter struct {
+	gin.ResponseWriter
+	config   CompressionConfig
+	encoding string
+	pool     *sync.Pool
+	buffer   []byte
+	decided  bool
+	encoder  encoder
+}
+
+func (w *compressWriter) Write(data []byte) (int, error) {
+	if !w.decided {
+		w.buffer = append(w.buffer, data...)
+		if len(w.buffer) < w.config.MinSize {
+			return len(data), nil
+		}
+		return len(data), w.decide()
+	}
+	if w.encoder != nil {
+		return w.encoder.Write(data)
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *compressWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// WriteHeaderNow does not write the headers before the compression is decided
+func (w *compressWriter) WriteHeaderNow() {
+	if w.decided {
+		w.ResponseWriter.WriteHeaderNow()
+	}
+}
+
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		_ = w.decide()
+	}
+	if w.encoder != nil {
+		_ = w.encoder.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+// decide compresses the response if it is large enough and of a compressed content type, then writes the buffer
+func (w *compressWriter) decide() error {
+	w.decided = true
+	if w.compressible() {
+		header := w.Header()
+		header.Set(httputils.HeaderNameContentEncoding, w.encoding)
+		header.Del(httputils.HeaderNameContentLength)
+		w.encoder = w.pool.Get().(encoder)
+		w.encoder.Reset(w.ResponseWriter)
+	}
+
+	buffer := w.buffer
+	w.buffer = nil
+	if len(buffer) == 0 {
+		return nil // the headers are written by gin after the handlers
+	}
+	if w.encoder != nil {
+		_, err := w.encoder.Write(buffer)
+		return err
+	}
+	_, err := w.ResponseWriter.Write(buffer)
+	return err
+}
+
+func (w *compressWriter) compressible() bool {
+	status := w.Status()
+	if len(w.buffer) == 0 || len(w.buffer) < w.config.MinSize ||
+		status < http.StatusOK || status == http.StatusNoContent || status == http.StatusNotModified ||
+		w.Header().Get(httputils.HeaderNameContentEncoding) != "" {
+		return false
+	}
+	mediaType, _, err := mime.ParseMediaType(w.Header().Get(httputils.HeaderNameContentType))
+	if err != nil {
+		return false
+	}
+	for _, allowed := range w.config.ContentTypes {
+		if allowed == mediaType || strings.HasSuffix(allowed, "/*") && strings.HasPrefix(mediaType, allowed[:len(allowed)-1]) {
+			return true
+		}
+	}
+	return false
+}
+
+// close writes the end of the response, once the handlers have returned
+func (w *compressWriter) close() {
+	if !w.decided {
+		_ = w.decide()
+	}
+	if w.encoder != nil {
+		_ = w.encoder.Close()
+		w.pool.Put(w.encoder)
+		w.encoder = nil
+	}
+}
@@ -0,0 +1,87 @@
+package middlewares
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/adeo/turbine-go-api-skeleton/utils/httputils"
+	"github.com/andybalholm/brotli"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testCompressionConfig = CompressionConfig{
+	MinSize:      100,
+	ContentTypes: DefaultCompressedContentTypes,
+}
+
+// runCompressionMiddleware serves a response of the given content type and size through the middleware
+func runCompressionMiddleware(acceptEncoding, contentType string, size int) *httptest.ResponseRecorder {
+	gin.SetMode(gin.ReleaseMode)
+	router := gin.New()
+	router.Use(GetCompressionMiddleware(testCompressionConfig))
+	router.GET("/templates", func(c *gin.Context) {
+		c.Writer.Header().Set(httputils.HeaderNameContentType, contentType)
+		c.Writer.WriteHeader(http.StatusOK)
+		c.Writer.Write([]byte(strings.Repeat("a", size/2)))
+		c.Writer.WriteString(strings.Repeat("a", size-size/2))
+	})
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/templates", nil)
+	if acceptEncoding != "" {
+		r.Header.Set(httputils.HeaderNameAcceptEncoding, acceptEncoding)
+	}
+	router.ServeHTTP(w, r)
+	return w
+}
+
+func TestCompressionMiddleware(t *testing.T) {
+	tests := []struct {
+		name             string
+		acceptEncoding   string
+		contentType      string
+		size             int
+		expectedEncoding string
+	}{
+		{name: "gzip", acceptEncoding: "gzip", contentType: httputils.HeaderValueApplicationJSONUTF8, size: 1000, expectedEncoding: "gzip"},
+		{name: "preferred brotli", acceptEncoding: "gzip, deflate, br", contentType: "text/csv", size: 1000, expectedEncoding: "br"},
+		{name: "client preference", acceptEncoding: "br;q=0.5, deflate", contentType: "text/csv", size: 1000, expectedEncoding: "deflate"},
+		{name: "wildcard", acceptEncoding: "*", contentType: "text/csv", size: 1000, expectedEncoding: "br"},
+		{name: "refused", acceptEncoding: "br;q=0, gzip;q=0, deflate;q=0", contentType: "text/csv", size: 1000},
+		{name: "no accept encoding", contentType: httputils.HeaderValueApplicationJSONUTF8, size: 1000},
+		{name: "small response", acceptEncoding: "gzip", contentType: httputils.HeaderValueApplicationJSONUTF8, size: 50},
+		{name: "not compressed content type", acceptEncoding: "gzip", contentType: httputils.HeaderValueApplicationMsgPack, size: 1000},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := runCompressionMiddleware(test.acceptEncoding, test.contentType, test.size)
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, test.expectedEncoding, w.Header().Get(httputils.HeaderNameContentEncoding))
+
+			var body []byte
+			var err error
+			switch test.expectedEncoding {
+			case "gzip":
+				var r *gzip.Reader
+				r, err = gzip.NewReader(w.Body)
+				require.NoError(t, err)
+				body, err = ioutil.ReadAll(r)
+			case "deflate":
+				body, err = ioutil.ReadAll(flate.NewReader(w.Body))
+			case "br":
+				body, err = ioutil.ReadAll(brotli.NewReader(w.Body))
+			default:
+				body = w.Body.Bytes()
+			}
+			require.NoError(t, err)
+			assert.Equal(t, strings.Repeat("a", test.size), string(body))
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package middlewares
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/adeo/turbine-go-api-skeleton/storage/dao"
+	"github.com/adeo/turbine-go-api-skeleton/storage/model"
+	"github.com/adeo/turbine-go-api-skeleton/storage/validators"
+	"github.com/adeo/turbine-go-api-skeleton/utils"
+	"github.com/adeo/turbine-go-api-skeleton/utils/httputils"
+	"github.com/gin-gonic/gin"
+	"gopkg.in/go-playground/validator.v9"
+)
+
+// ErrorMapper returns the error to send for an error of a handler, false if it does not know the error
+type ErrorMapper func(err error) (model.APIError, bool)
+
+var (
+	errorMappersMutex sync.RWMutex
+	errorMappers      []ErrorMapper
+)
+
+// defaultErrorMappers know the errors of the skeleton, they are tried after the registered ones
+var defaultErrorMappers = []ErrorMapper{
+	mapAPIError,
+	httputils.BindAPIError,
+	mapValidationError,
+	mapDAOError,
+	mapContextError,
+}
+
+// RegisterErrorMapper adds a mapper of the errors of the project, eg. of its services. The mappers are tried in
+// their order of registration, before the ones of the skeleton.
+func RegisterErrorMapper(mapper ErrorMapper) {
+	errorMappersMutex.Lock()
+	defer errorMappersMutex.Unlock()
+	errorMappers = append(errorMappers, mapper)
+}
+
+// MapError returns the error to send for an error of a handler, an internal server error for the unknown errors
+func MapError(err error) model.APIError {
+	errorMappersMutex.RLock()
+	mappers := append(append([]ErrorMapper(nil), errorMappers...), defaultErrorMappers...)
+	errorMappersMutex.RUnlock()
+
+	for _, mapper := range mappers {
+		if apiErr, ok := mapper(err); ok {
+			return apiErr
+		}
+	}
+	return model.ErrInternalServer
+}
+
+// GetErrorMiddleware writes the last error added to the context by the handlers, mapped by MapError, if they did
+// not write their response. The server errors are logged as errors, the other ones as debug. It must run after
+// the middlewares replacing the response writer, and after the HTTP logger middleware to log the error status.
+func GetErrorMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		last := c.Errors.Last()
+		if last == nil || c.Writer.Written() {
+			return
+		}
+		apiErr := MapError(last.Err)
+
+		logger := utils.GetLoggerFromCtx(c).WithError(last.Err).WithField("type", apiErr.Type)
+		if apiErr.HTTPCode >= http.StatusInternalServerError {
+			logger.Error("error while handling request")
+		} else {
+			logger.Debug("request rejected")
+		}
+		httputils.JSONError(c.Writer, apiErr)
+	}
+}
+
+// mapAPIError returns the errors of the handlers which are already an APIError
+func mapAPIError(err error) (model.APIError, bool) {
+	var apiErr *model.APIError
+	if errors.As(err, &apiErr) {
+		return *apiErr, true
+	}
+	return model.APIError{}, false
+}
+
+func mapValidationError(err error) (model.APIError, bool) {
+	var validationErrors validator.ValidationErrors
+	if errors.As(err, &validationErrors) {
+		return validators.NewDataValidationAPIError(validationErrors), true
+	}
+	return model.APIError{}, false
+}
+
+// mapDAOError returns the errors of the database which do not depend on the entity of the call
+func mapDAOError(err error) (model.APIError, bool) {
+	e, ok := dao.AsDAOError(err)
+	if !ok {
+		return model.APIError{}, false
+	}
+	switch e.Type {
+	case dao.ErrTypeNotFound, dao.ErrTypeInvalidID:
+		// an id which can't be stored by the database is the one of no entity
+		return model.ErrNotFound, true
+	case dao.ErrTypeDuplicate:
+		return model.ErrAlreadyExists, true
+	case dao.ErrTypeUnavailable:
+		return newServiceUnavailableAPIError(e), true
+	case dao.ErrTypeTimeout:
+		return model.ErrTimeout, true
+	case dao.ErrTypeCanceled:
+		return model.ErrRequestCanceled, true
+	case dao.ErrTypeConflict:
+		return model.ErrConflict, true
+	case dao.ErrTypeForeignKeyViolation:
+		return model.ErrReferenceConflict, true
+	case dao.ErrTypeConstraintViolation:
+		apiErr := model.ErrDataValidation
+		if e.Field != "" {
+			apiErr.Details = []model.FieldError{{
+				Field:       e.Field,
+				Constraint:  "database",
+				Description: "the value is rejected by a constraint of the database",
+			}}
+		}
+		return apiErr, true
+	}
+	return model.APIError{}, false
+}
+
+// newServiceUnavailableAPIError returns the error to send when the database is unavailable,
+// telling the client when to retry if the delay is known
+func newServiceUnavailableAPIError(e *dao.DAOError) model.APIError {
+	apiErr := model.ErrServiceUnavailable
+	if e.RetryAfter > 0 {
+		seconds := int(math.Ceil(e.RetryAfter.Seconds()))
+		apiErr.Headers = map[string][]string{
+			httputils.HeaderNameRetryAfter: {strconv.Itoa(seconds)},
+		}
+	}
+	return apiErr
+}
+
+// mapContextError returns the errors of the context of the request not returned by the database
+func mapContextError(err error) (model.APIError, bool) {
+	switch {
+	case errors.Is(err, context.Canceled):
+		return model.ErrRequestCanceled, true
+	case errors.Is(err, context.DeadlineExceeded):
+		return model.ErrTimeout, true
+	}
+	return model.APIError{}, false
+}
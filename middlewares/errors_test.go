@@ -0,0 +1,93 @@
+package middlewares
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/adeo/turbine-go-api-skeleton/storage/dao"
+	"github.com/adeo/turbine-go-api-skeleton/storage/model"
+	"github.com/adeo/turbine-go-api-skeleton/utils/httputils"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/go-playground/validator.v9"
+)
+
+var errProject = errors.New("project error")
+
+func init() {
+	RegisterErrorMapper(func(err error) (model.APIError, bool) {
+		if errors.Is(err, errProject) {
+			return model.ErrConflict, true
+		}
+		return model.APIError{}, false
+	})
+}
+
+func TestMapError(t *testing.T) {
+	notFound := model.ErrNotFound
+	notFound.Description = "Template not found"
+	var validationErr struct {
+		Name string `validate:"required"`
+	}
+
+	tests := []struct {
+		name           string
+		err            error
+		expectedStatus int
+		expectedType   string
+	}{
+		{name: "api error", err: &notFound, expectedStatus: http.StatusNotFound, expectedType: "not_found"},
+		{name: "dao invalid id", err: dao.NewDAOError(dao.ErrTypeInvalidID, nil), expectedStatus: http.StatusNotFound, expectedType: "not_found"},
+		{name: "bind error", err: fmt.Errorf("reading: %w", httputils.ErrBodyTooLarge), expectedStatus: http.StatusRequestEntityTooLarge, expectedType: "request_entity_too_large"},
+		{name: "validation error", err: validator.New().Struct(validationErr), expectedStatus: http.StatusBadRequest, expectedType: "data_validation"},
+		{name: "dao not found", err: dao.NewDAOError(dao.ErrTypeNotFound, nil), expectedStatus: http.StatusNotFound, expectedType: "not_found"},
+		{name: "dao duplicate", err: fmt.Errorf("creating: %w", dao.NewDAOError(dao.ErrTypeDuplicate, nil)), expectedStatus: http.StatusConflict, expectedType: "already_exists"},
+		{name: "dao unavailable", err: dao.NewDAOError(dao.ErrTypeUnavailable, nil), expectedStatus: http.StatusServiceUnavailable, expectedType: "service_unavailable"},
+		{name: "dao foreign key", err: dao.NewDAOError(dao.ErrTypeForeignKeyViolation, nil), expectedStatus: http.StatusConflict, expectedType: "reference_conflict"},
+		{name: "canceled", err: context.Canceled, expectedStatus: 499, expectedType: "request_canceled"},
+		{name: "deadline", err: fmt.Errorf("calling: %w", context.DeadlineExceeded), expectedStatus: http.StatusGatewayTimeout, expectedType: "timeout"},
+		{name: "registered", err: fmt.Errorf("calling: %w", errProject), expectedStatus: http.StatusConflict, expectedType: "conflict"},
+		{name: "unknown", err: errors.New("unknown"), expectedStatus: http.StatusInternalServerError, expectedType: "internal_server_error"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			apiErr := MapError(test.err)
+			assert.Equal(t, test.expectedStatus, apiErr.HTTPCode)
+			assert.Equal(t, test.expectedType, apiErr.Type)
+		})
+	}
+
+	unavailable := &dao.DAOError{Type: dao.ErrTypeUnavailable, RetryAfter: 1500 * time.Millisecond}
+	assert.Equal(t, []string{"2"}, MapError(unavailable).Headers[httputils.HeaderNameRetryAfter])
+}
+
+func TestErrorMiddleware(t *testing.T) {
+	tests := []struct {
+		name           string
+		handler        gin.HandlerFunc
+		expectedStatus int
+	}{
+		{name: "no error", handler: func(c *gin.Context) { c.Status(http.StatusNoContent) }, expectedStatus: http.StatusNoContent},
+		{name: "error", handler: func(c *gin.Context) { _ = c.Error(dao.NewDAOError(dao.ErrTypeNotFound, nil)) }, expectedStatus: http.StatusNotFound},
+		{name: "error after the response", handler: func(c *gin.Context) {
+			c.String(http.StatusAccepted, "accepted")
+			_ = c.Error(errors.New("unknown"))
+		}, expectedStatus: http.StatusAccepted},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			router := gin.New()
+			router.Use(GetErrorMiddleware())
+			router.GET("/templates", test.handler)
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/templates", nil))
+			assert.Equal(t, test.expectedStatus, w.Code)
+		})
+	}
+}
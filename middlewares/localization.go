@@ -0,0 +1,20 @@
+package middlewares
+
+import (
+	"github.com/adeo/turbine-go-api-skeleton/utils/httputils"
+	"github.com/adeo/turbine-go-api-skeleton/utils/i18n"
+	"github.com/gin-gonic/gin"
+)
+
+// GetLocalizationMiddleware makes the errors of the request written in the language of the catalog negotiated
+// with the Accept-Language header, nothing being translated without catalog
+func GetLocalizationMiddleware(catalog *i18n.Catalog) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if catalog == nil {
+			return
+		}
+		c.Writer.Header().Add(httputils.HeaderNameVary, httputils.HeaderNameAcceptLanguage)
+		language := catalog.Negotiate(c.GetHeader(httputils.HeaderNameAcceptLanguage))
+		c.Writer = httputils.NewLocalizedWriter(c.Writer, catalog, language)
+	}
+}
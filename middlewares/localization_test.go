@@ -0,0 +1,46 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/adeo/turbine-go-api-skeleton/storage/model"
+	"github.com/adeo/turbine-go-api-skeleton/utils/httputils"
+	"github.com/adeo/turbine-go-api-skeleton/utils/i18n"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocalizationMiddleware(t *testing.T) {
+	catalog := i18n.NewCatalog("en", map[string]i18n.Messages{
+		"fr": {Errors: map[string]string{"not_found": "La ressource n'existe pas"}},
+	})
+
+	tests := []struct {
+		name                string
+		catalog             *i18n.Catalog
+		acceptLanguage      string
+		expectedLanguage    string
+		expectedDescription string
+	}{
+		{name: "no catalog", acceptLanguage: "fr", expectedDescription: "Template not found"},
+		{name: "translated", catalog: catalog, acceptLanguage: "fr-FR, en;q=0.5", expectedLanguage: "fr", expectedDescription: "La ressource n'existe pas"},
+		{name: "default language", catalog: catalog, acceptLanguage: "it", expectedLanguage: "en", expectedDescription: "Template not found"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodGet, "/templates/1", nil)
+			c.Request.Header.Set(httputils.HeaderNameAcceptLanguage, test.acceptLanguage)
+
+			GetLocalizationMiddleware(test.catalog)(c)
+			httputils.JSONErrorWithMessage(c.Writer, model.ErrNotFound, "Template not found")
+
+			assert.Equal(t, http.StatusNotFound, w.Code)
+			assert.Equal(t, test.expectedLanguage, w.Header().Get(httputils.HeaderNameContentLanguage))
+			assert.Contains(t, w.Body.String(), test.expectedDescription)
+		})
+	}
+}
@@ -47,6 +47,7 @@ func GetLoggerMiddleware() gin.HandlerFunc {
 		logger := utils.GetLogger()
 		logEntry := logger.WithField(httputils.HeaderNameCorrelationID, correlationID)
 
+		c.Set(utils.ContextKeyCorrelationID, correlationID)
 		c.Set(utils.ContextKeyLogger, logEntry)
 	}
 }
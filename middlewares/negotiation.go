@@ -0,0 +1,29 @@
+package middlewares
+
+import (
+	"github.com/adeo/turbine-go-api-skeleton/storage/model"
+	"github.com/adeo/turbine-go-api-skeleton/utils/httputils"
+	"github.com/gin-gonic/gin"
+)
+
+// GetContentNegotiationMiddleware rejects the requests accepting none of the supported formats, and the ones
+// having a body of an unsupported Content-Type, before any work is done for them. The errors of the requests
+// preferring JSON:API documents are written as JSON:API errors.
+func GetContentNegotiationMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		accept := c.GetHeader(httputils.HeaderNameAccept)
+		if httputils.PrefersJSONAPI(accept) {
+			c.Writer = httputils.NewJSONAPIWriter(c.Writer)
+		}
+		if !httputils.IsAcceptable(accept) {
+			httputils.JSONError(c.Writer, model.ErrNotAcceptable)
+			c.Abort()
+			return
+		}
+		if c.Request.ContentLength != 0 && !httputils.IsReadable(c.GetHeader(httputils.HeaderNameContentType)) {
+			httputils.JSONError(c.Writer, model.ErrUnsupportedMediaType)
+			c.Abort()
+			return
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/adeo/turbine-go-api-skeleton/utils/httputils"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContentNegotiationMiddleware(t *testing.T) {
+	tests := []struct {
+		name            string
+		accept          string
+		contentType     string
+		body            string
+		expectedStatus  int
+		expectedJSONAPI bool
+	}{
+		{name: "no headers", expectedStatus: http.StatusOK},
+		{name: "json", accept: "application/json", contentType: "application/json", body: "{}", expectedStatus: http.StatusOK},
+		{name: "yaml", accept: "application/yaml", contentType: "application/x-yaml", body: "name: a", expectedStatus: http.StatusOK},
+		{name: "wildcard", accept: "text/html, */*;q=0.1", expectedStatus: http.StatusOK},
+		{name: "unsupported accept", accept: "text/html", expectedStatus: http.StatusNotAcceptable},
+		{name: "refused format", accept: "application/json;q=0", expectedStatus: http.StatusNotAcceptable},
+		{name: "unsupported content type", contentType: "text/plain", body: "name", expectedStatus: http.StatusUnsupportedMediaType},
+		{name: "csv body", contentType: "text/csv", body: "name\na", expectedStatus: http.StatusUnsupportedMediaType},
+		{name: "content type without body", contentType: "text/plain", expectedStatus: http.StatusOK},
+		{name: "json api", accept: "application/vnd.api+json", expectedStatus: http.StatusOK, expectedJSONAPI: true},
+		{name: "json api error", accept: "application/vnd.api+json", contentType: "text/plain", body: "name", expectedStatus: http.StatusUnsupportedMediaType, expectedJSONAPI: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodPost, "/templates", strings.NewReader(test.body))
+			if test.accept != "" {
+				c.Request.Header.Set(httputils.HeaderNameAccept, test.accept)
+			}
+			if test.contentType != "" {
+				c.Request.Header.Set(httputils.HeaderNameContentType, test.contentType)
+			}
+
+			GetContentNegotiationMiddleware()(c)
+			status := http.StatusOK
+			if c.IsAborted() {
+				status = w.Code
+			}
+			assert.Equal(t, test.expectedStatus, status)
+			_, jsonAPI := c.Writer.(*httputils.JSONAPIWriter)
+			assert.Equal(t, test.expectedJSONAPI, jsonAPI)
+		})
+	}
+}
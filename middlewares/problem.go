@@ -0,0 +1,20 @@
+package middlewares
+
+import (
+	"github.com/adeo/turbine-go-api-skeleton/utils"
+	"github.com/adeo/turbine-go-api-skeleton/utils/httputils"
+	"github.com/gin-gonic/gin"
+)
+
+// GetProblemMiddleware makes the errors of the request written as RFC 7807 problems, for all the requests when
+// enabled, otherwise for the requests accepting application/problem+json. It must run after the logger
+// middleware, the correlation id of the request being part of the problem instance.
+func GetProblemMiddleware(enabled bool, typeBaseURI string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !enabled && !httputils.AcceptsProblem(c.GetHeader(httputils.HeaderNameAccept)) {
+			return
+		}
+		instance := httputils.ProblemInstance(c.Request.URL.Path, c.GetString(utils.ContextKeyCorrelationID))
+		c.Writer = httputils.NewProblemWriter(c.Writer, typeBaseURI, instance)
+	}
+}
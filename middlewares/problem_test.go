@@ -0,0 +1,65 @@
+package middlewares
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/adeo/turbine-go-api-skeleton/storage/model"
+	"github.com/adeo/turbine-go-api-skeleton/utils"
+	"github.com/adeo/turbine-go-api-skeleton/utils/httputils"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// runProblemMiddleware writes a validation error for a request accepting the given types
+func runProblemMiddleware(enabled bool, accept string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/templates", nil)
+	if accept != "" {
+		c.Request.Header.Set(httputils.HeaderNameAccept, accept)
+	}
+	c.Set(utils.ContextKeyCorrelationID, "abc")
+
+	GetProblemMiddleware(enabled, "https://errors.example.com/")(c)
+	apiErr := model.ErrDataValidation
+	apiErr.Details = []model.FieldError{{Field: "name", Constraint: "required", Description: "name is required"}}
+	httputils.JSONError(c.Writer, apiErr)
+	return w
+}
+
+func TestProblemMiddleware(t *testing.T) {
+	expected := model.Problem{
+		Type:     "https://errors.example.com/data_validation",
+		Title:    "Bad Request",
+		Status:   http.StatusBadRequest,
+		Detail:   "the data are not valid",
+		Instance: "/templates#abc",
+		Errors:   []model.FieldError{{Field: "name", Constraint: "required", Description: "name is required"}},
+	}
+
+	for name, w := range map[string]*httptest.ResponseRecorder{
+		"enabled":  runProblemMiddleware(true, ""),
+		"accepted": runProblemMiddleware(false, "application/json, application/problem+json"),
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Equal(t, httputils.HeaderValueApplicationProblemJSON, w.Header().Get(httputils.HeaderNameContentType))
+			var actual model.Problem
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &actual))
+			assert.Equal(t, expected, actual)
+		})
+	}
+
+	t.Run("legacy", func(t *testing.T) {
+		w := runProblemMiddleware(false, "application/json")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, httputils.HeaderValueApplicationJSONUTF8, w.Header().Get(httputils.HeaderNameContentType))
+		var actual model.APIError
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &actual))
+		assert.Equal(t, "data_validation", actual.Type)
+	})
+}
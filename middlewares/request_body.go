@@ -0,0 +1,75 @@
+package middlewares
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/adeo/turbine-go-api-skeleton/storage/model"
+	"github.com/adeo/turbine-go-api-skeleton/utils/httputils"
+	"github.com/gin-gonic/gin"
+)
+
+// GetRequestBodyMiddleware limits the size of the request bodies to maxSize bytes, 0 for no limit, and
+// decompresses the gzip bodies. The bodies announcing a larger size are rejected, the other ones fail with
+// httputils.ErrBodyTooLarge once the limit is read, see httputils.Bind.
+func GetRequestBodyMiddleware(maxSize int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if maxSize > 0 && c.Request.ContentLength > maxSize {
+			httputils.JSONError(c.Writer, model.ErrRequestEntityTooLarge)
+			c.Abort()
+			return
+		}
+
+		body := &limitedBody{reader: c.Request.Body, body: c.Request.Body, limit: maxSize}
+		encoding := strings.ToLower(strings.TrimSpace(c.GetHeader(httputils.HeaderNameContentEncoding)))
+		switch encoding {
+		case "", encodingIdentity:
+		case encodingGzip:
+			reader, err := gzip.NewReader(c.Request.Body)
+			if err != nil {
+				httputils.JSONError(c.Writer, model.ErrBadRequestFormat)
+				c.Abort()
+				return
+			}
+			body.reader, body.decompressed = reader, true
+			c.Request.ContentLength = -1
+			c.Request.Header.Del(httputils.HeaderNameContentEncoding)
+			c.Request.Header.Del(httputils.HeaderNameContentLength)
+		default:
+			httputils.JSONErrorWithMessage(c.Writer, model.ErrUnsupportedMediaType, fmt.Sprintf("the %s Content-Encoding is not supported, please use gzip", encoding))
+			c.Abort()
+			return
+		}
+		c.Request.Body = body
+	}
+}
+
+// limitedBody is a request body failing with httputils.ErrBodyTooLarge after its limit
+type limitedBody struct {
+	reader       io.Reader
+	body         io.Closer
+	limit        int64
+	read         int64
+	decompressed bool // the errors of the decompression are errors of the body, not of the connection
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	n, err := b.reader.Read(p)
+	b.read += int64(n)
+	if b.limit > 0 && b.read > b.limit {
+		return n, httputils.ErrBodyTooLarge
+	}
+	if b.decompressed && err != nil && err != io.EOF {
+		err = fmt.Errorf("%w: %v", httputils.ErrInvalidBody, err)
+	}
+	return n, err
+}
+
+func (b *limitedBody) Close() error {
+	if closer, ok := b.reader.(io.Closer); ok && b.decompressed {
+		closer.Close()
+	}
+	return b.body.Close()
+}
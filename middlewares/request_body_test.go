@@ -0,0 +1,68 @@
+package middlewares
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/adeo/turbine-go-api-skeleton/utils/httputils"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func gzipped(t *testing.T, data string) *bytes.Buffer {
+	var buffer bytes.Buffer
+	w := gzip.NewWriter(&buffer)
+	_, err := w.Write([]byte(data))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	return &buffer
+}
+
+func TestRequestBodyMiddleware(t *testing.T) {
+	tests := []struct {
+		name            string
+		contentEncoding string
+		body            *bytes.Buffer
+		expectedStatus  int
+		expectedBody    string
+	}{
+		{name: "plain", body: bytes.NewBufferString(`{"name":"a"}`), expectedStatus: http.StatusOK, expectedBody: "a"},
+		{name: "gzip", contentEncoding: "gzip", body: gzipped(t, `{"name":"a"}`), expectedStatus: http.StatusOK, expectedBody: "a"},
+		{name: "too large", body: bytes.NewBufferString(`{"name":"` + strings.Repeat("a", 2000) + `"}`), expectedStatus: http.StatusRequestEntityTooLarge},
+		{name: "too large once decompressed", contentEncoding: "gzip", body: gzipped(t, `{"name":"`+strings.Repeat("a", 2000)+`"}`), expectedStatus: http.StatusRequestEntityTooLarge},
+		{name: "invalid gzip", contentEncoding: "gzip", body: bytes.NewBufferString("not gzip"), expectedStatus: http.StatusBadRequest},
+		{name: "unsupported encoding", contentEncoding: "br", body: bytes.NewBufferString("a"), expectedStatus: http.StatusUnsupportedMediaType},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			gin.SetMode(gin.ReleaseMode)
+			router := gin.New()
+			router.Use(GetRequestBodyMiddleware(1000))
+			router.POST("/templates", func(c *gin.Context) {
+				var v struct {
+					Name string `json:"name"`
+				}
+				if err := httputils.Bind(c, &v); httputils.BindError(c.Writer, err) {
+					return
+				}
+				c.String(http.StatusOK, v.Name)
+			})
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/templates", test.body)
+			if test.contentEncoding != "" {
+				r.Header.Set(httputils.HeaderNameContentEncoding, test.contentEncoding)
+			}
+			router.ServeHTTP(w, r)
+			assert.Equal(t, test.expectedStatus, w.Code)
+			if test.expectedBody != "" {
+				assert.Equal(t, test.expectedBody, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package middlewares
+
+import (
+	"encoding/json"
+
+	"github.com/adeo/turbine-go-api-skeleton/storage/dao"
+	"github.com/adeo/turbine-go-api-skeleton/storage/model"
+	"github.com/adeo/turbine-go-api-skeleton/utils"
+	"github.com/adeo/turbine-go-api-skeleton/utils/httputils"
+	"github.com/gin-gonic/gin"
+)
+
+// GetTenantMiddleware resolves the tenant of the request and stores it in the gin context and in the context of
+// the request, where the database reads it to scope its calls. The tenant is read from the claim field of the authenticated identity, and from
+// the given trusted header: a request giving a header different from the tenant of its identity is rejected.
+// An empty header or claim disables the corresponding source.
+func GetTenantMiddleware(header, claim string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tenant := identityClaim(c, claim)
+		if header != "" {
+			requested := c.GetHeader(header)
+			if tenant != "" && requested != "" && requested != tenant {
+				httputils.JSONError(c.Writer, model.ErrTenantMismatch)
+				c.Abort()
+				return
+			}
+			if tenant == "" {
+				tenant = requested
+			}
+		}
+
+		if tenant == "" {
+			httputils.JSONError(c.Writer, model.ErrTenantRequired)
+			c.Abort()
+			return
+		}
+		if !dao.IsValidTenant(tenant) {
+			httputils.JSONError(c.Writer, model.ErrTenantInvalid)
+			c.Abort()
+			return
+		}
+
+		c.Set(dao.ContextKeyTenant, tenant)
+		c.Request = c.Request.WithContext(dao.WithTenant(c.Request.Context(), tenant))
+		c.Set(utils.ContextKeyLogger, utils.GetLoggerFromCtx(c).WithField("tenant", tenant))
+	}
+}
+
+// identityClaim returns the claim field of the introspection of the authenticated identity, if any
+func identityClaim(c *gin.Context, claim string) string {
+	identity, ok := c.Get(utils.ContextKeyAuthIntrospect)
+	if !ok || claim == "" {
+		return ""
+	}
+
+	data, err := json.Marshal(identity)
+	if err != nil {
+		return ""
+	}
+	fields := map[string]interface{}{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return ""
+	}
+	value, _ := fields[claim].(string)
+	return value
+}
@@ -0,0 +1,61 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/adeo/turbine-go-api-skeleton/storage/dao"
+	"github.com/adeo/turbine-go-api-skeleton/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+const testTenantHeader = "X-Tenant-ID"
+
+// runTenantMiddleware runs the middleware on a request with the given header and identity tenants,
+// and returns the response status and the tenant stored in the context
+func runTenantMiddleware(header, identity string) (int, string) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/templates", nil)
+	if header != "" {
+		c.Request.Header.Set(testTenantHeader, header)
+	}
+	if identity != "" {
+		c.Set(utils.ContextKeyAuthIntrospect, map[string]string{"tenant": identity})
+	}
+
+	GetTenantMiddleware(testTenantHeader, "tenant")(c)
+	if c.IsAborted() {
+		return w.Code, ""
+	}
+	if tenant := dao.TenantFromContext(c); tenant != dao.TenantFromContext(c.Request.Context()) {
+		return http.StatusInternalServerError, tenant
+	}
+	return http.StatusOK, dao.TenantFromContext(c)
+}
+
+func TestTenantMiddleware(t *testing.T) {
+	tests := []struct {
+		name           string
+		header         string
+		identity       string
+		expectedStatus int
+		expectedTenant string
+	}{
+		{name: "identity", identity: "tenant-a", expectedStatus: http.StatusOK, expectedTenant: "tenant-a"},
+		{name: "header", header: "tenant-b", expectedStatus: http.StatusOK, expectedTenant: "tenant-b"},
+		{name: "same tenants", header: "tenant-a", identity: "tenant-a", expectedStatus: http.StatusOK, expectedTenant: "tenant-a"},
+		{name: "header of another tenant", header: "tenant-b", identity: "tenant-a", expectedStatus: http.StatusForbidden},
+		{name: "no tenant", expectedStatus: http.StatusForbidden},
+		{name: "invalid tenant", header: "../admin", expectedStatus: http.StatusBadRequest},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			status, tenant := runTenantMiddleware(test.header, test.identity)
+			assert.Equal(t, test.expectedStatus, status)
+			assert.Equal(t, test.expectedTenant, tenant)
+		})
+	}
+}
@@ -0,0 +1,23 @@
+package dao
+
+import "context"
+
+const (
+	// ContextKeyCaller is the key of the caller of the request in the context given to the database
+	ContextKeyCaller = "ContextKeyCaller"
+)
+
+// WithCaller returns a copy of the given context giving the caller of the database calls, eg. the subject of the
+// authenticated identity. The databases with read replicas send the reads of a caller to the primary just after
+// its writes.
+func WithCaller(ctx context.Context, caller string) context.Context {
+	return context.WithValue(ctx, ContextKeyCaller, caller)
+}
+
+// CallerFromContext returns the caller of the database call, empty when unknown
+func CallerFromContext(ctx context.Context) string {
+	if caller, ok := ctx.Value(ContextKeyCaller).(string); ok {
+		return caller
+	}
+	return ""
+}
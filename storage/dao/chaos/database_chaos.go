@@ -0,0 +1,127 @@
+// Package chaos provides a dao.Database decorator injecting faults, to test how the application
+// and its clients behave when the database is slow or failing.
+package chaos
+
+import (
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/adeo/turbine-go-api-skeleton/storage/dao"
+)
+
+const (
+	// AllMethods is the rule method matching all the dao methods
+	AllMethods = "*"
+	// ErrorTypeGeneric is the rule error type injecting an error which is not a *dao.DAOError
+	ErrorTypeGeneric = "generic"
+)
+
+// ErrInjected is the cause of all the injected errors
+var ErrInjected = errors.New("chaos: injected error")
+
+// Rule describes the faults to inject when calling a dao method
+type Rule struct {
+	// Method is the dao method name (eg. GetAllEntities/template), or AllMethods
+	Method string `json:"method" validate:"required"`
+	// Probability is the probability, between 0 and 1, to apply this rule on each call
+	Probability float64 `json:"probability" validate:"min=0,max=1"`
+	// LatencyMS is the latency to add to the call, in milliseconds
+	LatencyMS int `json:"latency_ms" validate:"min=0"`
+	// ErrorType is the type of the error to return instead of calling the database: one of the
+	// dao error types (eg. not_found), or ErrorTypeGeneric. The database is called when empty.
+	ErrorType string `json:"error_type" validate:"omitempty,oneof=generic not_found duplicate foreign_key_violation unavailable conflict timeout constraint_violation canceled invalid_id"`
+}
+
+// Config is the list of rules applied by DatabaseChaos
+type Config struct {
+	Rules []Rule `json:"rules" validate:"dive"`
+}
+
+type DatabaseChaos struct {
+	db dao.Database
+	*rules
+}
+
+// rules are the rules applied by a DatabaseChaos, they can be shared by the decorators of several databases
+type rules struct {
+	mu     sync.Mutex
+	config Config
+	random *rand.Rand
+}
+
+// NewDatabaseChaos returns a decorator of the given database, without any rule
+func NewDatabaseChaos(db dao.Database) *DatabaseChaos {
+	return &DatabaseChaos{
+		db: db,
+		rules: &rules{
+			config: Config{Rules: make([]Rule, 0)},
+			random: rand.New(rand.NewSource(time.Now().UnixNano())),
+		},
+	}
+}
+
+// Decorate returns a decorator of another database, applying the same rules as this one
+func (db *DatabaseChaos) Decorate(other dao.Database) *DatabaseChaos {
+	return &DatabaseChaos{db: other, rules: db.rules}
+}
+
+func (db *DatabaseChaos) GetConfig() Config {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	rules := make([]Rule, len(db.config.Rules))
+	copy(rules, db.config.Rules)
+	return Config{Rules: rules}
+}
+
+// SetConfig replaces the rules applied, the config must have been validated before
+func (db *DatabaseChaos) SetConfig(config Config) {
+	rules := make([]Rule, len(config.Rules))
+	copy(rules, config.Rules)
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.config = Config{Rules: rules}
+}
+
+// inject applies the rules matching the given method: it waits for the latencies to inject,
+// and returns the error to return instead of calling the database, if any
+func (db *DatabaseChaos) inject(method string) error {
+	latency, errorType := db.draw(method)
+
+	time.Sleep(latency)
+
+	switch {
+	case errorType == "":
+		return nil
+	case errorType == ErrorTypeGeneric:
+		return ErrInjected
+	default:
+		t, _ := dao.ParseType(errorType)
+		return dao.NewDAOError(t, ErrInjected)
+	}
+}
+
+// draw returns the total latency and the first error type of the rules applied to this call
+func (db *DatabaseChaos) draw(method string) (time.Duration, string) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	var latency time.Duration
+	errorType := ""
+	for _, rule := range db.config.Rules {
+		if rule.Method != method && rule.Method != AllMethods {
+			continue
+		}
+		if db.random.Float64() >= rule.Probability {
+			continue
+		}
+		latency += time.Duration(rule.LatencyMS) * time.Millisecond
+		if errorType == "" {
+			errorType = rule.ErrorType
+		}
+	}
+	return latency, errorType
+}
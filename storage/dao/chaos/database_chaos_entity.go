@@ -0,0 +1,56 @@
+package chaos
+
+import (
+	"context"
+
+	"github.com/adeo/turbine-go-api-skeleton/storage/dao"
+)
+
+func (db *DatabaseChaos) GetAllEntities(ctx context.Context, entity string) ([]interface{}, error) {
+	if err := db.inject(dao.EntityMethod("GetAllEntities", entity)); err != nil {
+		return nil, err
+	}
+	return db.db.GetAllEntities(ctx, entity)
+}
+
+func (db *DatabaseChaos) GetEntityByID(ctx context.Context, entity, id string) (interface{}, error) {
+	if err := db.inject(dao.EntityMethod("GetEntityByID", entity)); err != nil {
+		return nil, err
+	}
+	return db.db.GetEntityByID(ctx, entity, id)
+}
+
+func (db *DatabaseChaos) CreateEntity(ctx context.Context, entity string, v interface{}) error {
+	if err := db.inject(dao.EntityMethod("CreateEntity", entity)); err != nil {
+		return err
+	}
+	return db.db.CreateEntity(ctx, entity, v)
+}
+
+func (db *DatabaseChaos) DeleteEntity(ctx context.Context, entity, id string) error {
+	if err := db.inject(dao.EntityMethod("DeleteEntity", entity)); err != nil {
+		return err
+	}
+	return db.db.DeleteEntity(ctx, entity, id)
+}
+
+func (db *DatabaseChaos) UpdateEntity(ctx context.Context, entity string, v interface{}) error {
+	if err := db.inject(dao.EntityMethod("UpdateEntity", entity)); err != nil {
+		return err
+	}
+	return db.db.UpdateEntity(ctx, entity, v)
+}
+
+func (db *DatabaseChaos) GetEntitiesAfter(ctx context.Context, entity, afterID string, limit int) ([]interface{}, error) {
+	if err := db.inject(dao.EntityMethod("GetEntitiesAfter", entity)); err != nil {
+		return nil, err
+	}
+	return db.db.GetEntitiesAfter(ctx, entity, afterID, limit)
+}
+
+func (db *DatabaseChaos) RestoreEntity(ctx context.Context, entity string, v interface{}) error {
+	if err := db.inject(dao.EntityMethod("RestoreEntity", entity)); err != nil {
+		return err
+	}
+	return db.db.RestoreEntity(ctx, entity, v)
+}
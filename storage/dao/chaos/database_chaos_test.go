@@ -0,0 +1,67 @@
+package chaos
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adeo/turbine-go-api-skeleton/storage/dao"
+	"github.com/adeo/turbine-go-api-skeleton/storage/dao/daotest"
+	"github.com/adeo/turbine-go-api-skeleton/storage/dao/fake"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDatabaseChaosWithoutRules(t *testing.T) {
+	daotest.Run(t, func(t *testing.T) dao.Database {
+		return NewDatabaseChaos(fake.NewDatabaseFake("", "", 0))
+	})
+}
+
+func TestDatabaseChaosInject(t *testing.T) {
+	db := NewDatabaseChaos(fake.NewDatabaseFake("", "", 0))
+	db.SetConfig(Config{Rules: []Rule{
+		{Method: dao.EntityMethod("GetAllEntities", "template"), Probability: 1, ErrorType: "duplicate"},
+		{Method: AllMethods, Probability: 1, LatencyMS: 20},
+		{Method: dao.EntityMethod("DeleteEntity", "template"), Probability: 1, ErrorType: ErrorTypeGeneric},
+		{Method: dao.EntityMethod("CreateEntity", "template"), Probability: 0, ErrorType: ErrorTypeGeneric},
+	}})
+
+	start := time.Now()
+	_, err := db.GetAllEntities(context.Background(), "template")
+	assert.True(t, time.Since(start) >= 20*time.Millisecond, "latency must be injected")
+	require.Error(t, err)
+	require.IsType(t, &dao.DAOError{}, err)
+	assert.Equal(t, dao.ErrTypeDuplicate, err.(*dao.DAOError).Type)
+
+	err = db.DeleteEntity(context.Background(), "template", "id")
+	assert.Equal(t, ErrInjected, err)
+
+	_, err = db.GetEntityByID(context.Background(), "template", "id")
+	require.IsType(t, &dao.DAOError{}, err)
+	assert.Equal(t, dao.ErrTypeNotFound, err.(*dao.DAOError).Type, "the wrapped database must be called")
+
+	db.SetConfig(Config{})
+	_, err = db.GetAllEntities(context.Background(), "template")
+	assert.NoError(t, err)
+}
+
+func TestDatabaseChaosDecorate(t *testing.T) {
+	db := NewDatabaseChaos(fake.NewDatabaseFake("", "", 0))
+	other := db.Decorate(fake.NewDatabaseFake("", "", 0))
+
+	db.SetConfig(Config{Rules: []Rule{{Method: AllMethods, Probability: 1, ErrorType: ErrorTypeGeneric}}})
+	_, err := other.GetAllEntities(context.Background(), "template")
+	assert.Equal(t, ErrInjected, err, "the rules must be shared")
+	assert.Len(t, other.GetConfig().Rules, 1)
+}
+
+func TestRuleErrorTypes(t *testing.T) {
+	field, _ := reflect.TypeOf(Rule{}).FieldByName("ErrorType")
+	for typ := dao.ErrTypeNotFound; !strings.HasPrefix(typ.String(), "unknown_"); typ++ {
+		assert.Contains(t, strings.Split(strings.TrimPrefix(field.Tag.Get("validate"), "omitempty,oneof="), " "), typ.String(),
+			"all the dao error types must be injectable")
+	}
+}
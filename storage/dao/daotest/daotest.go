@@ -0,0 +1,109 @@
+// Package daotest provides a conformance suite that any dao.Database implementation can run,
+// to make sure all the backends behave the same way.
+package daotest
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/adeo/turbine-go-api-skeleton/storage/dao"
+	"github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// the suite works with two tenants, to check the data of a tenant are never visible to another one
+var (
+	tenantCtx      = dao.WithTenant(context.Background(), "daotest")
+	otherTenantCtx = dao.WithTenant(context.Background(), "daotest-other")
+)
+
+// DatabaseFactory returns the database to test. It is called at the beginning of each test case,
+// the suite takes care of removing the existing data before using it.
+type DatabaseFactory func(t *testing.T) dao.Database
+
+// Run runs the whole conformance suite against the databases returned by newDatabase
+func Run(t *testing.T, newDatabase DatabaseFactory) {
+	t.Run("Entity", func(t *testing.T) { RunEntityTests(t, newDatabase) })
+}
+
+// requireDAOError checks that err wraps a *dao.DAOError of the given type
+func requireDAOError(t *testing.T, err error, expected dao.Type) {
+	require.Error(t, err)
+	e, ok := dao.AsDAOError(err)
+	require.Truef(t, ok, "expected a *dao.DAOError, got %T: %v", err, err)
+	require.Equal(t, expected, e.Type, "unexpected dao error type: %v", err)
+}
+
+// assertSameTime compares two timestamps, ignoring location and monotonic clock
+func assertSameTime(t *testing.T, expected, actual time.Time) {
+	assert.Truef(t, expected.Equal(actual), "expected %v, got %v", expected, actual)
+}
+
+// assertRecent checks that the timestamp has been set by the database when the test ran
+func assertRecent(t *testing.T, actual time.Time) {
+	assert.WithinDuration(t, time.Now(), actual, time.Minute)
+}
+
+// waitForNextTimestamp makes sure the next timestamp generated by the database will be strictly
+// greater than the previous one, whatever the precision of the backend
+func waitForNextTimestamp() {
+	time.Sleep(2 * time.Millisecond)
+}
+
+// UUIDIDs returns db rejecting the ids which are not uuids with a dao.ErrInvalidID, like a PostgreSQL table with a
+// uuid id column, to test the copies between backends generating different ids
+func UUIDIDs(db dao.Database) dao.Database {
+	return &uuidIDs{Database: db}
+}
+
+type uuidIDs struct {
+	dao.Database
+}
+
+// checkUUID returns the error of PostgreSQL when the id is not a uuid
+func checkUUID(id string) error {
+	if _, err := uuid.FromString(id); err != nil {
+		return dao.NewDAOError(dao.ErrTypeInvalidID, fmt.Errorf("invalid input syntax for type uuid: %q", id))
+	}
+	return nil
+}
+
+// checkEntityUUID returns the error of PostgreSQL when the id of the registered entity is not a uuid
+func checkEntityUUID(entity string, v interface{}) error {
+	e, err := dao.LookupEntity(entity)
+	if err != nil {
+		return err
+	}
+	return checkUUID(e.ID(v))
+}
+
+func (db *uuidIDs) GetEntityByID(ctx context.Context, entity, id string) (interface{}, error) {
+	if err := checkUUID(id); err != nil {
+		return nil, err
+	}
+	return db.Database.GetEntityByID(ctx, entity, id)
+}
+
+func (db *uuidIDs) DeleteEntity(ctx context.Context, entity, id string) error {
+	if err := checkUUID(id); err != nil {
+		return err
+	}
+	return db.Database.DeleteEntity(ctx, entity, id)
+}
+
+func (db *uuidIDs) UpdateEntity(ctx context.Context, entity string, v interface{}) error {
+	if err := checkEntityUUID(entity, v); err != nil {
+		return err
+	}
+	return db.Database.UpdateEntity(ctx, entity, v)
+}
+
+func (db *uuidIDs) RestoreEntity(ctx context.Context, entity string, v interface{}) error {
+	if err := checkEntityUUID(entity, v); err != nil {
+		return err
+	}
+	return db.Database.RestoreEntity(ctx, entity, v)
+}
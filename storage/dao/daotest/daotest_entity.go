@@ -0,0 +1,343 @@
+package daotest
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/adeo/turbine-go-api-skeleton/storage/dao"
+	"github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// EntityItem is the entity registered by the suite to test the generic methods. The postgresql databases need its
+// table, eg. in the default schema:
+//
+//	CREATE TABLE schema.daotest_item (
+//		id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+//		tenant_id text NOT NULL,
+//		created_at timestamptz NOT NULL DEFAULT now(),
+//		updated_at timestamptz,
+//		name text NOT NULL,
+//		tags jsonb,
+//		attributes jsonb,
+//		expires_at timestamptz,
+//		UNIQUE (tenant_id, name)
+//	);
+const EntityItem = "daotest_item"
+
+func init() {
+	dao.RegisterEntity(EntityItem, Item{}, "name")
+}
+
+// Item is the model of EntityItem, its fields cover the kinds of values stored by the backends
+type Item struct {
+	ItemEditable `bson:",inline"`
+	ID           string     `json:"id" bson:"_id"`
+	TenantID     string     `json:"tenant_id,omitempty" bson:"tenant_id"`
+	CreatedAt    time.Time  `json:"created_at" bson:"created_at"`
+	UpdatedAt    *time.Time `json:"updated_at" bson:"updated_at"`
+}
+
+// ItemEditable holds the fields of an Item sent by the clients
+type ItemEditable struct {
+	Name       string            `json:"name" bson:"name"`
+	Tags       []string          `json:"tags" bson:"tags"`
+	Attributes map[string]string `json:"attributes" bson:"attributes"`
+	ExpiresAt  *time.Time        `json:"expires_at" bson:"expires_at"`
+}
+
+// RunEntityTests runs the conformance tests of the generic methods against the databases returned by newDatabase
+func RunEntityTests(t *testing.T, newDatabase DatabaseFactory) {
+	newEntityDatabase := func(t *testing.T) dao.Database {
+		db := newDatabase(t)
+		clearItems(t, db)
+		return db
+	}
+
+	t.Run("CreateAndGet", func(t *testing.T) { testEntityCreateAndGet(t, newEntityDatabase(t)) })
+	t.Run("CreateDuplicate", func(t *testing.T) { testEntityCreateDuplicate(t, newEntityDatabase(t)) })
+	t.Run("GetNotFound", func(t *testing.T) { testEntityGetNotFound(t, newEntityDatabase(t)) })
+	t.Run("GetAllOrdering", func(t *testing.T) { testEntityGetAllOrdering(t, newEntityDatabase(t)) })
+	t.Run("Update", func(t *testing.T) { testEntityUpdate(t, newEntityDatabase(t)) })
+	t.Run("UpdateDuplicate", func(t *testing.T) { testEntityUpdateDuplicate(t, newEntityDatabase(t)) })
+	t.Run("UpdateNotFound", func(t *testing.T) { testEntityUpdateNotFound(t, newEntityDatabase(t)) })
+	t.Run("Delete", func(t *testing.T) { testEntityDelete(t, newEntityDatabase(t)) })
+	t.Run("GetAfter", func(t *testing.T) { testEntityGetAfter(t, newEntityDatabase(t)) })
+	t.Run("Restore", func(t *testing.T) { testEntityRestore(t, newEntityDatabase(t)) })
+	t.Run("RestoreDuplicate", func(t *testing.T) { testEntityRestoreDuplicate(t, newEntityDatabase(t)) })
+	t.Run("TenantIsolation", func(t *testing.T) { testEntityTenantIsolation(t, newEntityDatabase(t)) })
+	t.Run("Expiry", func(t *testing.T) { testEntityExpiry(t, newEntityDatabase(t)) })
+	t.Run("UnknownEntity", func(t *testing.T) { testEntityUnknown(t, newEntityDatabase(t)) })
+}
+
+func clearItems(t *testing.T, db dao.Database) {
+	for _, ctx := range []context.Context{tenantCtx, otherTenantCtx} {
+		items, err := db.GetAllEntities(ctx, EntityItem)
+		require.NoError(t, err)
+		for _, i := range items {
+			require.NoError(t, db.DeleteEntity(ctx, EntityItem, i.(*Item).ID))
+		}
+	}
+}
+
+func newItem(name string) *Item {
+	return &Item{
+		ItemEditable: ItemEditable{
+			Name:       name,
+			Tags:       []string{"a", "b"},
+			Attributes: map[string]string{"key": "value"},
+		},
+	}
+}
+
+func createItem(t *testing.T, db dao.Database, name string) *Item {
+	i := newItem(name)
+	require.NoError(t, db.CreateEntity(tenantCtx, EntityItem, i))
+	return i
+}
+
+func getItem(t *testing.T, db dao.Database, ctx context.Context, id string) (*Item, error) {
+	v, err := db.GetEntityByID(ctx, EntityItem, id)
+	if err != nil {
+		return nil, err
+	}
+	require.IsType(t, &Item{}, v)
+	return v.(*Item), nil
+}
+
+func testEntityCreateAndGet(t *testing.T, db dao.Database) {
+	created := createItem(t, db, "item-1")
+	require.NotEmpty(t, created.ID)
+	assertRecent(t, created.CreatedAt)
+	assert.Nil(t, created.UpdatedAt)
+
+	found, err := getItem(t, db, tenantCtx, created.ID)
+	require.NoError(t, err)
+	assert.Equal(t, created.ID, found.ID)
+	assert.Equal(t, created.ItemEditable, found.ItemEditable)
+	assertSameTime(t, created.CreatedAt, found.CreatedAt)
+	assert.Nil(t, found.UpdatedAt)
+
+	// the stored value is not shared with the caller
+	found.Tags[0] = "modified"
+	found, err = getItem(t, db, tenantCtx, created.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "a", found.Tags[0])
+}
+
+func testEntityCreateDuplicate(t *testing.T, db dao.Database) {
+	createItem(t, db, "item-1")
+
+	err := db.CreateEntity(tenantCtx, EntityItem, newItem("item-1"))
+	requireDAOError(t, err, dao.ErrTypeDuplicate)
+
+	items, err := db.GetAllEntities(tenantCtx, EntityItem)
+	require.NoError(t, err)
+	assert.Len(t, items, 1)
+}
+
+func testEntityGetNotFound(t *testing.T, db dao.Database) {
+	createItem(t, db, "item-1")
+
+	found, err := db.GetEntityByID(tenantCtx, EntityItem, uuid.NewV4().String())
+	requireDAOError(t, err, dao.ErrTypeNotFound)
+	assert.Nil(t, found)
+}
+
+func testEntityGetAllOrdering(t *testing.T, db dao.Database) {
+	names := []string{"item-b", "item-c", "item-a"}
+	created := make([]*Item, 0, len(names))
+	for _, name := range names {
+		created = append(created, createItem(t, db, name))
+		waitForNextTimestamp()
+	}
+
+	items, err := db.GetAllEntities(tenantCtx, EntityItem)
+	require.NoError(t, err)
+	require.Len(t, items, len(created))
+	for i := range created {
+		assert.Equal(t, created[i].ID, items[i].(*Item).ID, "items must be ordered by creation date")
+		assert.Equal(t, created[i].ItemEditable, items[i].(*Item).ItemEditable)
+	}
+}
+
+func testEntityUpdate(t *testing.T, db dao.Database) {
+	created := createItem(t, db, "item-1")
+	waitForNextTimestamp()
+
+	// only the editable fields and the id are given, the database keeps the other ones
+	toUpdate := newItem("item-2")
+	toUpdate.ID = created.ID
+	toUpdate.Tags = nil
+	require.NoError(t, db.UpdateEntity(tenantCtx, EntityItem, toUpdate))
+	assert.Equal(t, "item-2", toUpdate.Name)
+	assert.Equal(t, dao.TenantFromContext(tenantCtx), toUpdate.TenantID)
+	assertSameTime(t, created.CreatedAt, toUpdate.CreatedAt)
+	require.NotNil(t, toUpdate.UpdatedAt)
+	assertRecent(t, *toUpdate.UpdatedAt)
+
+	found, err := getItem(t, db, tenantCtx, created.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "item-2", found.Name)
+	assert.Empty(t, found.Tags)
+	assertSameTime(t, created.CreatedAt, found.CreatedAt)
+	require.NotNil(t, found.UpdatedAt)
+	assertSameTime(t, *toUpdate.UpdatedAt, *found.UpdatedAt)
+}
+
+func testEntityUpdateDuplicate(t *testing.T, db dao.Database) {
+	createItem(t, db, "item-1")
+	created := createItem(t, db, "item-2")
+
+	created.Name = "item-1"
+	err := db.UpdateEntity(tenantCtx, EntityItem, created)
+	requireDAOError(t, err, dao.ErrTypeDuplicate)
+
+	found, err := getItem(t, db, tenantCtx, created.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "item-2", found.Name)
+}
+
+func testEntityUpdateNotFound(t *testing.T, db dao.Database) {
+	toUpdate := newItem("item-1")
+	toUpdate.ID = uuid.NewV4().String()
+
+	err := db.UpdateEntity(tenantCtx, EntityItem, toUpdate)
+	requireDAOError(t, err, dao.ErrTypeNotFound)
+}
+
+func testEntityDelete(t *testing.T, db dao.Database) {
+	deleted := createItem(t, db, "item-1")
+	kept := createItem(t, db, "item-2")
+
+	require.NoError(t, db.DeleteEntity(tenantCtx, EntityItem, deleted.ID))
+	_, err := db.GetEntityByID(tenantCtx, EntityItem, deleted.ID)
+	requireDAOError(t, err, dao.ErrTypeNotFound)
+	err = db.DeleteEntity(tenantCtx, EntityItem, deleted.ID)
+	requireDAOError(t, err, dao.ErrTypeNotFound)
+
+	items, err := db.GetAllEntities(tenantCtx, EntityItem)
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+	assert.Equal(t, kept.ID, items[0].(*Item).ID)
+
+	// the name is available again
+	createItem(t, db, "item-1")
+}
+
+func testEntityGetAfter(t *testing.T, db dao.Database) {
+	ids := make([]string, 0)
+	for _, name := range []string{"item-1", "item-2", "item-3"} {
+		ids = append(ids, createItem(t, db, name).ID)
+	}
+	sort.Strings(ids)
+
+	page, err := db.GetEntitiesAfter(tenantCtx, EntityItem, "", 2)
+	require.NoError(t, err)
+	require.Len(t, page, 2)
+	assert.Equal(t, ids[0], page[0].(*Item).ID)
+	assert.Equal(t, ids[1], page[1].(*Item).ID)
+
+	page, err = db.GetEntitiesAfter(tenantCtx, EntityItem, page[1].(*Item).ID, 2)
+	require.NoError(t, err)
+	require.Len(t, page, 1)
+	assert.Equal(t, ids[2], page[0].(*Item).ID)
+}
+
+func testEntityRestore(t *testing.T, db dao.Database) {
+	source := createItem(t, db, "item-1")
+	require.NoError(t, db.DeleteEntity(tenantCtx, EntityItem, source.ID))
+
+	// the precision of the timestamps of all the backends
+	createdAt := time.Now().Add(-time.Hour).Truncate(time.Millisecond)
+	updatedAt := createdAt.Add(time.Minute)
+	restored := newItem("item-1")
+	restored.ID = source.ID
+	restored.CreatedAt = createdAt
+	restored.UpdatedAt = &updatedAt
+	require.NoError(t, db.RestoreEntity(tenantCtx, EntityItem, restored))
+
+	found, err := getItem(t, db, tenantCtx, source.ID)
+	require.NoError(t, err)
+	assert.Equal(t, restored.ItemEditable, found.ItemEditable)
+	assert.Equal(t, dao.TenantFromContext(tenantCtx), found.TenantID)
+	assertSameTime(t, createdAt, found.CreatedAt)
+	require.NotNil(t, found.UpdatedAt)
+	assertSameTime(t, updatedAt, *found.UpdatedAt)
+}
+
+func testEntityRestoreDuplicate(t *testing.T, db dao.Database) {
+	existing := createItem(t, db, "item-1")
+
+	sameID := newItem("item-2")
+	sameID.ID = existing.ID
+	sameID.CreatedAt = existing.CreatedAt
+	err := db.RestoreEntity(tenantCtx, EntityItem, sameID)
+	requireDAOError(t, err, dao.ErrTypeDuplicate)
+
+	sameName := newItem("item-1")
+	sameName.ID = uuid.NewV4().String()
+	sameName.CreatedAt = existing.CreatedAt
+	err = db.RestoreEntity(tenantCtx, EntityItem, sameName)
+	requireDAOError(t, err, dao.ErrTypeDuplicate)
+}
+
+func testEntityTenantIsolation(t *testing.T, db dao.Database) {
+	created := createItem(t, db, "item-1")
+	assert.Equal(t, dao.TenantFromContext(tenantCtx), created.TenantID)
+
+	// the unique indexes are scoped by tenant
+	other := newItem("item-1")
+	require.NoError(t, db.CreateEntity(otherTenantCtx, EntityItem, other))
+
+	items, err := db.GetAllEntities(otherTenantCtx, EntityItem)
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+	assert.Equal(t, other.ID, items[0].(*Item).ID)
+
+	_, err = db.GetEntityByID(otherTenantCtx, EntityItem, created.ID)
+	requireDAOError(t, err, dao.ErrTypeNotFound)
+	update := newItem("item-2")
+	update.ID = created.ID
+	err = db.UpdateEntity(otherTenantCtx, EntityItem, update)
+	requireDAOError(t, err, dao.ErrTypeNotFound)
+	err = db.DeleteEntity(otherTenantCtx, EntityItem, created.ID)
+	requireDAOError(t, err, dao.ErrTypeNotFound)
+}
+
+func testEntityExpiry(t *testing.T, db dao.Database) {
+	// the expired items are hidden but may not be purged yet: their names must not collide with the other tests
+	expiresAt := time.Now().Add(time.Hour).Truncate(time.Millisecond)
+	expiring := newItem("item-expiring-" + uuid.NewV4().String())
+	expiring.ExpiresAt = &expiresAt
+	require.NoError(t, db.CreateEntity(tenantCtx, EntityItem, expiring))
+
+	found, err := getItem(t, db, tenantCtx, expiring.ID)
+	require.NoError(t, err, "an item must be visible until its expiry")
+	require.NotNil(t, found.ExpiresAt)
+	assertSameTime(t, expiresAt, *found.ExpiresAt)
+
+	expiredAt := time.Now().Add(-time.Second).Truncate(time.Millisecond)
+	expiring.ExpiresAt = &expiredAt
+	require.NoError(t, db.UpdateEntity(tenantCtx, EntityItem, expiring))
+
+	_, err = db.GetEntityByID(tenantCtx, EntityItem, expiring.ID)
+	requireDAOError(t, err, dao.ErrTypeNotFound)
+	items, err := db.GetAllEntities(tenantCtx, EntityItem)
+	require.NoError(t, err)
+	assert.Empty(t, items)
+	page, err := db.GetEntitiesAfter(tenantCtx, EntityItem, "", 10)
+	require.NoError(t, err)
+	assert.Empty(t, page)
+}
+
+func testEntityUnknown(t *testing.T, db dao.Database) {
+	_, err := db.GetAllEntities(tenantCtx, "daotest_unknown")
+	assert.True(t, errors.Is(err, dao.ErrUnknownEntity), "unexpected error: %v", err)
+	err = db.CreateEntity(tenantCtx, "daotest_unknown", newItem("item-1"))
+	assert.True(t, errors.Is(err, dao.ErrUnknownEntity), "unexpected error: %v", err)
+}
@@ -1,17 +1,7 @@
 package dao
 
-import (
-	"github.com/adeo/turbine-go-api-skeleton/storage/model"
-)
-
+// Database is implemented by the databases. Every call is scoped to the tenant of the given context, see WithTenant.
+// The entities, registered with RegisterEntity, are stored by the generic methods of EntityDatabase.
 type Database interface {
-
-	// start: template dao funcs
-	GetAllTemplates() ([]*model.Template, error)
-	GetTemplateByID(string) (*model.Template, error)
-	CreateTemplate(*model.Template) error
-	DeleteTemplate(string) error
-	UpdateTemplate(template *model.Template) error
-	// end: template dao funcs
-
+	EntityDatabase
 }
@@ -1,7 +1,11 @@
 package dao
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net"
+	"time"
 )
 
 type Type int
@@ -10,11 +14,69 @@ const (
 	ErrTypeNotFound Type = iota
 	ErrTypeDuplicate
 	ErrTypeForeignKeyViolation
+	ErrTypeUnavailable
+	// ErrTypeConflict is a write conflicting with a concurrent one, or made on an outdated version of the entity
+	ErrTypeConflict
+	// ErrTypeTimeout is a call which did not complete in time, it may have been applied
+	ErrTypeTimeout
+	// ErrTypeConstraintViolation is a value rejected by a constraint of the database other than unicity and foreign keys
+	ErrTypeConstraintViolation
+	// ErrTypeCanceled is a call canceled by the caller, usually because the client went away
+	ErrTypeCanceled
+	// ErrTypeInvalidID is an id which can't be stored by the database, eg. a MongoDB id in a PostgreSQL uuid column
+	ErrTypeInvalidID
+)
+
+var typeNames = map[Type]string{
+	ErrTypeNotFound:            "not_found",
+	ErrTypeDuplicate:           "duplicate",
+	ErrTypeForeignKeyViolation: "foreign_key_violation",
+	ErrTypeUnavailable:         "unavailable",
+	ErrTypeConflict:            "conflict",
+	ErrTypeTimeout:             "timeout",
+	ErrTypeConstraintViolation: "constraint_violation",
+	ErrTypeCanceled:            "canceled",
+	ErrTypeInvalidID:           "invalid_id",
+}
+
+func (t Type) String() string {
+	if name, ok := typeNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("unknown_%d", int(t))
+}
+
+// ParseType returns the type having the given name, as returned by Type.String
+func ParseType(name string) (Type, bool) {
+	for t, n := range typeNames {
+		if n == name {
+			return t, true
+		}
+	}
+	return 0, false
+}
+
+// The targets of errors.Is, matching any dao error of their type
+var (
+	ErrNotFound            error = &DAOError{Type: ErrTypeNotFound}
+	ErrDuplicate           error = &DAOError{Type: ErrTypeDuplicate}
+	ErrForeignKeyViolation error = &DAOError{Type: ErrTypeForeignKeyViolation}
+	ErrUnavailable         error = &DAOError{Type: ErrTypeUnavailable}
+	ErrConflict            error = &DAOError{Type: ErrTypeConflict}
+	ErrTimeout             error = &DAOError{Type: ErrTypeTimeout}
+	ErrConstraintViolation error = &DAOError{Type: ErrTypeConstraintViolation}
+	ErrCanceled            error = &DAOError{Type: ErrTypeCanceled}
+	ErrInvalidID           error = &DAOError{Type: ErrTypeInvalidID}
 )
 
 type DAOError struct {
 	Cause error
 	Type  Type
+	// Field is the field, or the constraint or index when the field is unknown, rejecting the value.
+	// Used with ErrTypeDuplicate, ErrTypeForeignKeyViolation and ErrTypeConstraintViolation, when known.
+	Field string
+	// RetryAfter is the delay after which the call may succeed, when known. Used with ErrTypeUnavailable.
+	RetryAfter time.Duration
 }
 
 func NewDAOError(t Type, cause error) error {
@@ -24,9 +86,66 @@ func NewDAOError(t Type, cause error) error {
 	}
 }
 
+// NewFieldDAOError returns a dao error caused by the value of the given field
+func NewFieldDAOError(t Type, field string, cause error) error {
+	return &DAOError{
+		Type:  t,
+		Field: field,
+		Cause: cause,
+	}
+}
+
+// AsDAOError returns the first dao error in the chain of err, see errors.As
+func AsDAOError(err error) (*DAOError, bool) {
+	var e *DAOError
+	if errors.As(err, &e) {
+		return e, true
+	}
+	return nil, false
+}
+
 func (e *DAOError) Error() string {
+	name := e.Type.String()
+	if e.Field != "" {
+		name += " (" + e.Field + ")"
+	}
 	if e.Cause != nil {
-		return fmt.Sprintf("Type %d: %s", e.Type, e.Cause.Error())
+		return name + ": " + e.Cause.Error()
+	}
+	return name
+}
+
+func (e *DAOError) Unwrap() error {
+	return e.Cause
+}
+
+// Is makes the dao errors match the targets of their type, eg. errors.Is(err, dao.ErrNotFound)
+func (e *DAOError) Is(target error) bool {
+	t, ok := target.(*DAOError)
+	return ok && t.Cause == nil && t.Field == "" && t.Type == e.Type
+}
+
+// Classify returns the dao error matching the errors which do not depend on the driver: the cancellation and
+// deadline of the context, and the network errors. The other errors, dao errors included, are returned as is.
+func Classify(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := AsDAOError(err); ok {
+		return err
+	}
+	if errors.Is(err, context.Canceled) {
+		return NewDAOError(ErrTypeCanceled, err)
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return NewDAOError(ErrTypeTimeout, err)
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		if netErr.Timeout() {
+			return NewDAOError(ErrTypeTimeout, err)
+		}
+		return NewDAOError(ErrTypeUnavailable, err)
 	}
-	return fmt.Sprintf("Type %d: no cause given", e.Type)
+	return err
 }
@@ -0,0 +1,42 @@
+package dao
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDAOErrorIs(t *testing.T) {
+	cause := errors.New("no rows")
+	err := fmt.Errorf("getting the template: %w", NewDAOError(ErrTypeNotFound, cause))
+
+	assert.True(t, errors.Is(err, ErrNotFound))
+	assert.False(t, errors.Is(err, ErrDuplicate))
+	assert.True(t, errors.Is(err, cause), "the cause must be unwrapped")
+
+	e, ok := AsDAOError(err)
+	assert.True(t, ok)
+	assert.Equal(t, ErrTypeNotFound, e.Type)
+}
+
+func TestDAOErrorError(t *testing.T) {
+	assert.Equal(t, "not_found: no rows", NewDAOError(ErrTypeNotFound, errors.New("no rows")).Error())
+	assert.Equal(t, "constraint_violation (name): null value", NewFieldDAOError(ErrTypeConstraintViolation, "name", errors.New("null value")).Error())
+	assert.Equal(t, "unavailable", ErrUnavailable.Error())
+}
+
+func TestClassify(t *testing.T) {
+	assert.Nil(t, Classify(nil))
+	assert.True(t, errors.Is(Classify(context.Canceled), ErrCanceled))
+	assert.True(t, errors.Is(Classify(fmt.Errorf("query: %w", context.DeadlineExceeded)), ErrTimeout))
+	assert.True(t, errors.Is(Classify(&net.OpError{Op: "dial", Err: errors.New("connection refused")}), ErrUnavailable))
+
+	other := errors.New("syntax error")
+	assert.Equal(t, other, Classify(other))
+	duplicate := NewDAOError(ErrTypeDuplicate, other)
+	assert.Equal(t, duplicate, Classify(duplicate))
+}
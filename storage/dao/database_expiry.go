@@ -0,0 +1,20 @@
+package dao
+
+import "time"
+
+// ExpiryConfig is the driver setting of the purge of the expired entities, for the backends not removing them by
+// themselves. The expired entities are never returned by the reads, even before being purged.
+type ExpiryConfig struct {
+	PurgeInterval time.Duration // 0 disables the purge
+}
+
+// PurgeStatsProvider is implemented by the databases purging the expired entities themselves
+type PurgeStatsProvider interface {
+	// PurgedCounts returns the number of expired entities purged since the database was opened, by entity
+	PurgedCounts() map[string]int64
+}
+
+// IsExpired tells if an entity expiring at the given date, nil if it never expires, is expired
+func IsExpired(expiresAt *time.Time, now time.Time) bool {
+	return expiresAt != nil && !expiresAt.After(now)
+}
@@ -0,0 +1,8 @@
+package dao
+
+import "context"
+
+// Pinger is implemented by the databases able to check they are reachable
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
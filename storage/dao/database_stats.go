@@ -0,0 +1,21 @@
+package dao
+
+// PoolStats are the statistics of a connection pool of a database
+type PoolStats struct {
+	// Name identifies the pool in the database, eg. primary or the address of a server
+	Name                string  `json:"name"`
+	MaxOpenConnections  int     `json:"max_open_connections"` // 0 means unlimited
+	OpenConnections     int     `json:"open_connections"`
+	InUse               int     `json:"in_use"`
+	Idle                int     `json:"idle"`
+	WaitCount           int64   `json:"wait_count"`            // number of connections waited for
+	WaitDurationSeconds float64 `json:"wait_duration_seconds"` // total time waited for connections
+	MaxIdleClosed       int64   `json:"max_idle_closed"`       // number of connections closed because of the max idle setting
+	MaxLifetimeClosed   int64   `json:"max_lifetime_closed"`   // number of connections closed because of the max lifetime setting
+	CheckOutFailures    int64   `json:"check_out_failures"`    // number of failures to get a connection from the pool
+}
+
+// PoolStatsProvider is implemented by the databases exposing the statistics of their connection pools
+type PoolStatsProvider interface {
+	PoolStats() []PoolStats
+}
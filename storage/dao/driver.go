@@ -0,0 +1,75 @@
+package dao
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Options are the options given to a driver to open a database
+type Options struct {
+	ConnectionURI     string
+	ReadConnectionURI string // read replicas, ignored by the drivers not supporting them
+	Name              string // name of the database, ignored by the drivers not using it
+	Tenancy           Tenancy
+	// Settings are the driver specific settings, each driver picks the values of its own types (eg. postgresql.PoolConfig)
+	Settings []interface{}
+}
+
+// Driver opens a database from its options, it must not call Fatal nor panic when the database is unreachable
+type Driver func(opts Options) (Database, error)
+
+var (
+	driversMu sync.RWMutex
+	drivers   = make(map[string]Driver)
+)
+
+// Register makes a driver available for the connection uris with the given scheme (eg. postgresql for postgresql://...).
+// It is meant to be called from the init function of the package of the driver, and panics if the scheme is
+// already registered.
+func Register(scheme string, driver Driver) {
+	driversMu.Lock()
+	defer driversMu.Unlock()
+	if driver == nil {
+		panic("dao: Register driver is nil")
+	}
+	if _, exists := drivers[scheme]; exists {
+		panic("dao: Register called twice for scheme " + scheme)
+	}
+	drivers[scheme] = driver
+}
+
+// Drivers returns the sorted schemes of the registered drivers
+func Drivers() []string {
+	driversMu.RLock()
+	defer driversMu.RUnlock()
+	schemes := make([]string, 0, len(drivers))
+	for scheme := range drivers {
+		schemes = append(schemes, scheme)
+	}
+	sort.Strings(schemes)
+	return schemes
+}
+
+// Scheme returns the scheme of the given connection uri, empty if it has none
+func Scheme(connectionURI string) string {
+	// the uri is not parsed with net/url, which rejects the lists of hosts of mongodb uris
+	i := strings.Index(connectionURI, "://")
+	if i <= 0 {
+		return ""
+	}
+	return connectionURI[:i]
+}
+
+// Open opens the database with the driver registered for the scheme of its connection uri
+func Open(opts Options) (Database, error) {
+	scheme := Scheme(opts.ConnectionURI)
+	driversMu.RLock()
+	driver, ok := drivers[scheme]
+	driversMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("dao: no driver registered for the scheme %q of the connection uri, registered schemes: %s", scheme, strings.Join(Drivers(), ", "))
+	}
+	return driver(opts)
+}
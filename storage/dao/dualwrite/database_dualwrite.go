@@ -0,0 +1,200 @@
+// Package dualwrite provides a dao.Database writing to two databases, to migrate from a backend to another
+// without downtime: the secondary database is kept up to date, then becomes the primary one.
+package dualwrite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sync"
+
+	"github.com/adeo/turbine-go-api-skeleton/storage/dao"
+	"github.com/adeo/turbine-go-api-skeleton/utils"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	shadowReadsName = "dao_shadow_reads_total"
+	writeErrorsName = "dao_dual_write_errors_total"
+
+	shadowReadMatch    = "match"
+	shadowReadMismatch = "mismatch"
+	shadowReadError    = "error"
+	shadowReadDropped  = "dropped"
+
+	// maxShadowReads is the number of shadow reads running at once, the next reads are not compared until one ends
+	maxShadowReads = 100
+)
+
+var (
+	registerOnce sync.Once
+	shadowReads  *prometheus.CounterVec
+	writeErrors  *prometheus.CounterVec
+)
+
+// register creates and registers the collectors, shared by all the dual write databases
+func register(service string) {
+	registerOnce.Do(func() {
+		labels := prometheus.Labels{"service": service}
+
+		shadowReads = prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name:        shadowReadsName,
+				Help:        "How many reads of the primary database were compared with the secondary one, partitioned by backends, method and result (match, mismatch, error, or dropped when too many were running).",
+				ConstLabels: labels,
+			},
+			[]string{"primary", "secondary", "method", "result"},
+		)
+		writeErrors = prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name:        writeErrorsName,
+				Help:        "How many writes succeeded in the primary database but failed in the secondary one, partitioned by backends and method.",
+				ConstLabels: labels,
+			},
+			[]string{"primary", "secondary", "method"},
+		)
+
+		prometheus.MustRegister(shadowReads, writeErrors)
+	})
+}
+
+// DatabaseDualWrite writes to a primary and a secondary database, and reads from the primary one.
+// The writes are applied to the secondary database once they succeeded in the primary one, keeping the ids
+// generated by the primary database; their failures in the secondary database are logged and counted, but
+// never returned. When shadow reads are enabled, the reads are also sent to the secondary database in
+// background, and their results compared with the ones of the primary database, at most maxShadowReads at once.
+type DatabaseDualWrite struct {
+	primary          dao.Database
+	secondary        dao.Database
+	primaryBackend   string
+	secondaryBackend string
+	shadowReads      bool
+	pending          sync.WaitGroup // running shadow reads
+	shadowSlots      chan struct{}  // a value per running shadow read, to bound them
+}
+
+// NewDatabaseDualWrite returns a database writing to both databases, labeled with their backend names (eg. mongodb)
+func NewDatabaseDualWrite(primary, secondary dao.Database, shadowReads bool, service, primaryBackend, secondaryBackend string) *DatabaseDualWrite {
+	register(service)
+	return &DatabaseDualWrite{
+		primary:          primary,
+		secondary:        secondary,
+		primaryBackend:   primaryBackend,
+		secondaryBackend: secondaryBackend,
+		shadowReads:      shadowReads,
+		shadowSlots:      make(chan struct{}, maxShadowReads),
+	}
+}
+
+// mirror applies to the secondary database a write which succeeded in the primary one
+func (db *DatabaseDualWrite) mirror(method string, write func() error) {
+	err := write()
+	if err != nil {
+		writeErrors.WithLabelValues(db.primaryBackend, db.secondaryBackend, method).Inc()
+		logger := utils.GetLogger().WithError(err).WithField("method", method)
+		if errors.Is(err, dao.ErrInvalidID) {
+			// eg. a MongoDB id written to a PostgreSQL table with a uuid id column
+			logger.Error("dual write failed in the secondary database, its id column must be migrated to text")
+			return
+		}
+		logger.Error("dual write failed in the secondary database")
+	}
+}
+
+// shadowRead compares in background the result of a read of the primary database, in its comparable form,
+// with the result of the same read of the secondary database. A not found error is compared as a nil result.
+// The read is dropped when maxShadowReads are already running, not to pile up goroutines on a slow secondary.
+func (db *DatabaseDualWrite) shadowRead(ctx context.Context, method string, expected interface{}, read func(ctx context.Context) (interface{}, error)) {
+	if !db.shadowReads {
+		return
+	}
+	select {
+	case db.shadowSlots <- struct{}{}:
+	default:
+		shadowReads.WithLabelValues(db.primaryBackend, db.secondaryBackend, method, shadowReadDropped).Inc()
+		return
+	}
+
+	// the context of the request can't be used once the call returned, only its tenant is kept
+	ctx = dao.WithTenant(context.Background(), dao.TenantFromContext(ctx))
+	db.pending.Add(1)
+	go func() {
+		defer db.pending.Done()
+		defer func() { <-db.shadowSlots }()
+
+		actual, err := read(ctx)
+		if errors.Is(err, dao.ErrNotFound) {
+			actual, err = nil, nil
+		}
+
+		result := shadowReadMatch
+		logger := utils.GetLogger().WithField("method", method).WithField("tenant", dao.TenantFromContext(ctx))
+		switch {
+		case err != nil:
+			result = shadowReadError
+			logger.WithError(err).Error("shadow read failed in the secondary database")
+		case !reflect.DeepEqual(expected, actual):
+			result = shadowReadMismatch
+			logger.WithField("primary", expected).WithField("secondary", actual).Warn("shadow read differs between the databases")
+		}
+		shadowReads.WithLabelValues(db.primaryBackend, db.secondaryBackend, method, result).Inc()
+	}()
+}
+
+// wait waits for the running shadow reads
+func (db *DatabaseDualWrite) wait() {
+	db.pending.Wait()
+}
+
+// PoolStats returns the statistics of the connection pools of both databases, prefixed by their role
+func (db *DatabaseDualWrite) PoolStats() []dao.PoolStats {
+	stats := make([]dao.PoolStats, 0)
+	for _, role := range []struct {
+		name string
+		db   dao.Database
+	}{{"primary", db.primary}, {"secondary", db.secondary}} {
+		provider, ok := role.db.(dao.PoolStatsProvider)
+		if !ok {
+			continue
+		}
+		for _, s := range provider.PoolStats() {
+			s.Name = role.name + "/" + s.Name
+			stats = append(stats, s)
+		}
+	}
+	return stats
+}
+
+// Ping checks that both databases are reachable, its error naming the unreachable one: the failures of the
+// secondary database do not fail the calls, but the writes it misses must be copied again
+func (db *DatabaseDualWrite) Ping(ctx context.Context) error {
+	for _, role := range []struct {
+		name string
+		db   dao.Database
+	}{{"primary", db.primary}, {"secondary", db.secondary}} {
+		pinger, ok := role.db.(dao.Pinger)
+		if !ok {
+			continue
+		}
+		if err := pinger.Ping(ctx); err != nil {
+			return fmt.Errorf("%s database: %w", role.name, err)
+		}
+	}
+	return nil
+}
+
+// Close waits for the running shadow reads, then closes both databases
+func (db *DatabaseDualWrite) Close() error {
+	db.wait()
+	var result error
+	for _, d := range []dao.Database{db.primary, db.secondary} {
+		if closer, ok := d.(io.Closer); ok {
+			if err := closer.Close(); err != nil && result == nil {
+				result = err
+			}
+		}
+	}
+	return result
+}
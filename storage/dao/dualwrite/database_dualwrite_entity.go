@@ -0,0 +1,109 @@
+package dualwrite
+
+import (
+	"context"
+
+	"github.com/adeo/turbine-go-api-skeleton/storage/dao"
+)
+
+// comparableEntities returns the form of the values compared by the shadow reads, see dao.Entity.Comparable.
+// The values of an unknown entity are returned as they are, its reads failing in both databases.
+func comparableEntities(entity string, values []interface{}) []interface{} {
+	e, err := dao.LookupEntity(entity)
+	if err != nil {
+		return values
+	}
+	result := make([]interface{}, 0, len(values))
+	for _, v := range values {
+		result = append(result, e.Comparable(v, false))
+	}
+	return result
+}
+
+func comparableEntity(entity string, v interface{}) interface{} {
+	return comparableEntities(entity, []interface{}{v})[0]
+}
+
+// copyEntity returns a shallow copy of the value pointed by v, mirrored to the secondary database
+func copyEntity(entity string, v interface{}) interface{} {
+	e, err := dao.LookupEntity(entity)
+	if err != nil {
+		return v
+	}
+	return e.Copy(v)
+}
+
+func (db *DatabaseDualWrite) GetAllEntities(ctx context.Context, entity string) ([]interface{}, error) {
+	method := dao.EntityMethod("GetAllEntities", entity)
+	values, err := db.primary.GetAllEntities(ctx, entity)
+	if err == nil {
+		db.shadowRead(ctx, method, comparableEntities(entity, values), func(ctx context.Context) (interface{}, error) {
+			values, err := db.secondary.GetAllEntities(ctx, entity)
+			return comparableEntities(entity, values), err
+		})
+	}
+	return values, err
+}
+
+func (db *DatabaseDualWrite) GetEntityByID(ctx context.Context, entity, id string) (interface{}, error) {
+	method := dao.EntityMethod("GetEntityByID", entity)
+	v, err := db.primary.GetEntityByID(ctx, entity, id)
+	if err == nil {
+		db.shadowRead(ctx, method, comparableEntity(entity, v), func(ctx context.Context) (interface{}, error) {
+			v, err := db.secondary.GetEntityByID(ctx, entity, id)
+			if err != nil {
+				return nil, err
+			}
+			return comparableEntity(entity, v), nil
+		})
+	}
+	return v, err
+}
+
+func (db *DatabaseDualWrite) GetEntitiesAfter(ctx context.Context, entity, afterID string, limit int) ([]interface{}, error) {
+	method := dao.EntityMethod("GetEntitiesAfter", entity)
+	values, err := db.primary.GetEntitiesAfter(ctx, entity, afterID, limit)
+	if err == nil {
+		db.shadowRead(ctx, method, comparableEntities(entity, values), func(ctx context.Context) (interface{}, error) {
+			values, err := db.secondary.GetEntitiesAfter(ctx, entity, afterID, limit)
+			return comparableEntities(entity, values), err
+		})
+	}
+	return values, err
+}
+
+func (db *DatabaseDualWrite) CreateEntity(ctx context.Context, entity string, v interface{}) error {
+	err := db.primary.CreateEntity(ctx, entity, v)
+	if err == nil {
+		// the secondary database keeps the id and timestamps generated by the primary one
+		mirrored := copyEntity(entity, v)
+		db.mirror(dao.EntityMethod("CreateEntity", entity), func() error { return db.secondary.RestoreEntity(ctx, entity, mirrored) })
+	}
+	return err
+}
+
+func (db *DatabaseDualWrite) DeleteEntity(ctx context.Context, entity, id string) error {
+	err := db.primary.DeleteEntity(ctx, entity, id)
+	if err == nil {
+		db.mirror(dao.EntityMethod("DeleteEntity", entity), func() error { return db.secondary.DeleteEntity(ctx, entity, id) })
+	}
+	return err
+}
+
+func (db *DatabaseDualWrite) UpdateEntity(ctx context.Context, entity string, v interface{}) error {
+	err := db.primary.UpdateEntity(ctx, entity, v)
+	if err == nil {
+		mirrored := copyEntity(entity, v)
+		db.mirror(dao.EntityMethod("UpdateEntity", entity), func() error { return db.secondary.UpdateEntity(ctx, entity, mirrored) })
+	}
+	return err
+}
+
+func (db *DatabaseDualWrite) RestoreEntity(ctx context.Context, entity string, v interface{}) error {
+	err := db.primary.RestoreEntity(ctx, entity, v)
+	if err == nil {
+		mirrored := copyEntity(entity, v)
+		db.mirror(dao.EntityMethod("RestoreEntity", entity), func() error { return db.secondary.RestoreEntity(ctx, entity, mirrored) })
+	}
+	return err
+}
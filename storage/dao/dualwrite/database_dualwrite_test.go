@@ -0,0 +1,169 @@
+package dualwrite
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/adeo/turbine-go-api-skeleton/storage/dao"
+	"github.com/adeo/turbine-go-api-skeleton/storage/dao/daotest"
+	"github.com/adeo/turbine-go-api-skeleton/storage/dao/fake"
+	"github.com/adeo/turbine-go-api-skeleton/storage/model"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestDatabaseDualWriteConformance(t *testing.T) {
+	daotest.Run(t, func(t *testing.T) dao.Database {
+		return NewDatabaseDualWrite(fake.NewDatabaseFake("", "", 0), fake.NewDatabaseFake("", "", 0), true, "test", "conformance", "secondary")
+	})
+}
+
+func TestDatabaseDualWriteMirror(t *testing.T) {
+	ctx := context.Background()
+	primary, secondary := fake.NewDatabaseFake("", "", 0), fake.NewDatabaseFake("", "", 0)
+	db := NewDatabaseDualWrite(primary, secondary, false, "test", "mirror", "secondary")
+
+	template := &model.Template{TemplateEditable: model.TemplateEditable{Name: "template-1"}}
+	require.NoError(t, db.CreateEntity(ctx, "template", template))
+
+	// the secondary database keeps the id generated by the primary one
+	mirrored, err := secondary.GetEntityByID(ctx, "template", template.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "template-1", mirrored.(*model.Template).Name)
+
+	template.Name = "template-2"
+	require.NoError(t, db.UpdateEntity(ctx, "template", template))
+	mirrored, err = secondary.GetEntityByID(ctx, "template", template.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "template-2", mirrored.(*model.Template).Name)
+
+	require.NoError(t, db.DeleteEntity(ctx, "template", template.ID))
+	_, err = secondary.GetEntityByID(ctx, "template", template.ID)
+	assert.Error(t, err)
+}
+
+func TestDatabaseDualWriteSecondaryFailure(t *testing.T) {
+	ctx := context.Background()
+	primary, secondary := fake.NewDatabaseFake("", "", 0), fake.NewDatabaseFake("", "", 0)
+	db := NewDatabaseDualWrite(primary, secondary, false, "test", "failure", "secondary")
+
+	// the template already exists in the secondary database only, the write fails there
+	template := &model.Template{TemplateEditable: model.TemplateEditable{Name: "template-1"}}
+	require.NoError(t, secondary.CreateEntity(ctx, "template", template))
+	require.NoError(t, db.CreateEntity(ctx, "template", &model.Template{TemplateEditable: model.TemplateEditable{Name: "template-1"}}))
+
+	assert.Equal(t, float64(1), testutil.ToFloat64(writeErrors.WithLabelValues("failure", "secondary", dao.EntityMethod("CreateEntity", "template"))))
+}
+
+func TestDatabaseDualWriteShadowRead(t *testing.T) {
+	ctx := context.Background()
+	primary, secondary := fake.NewDatabaseFake("", "", 0), fake.NewDatabaseFake("", "", 0)
+	db := NewDatabaseDualWrite(primary, secondary, true, "test", "shadow", "secondary")
+
+	template := &model.Template{TemplateEditable: model.TemplateEditable{Name: "template-1"}}
+	require.NoError(t, db.CreateEntity(ctx, "template", template))
+	_, err := db.GetEntityByID(ctx, "template", template.ID)
+	require.NoError(t, err)
+	db.wait()
+	assert.Equal(t, float64(1), testutil.ToFloat64(shadowReads.WithLabelValues("shadow", "secondary", dao.EntityMethod("GetEntityByID", "template"), shadowReadMatch)))
+
+	// a write applied to the primary database only makes the databases diverge
+	template.Name = "template-2"
+	require.NoError(t, primary.UpdateEntity(ctx, "template", template))
+	_, err = db.GetEntityByID(ctx, "template", template.ID)
+	require.NoError(t, err)
+	require.NoError(t, primary.CreateEntity(ctx, "template", &model.Template{TemplateEditable: model.TemplateEditable{Name: "template-3"}}))
+	_, err = db.GetAllEntities(ctx, "template")
+	require.NoError(t, err)
+	db.wait()
+	assert.Equal(t, float64(1), testutil.ToFloat64(shadowReads.WithLabelValues("shadow", "secondary", dao.EntityMethod("GetEntityByID", "template"), shadowReadMismatch)))
+	assert.Equal(t, float64(1), testutil.ToFloat64(shadowReads.WithLabelValues("shadow", "secondary", dao.EntityMethod("GetAllEntities", "template"), shadowReadMismatch)))
+}
+
+func TestDatabaseDualWriteShadowReadSaturated(t *testing.T) {
+	ctx := context.Background()
+	primary, secondary := fake.NewDatabaseFake("", "", 0), fake.NewDatabaseFake("", "", 0)
+	db := NewDatabaseDualWrite(primary, secondary, true, "test", "saturated", "secondary")
+
+	template := &model.Template{TemplateEditable: model.TemplateEditable{Name: "template-1"}}
+	require.NoError(t, db.CreateEntity(ctx, "template", template))
+
+	// the running shadow reads hold all the slots, the next read is not compared
+	for i := 0; i < maxShadowReads; i++ {
+		db.shadowSlots <- struct{}{}
+	}
+	_, err := db.GetEntityByID(ctx, "template", template.ID)
+	require.NoError(t, err)
+	db.wait()
+	assert.Equal(t, float64(1), testutil.ToFloat64(shadowReads.WithLabelValues("saturated", "secondary", dao.EntityMethod("GetEntityByID", "template"), shadowReadDropped)))
+
+	<-db.shadowSlots
+	_, err = db.GetEntityByID(ctx, "template", template.ID)
+	require.NoError(t, err)
+	db.wait()
+	assert.Equal(t, float64(1), testutil.ToFloat64(shadowReads.WithLabelValues("saturated", "secondary", dao.EntityMethod("GetEntityByID", "template"), shadowReadMatch)))
+}
+
+// objectIDs generates the ids of the templates like MongoDB
+type objectIDs struct {
+	dao.Database
+}
+
+func (db *objectIDs) CreateEntity(ctx context.Context, entity string, v interface{}) error {
+	template := v.(*model.Template)
+	template.ID = primitive.NewObjectID().Hex()
+	template.CreatedAt = time.Now()
+	return db.Database.RestoreEntity(ctx, entity, template)
+}
+
+func TestDatabaseDualWriteIDs(t *testing.T) {
+	ctx := context.Background()
+
+	// a PostgreSQL table with a uuid id column rejects the MongoDB ids
+	secondary := fake.NewDatabaseFake("", "", 0)
+	db := NewDatabaseDualWrite(&objectIDs{fake.NewDatabaseFake("", "", 0)}, daotest.UUIDIDs(secondary), false, "test", "ids", "uuid")
+	require.NoError(t, db.CreateEntity(ctx, "template", &model.Template{TemplateEditable: model.TemplateEditable{Name: "template-1"}}))
+	assert.Equal(t, float64(1), testutil.ToFloat64(writeErrors.WithLabelValues("ids", "uuid", dao.EntityMethod("CreateEntity", "template"))))
+
+	// once migrated to a text id column, the secondary database keeps them
+	db = NewDatabaseDualWrite(&objectIDs{fake.NewDatabaseFake("", "", 0)}, secondary, true, "test", "ids", "text")
+	template := &model.Template{TemplateEditable: model.TemplateEditable{Name: "template-2"}}
+	require.NoError(t, db.CreateEntity(ctx, "template", template))
+	assert.Equal(t, float64(0), testutil.ToFloat64(writeErrors.WithLabelValues("ids", "text", dao.EntityMethod("CreateEntity", "template"))))
+	mirrored, err := secondary.GetEntityByID(ctx, "template", template.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "template-2", mirrored.(*model.Template).Name)
+
+	_, err = db.GetEntityByID(ctx, "template", template.ID)
+	require.NoError(t, err)
+	db.wait()
+	assert.Equal(t, float64(1), testutil.ToFloat64(shadowReads.WithLabelValues("ids", "text", dao.EntityMethod("GetEntityByID", "template"), shadowReadMatch)))
+}
+
+// pinger is a database whose ping returns err
+type pinger struct {
+	dao.Database
+	err error
+}
+
+func (db *pinger) Ping(ctx context.Context) error {
+	return db.err
+}
+
+func TestDatabaseDualWritePing(t *testing.T) {
+	up := &pinger{Database: fake.NewDatabaseFake("", "", 0)}
+	down := &pinger{Database: fake.NewDatabaseFake("", "", 0), err: errors.New("connection refused")}
+
+	assert.NoError(t, NewDatabaseDualWrite(up, up, false, "test", "ping", "secondary").Ping(context.Background()))
+
+	err := NewDatabaseDualWrite(down, up, false, "test", "ping", "secondary").Ping(context.Background())
+	assert.EqualError(t, err, "primary database: connection refused")
+
+	err = NewDatabaseDualWrite(up, down, false, "test", "ping", "secondary").Ping(context.Background())
+	assert.EqualError(t, err, "secondary database: connection refused")
+	assert.True(t, errors.Is(err, down.err))
+}
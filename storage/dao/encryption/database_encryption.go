@@ -0,0 +1,57 @@
+package encryption
+
+import (
+	"context"
+	"time"
+
+	"github.com/adeo/turbine-go-api-skeleton/storage/dao"
+	"github.com/satori/go.uuid"
+)
+
+// DatabaseEncryption is a decorator of a database encrypting the fields tagged with encrypt:"true" of the
+// written entities, and decrypting them in the read ones. The encrypted fields can't be used in the queries
+// nor in the unique indexes, their values being randomized.
+type DatabaseEncryption struct {
+	db      dao.Database
+	keyring *Keyring
+}
+
+// NewDatabaseEncryption returns a decorator of the given database encrypting the fields with the keyring
+func NewDatabaseEncryption(db dao.Database, keyring *Keyring) *DatabaseEncryption {
+	return &DatabaseEncryption{
+		db:      db,
+		keyring: keyring,
+	}
+}
+
+// owner returns the owner of the encrypted values of the entity having the given id, in the tenant of the call
+func owner(ctx context.Context, entity, id string) Owner {
+	return Owner{Entity: entity, Tenant: dao.TenantFromContext(ctx), ID: id}
+}
+
+// write encrypts the fields of v, the value of the owner, in place, calls write, then decrypts them back for the
+// caller, whatever the result
+func (db *DatabaseEncryption) write(owner Owner, v interface{}, write func() error) error {
+	if err := db.keyring.EncryptFields(owner, v); err != nil {
+		_ = db.keyring.DecryptFields(owner, v)
+		return err
+	}
+	err := write()
+	if errDecrypt := db.keyring.DecryptFields(owner, v); err == nil {
+		err = errDecrypt
+	}
+	return err
+}
+
+// create creates v, a value of the entity, with create. The values having encrypted fields need their id to
+// encrypt them: they are given a uuid and a creation date by setIdentity, then inserted with restore, which keeps
+// them.
+func (db *DatabaseEncryption) create(ctx context.Context, entity string, v interface{}, setIdentity func(id string, createdAt time.Time), create, restore func() error) error {
+	if !hasEncryptedFields(v) {
+		return create()
+	}
+	id := uuid.NewV4().String()
+	// with the precision of all the backends, the created value being returned as stored
+	setIdentity(id, time.Now().Truncate(time.Millisecond))
+	return db.write(owner(ctx, entity, id), v, restore)
+}
@@ -0,0 +1,129 @@
+package encryption
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/adeo/turbine-go-api-skeleton/storage/dao"
+)
+
+// entityOwner returns the owner of the encrypted values of v, a value of the registered entity
+func entityOwner(ctx context.Context, entity string, v interface{}) (Owner, error) {
+	e, err := dao.LookupEntity(entity)
+	if err != nil {
+		return Owner{}, err
+	}
+	return owner(ctx, entity, e.ID(v)), nil
+}
+
+// decryptEntity decrypts the fields of v, a value of the entity, in place
+func (db *DatabaseEncryption) decryptEntity(ctx context.Context, entity string, v interface{}) error {
+	o, err := entityOwner(ctx, entity, v)
+	if err != nil {
+		return err
+	}
+	return db.keyring.DecryptFields(o, v)
+}
+
+// decryptAll decrypts the fields of the values of the entity in place
+func (db *DatabaseEncryption) decryptAll(ctx context.Context, entity string, values []interface{}) error {
+	for _, v := range values {
+		if err := db.decryptEntity(ctx, entity, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeEntity encrypts the fields of v, a value of the entity, in place, calls write, then decrypts them back
+func (db *DatabaseEncryption) writeEntity(ctx context.Context, entity string, v interface{}, write func() error) error {
+	o, err := entityOwner(ctx, entity, v)
+	if err != nil {
+		return err
+	}
+	return db.write(o, v, write)
+}
+
+func (db *DatabaseEncryption) GetAllEntities(ctx context.Context, entity string) ([]interface{}, error) {
+	values, err := db.db.GetAllEntities(ctx, entity)
+	if err != nil {
+		return nil, err
+	}
+	return values, db.decryptAll(ctx, entity, values)
+}
+
+func (db *DatabaseEncryption) GetEntityByID(ctx context.Context, entity, id string) (interface{}, error) {
+	v, err := db.db.GetEntityByID(ctx, entity, id)
+	if err != nil {
+		return nil, err
+	}
+	return v, db.decryptEntity(ctx, entity, v)
+}
+
+func (db *DatabaseEncryption) CreateEntity(ctx context.Context, entity string, v interface{}) error {
+	e, err := dao.LookupEntity(entity)
+	if err != nil {
+		return err
+	}
+	return db.create(ctx, entity, v,
+		func(id string, createdAt time.Time) {
+			e.SetID(v, id)
+			e.SetCreatedAt(v, createdAt)
+		},
+		func() error { return db.db.CreateEntity(ctx, entity, v) },
+		func() error { return db.db.RestoreEntity(ctx, entity, v) })
+}
+
+func (db *DatabaseEncryption) DeleteEntity(ctx context.Context, entity, id string) error {
+	return db.db.DeleteEntity(ctx, entity, id)
+}
+
+func (db *DatabaseEncryption) UpdateEntity(ctx context.Context, entity string, v interface{}) error {
+	return db.writeEntity(ctx, entity, v, func() error {
+		return db.db.UpdateEntity(ctx, entity, v)
+	})
+}
+
+func (db *DatabaseEncryption) GetEntitiesAfter(ctx context.Context, entity, afterID string, limit int) ([]interface{}, error) {
+	values, err := db.db.GetEntitiesAfter(ctx, entity, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	return values, db.decryptAll(ctx, entity, values)
+}
+
+func (db *DatabaseEncryption) RestoreEntity(ctx context.Context, entity string, v interface{}) error {
+	return db.writeEntity(ctx, entity, v, func() error {
+		return db.db.RestoreEntity(ctx, entity, v)
+	})
+}
+
+// entityRegistered re-encrypts the values of a registered entity
+func entityRegistered(e dao.Entity) entity {
+	return entity{
+		name: e.Name,
+		reencrypt: func(ctx context.Context, db *DatabaseEncryption, afterID string, limit int) (int, int, string, error) {
+			// the page is read from the wrapped database, to see which key encrypted the fields
+			values, err := db.db.GetEntitiesAfter(ctx, e.Name, afterID, limit)
+			if err != nil || len(values) == 0 {
+				return 0, 0, afterID, err
+			}
+			reencrypted := 0
+			for _, v := range values {
+				if db.keyring.IsCurrentFields(v) {
+					continue
+				}
+				if err := db.keyring.DecryptFields(owner(ctx, e.Name, e.ID(v)), v); err != nil {
+					return len(values), reencrypted, afterID, err
+				}
+				// deleted since read otherwise
+				if err := db.UpdateEntity(ctx, e.Name, v); err != nil && !errors.Is(err, dao.ErrNotFound) {
+					return len(values), reencrypted, afterID, err
+				}
+				reencrypted++
+			}
+			return len(values), reencrypted, e.ID(values[len(values)-1]), nil
+		},
+	}
+}
@@ -0,0 +1,105 @@
+package encryption
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adeo/turbine-go-api-skeleton/storage/dao"
+	"github.com/adeo/turbine-go-api-skeleton/storage/dao/daotest"
+	"github.com/adeo/turbine-go-api-skeleton/storage/dao/fake"
+	"github.com/adeo/turbine-go-api-skeleton/storage/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// entitySecret is a registered entity having an encrypted field
+const entitySecret = "encryption_secret"
+
+type secretModel struct {
+	ID        string     `json:"id" bson:"_id"`
+	TenantID  string     `json:"tenant_id,omitempty" bson:"tenant_id"`
+	CreatedAt time.Time  `json:"created_at" bson:"created_at"`
+	UpdatedAt *time.Time `json:"updated_at" bson:"updated_at"`
+	Secret    string     `json:"secret" bson:"secret" encrypt:"true"`
+}
+
+func init() {
+	dao.RegisterEntity(entitySecret, secretModel{})
+}
+
+func newTestDatabase(t *testing.T) *DatabaseEncryption {
+	keyring, err := NewKeyring(map[string]string{"k1": key1}, "")
+	require.NoError(t, err)
+	return NewDatabaseEncryption(fake.NewDatabaseFake("", "", 0), keyring)
+}
+
+func TestDatabaseEncryption(t *testing.T) {
+	daotest.Run(t, func(t *testing.T) dao.Database {
+		return newTestDatabase(t)
+	})
+}
+
+func TestDatabaseEncryptionWrite(t *testing.T) {
+	db := newTestDatabase(t)
+
+	v := &secretEntity{secretEditable: secretEditable{Secret: "secret"}}
+	var stored string
+	err := db.write(secretOwner, v, func() error {
+		stored = v.Secret
+		return nil
+	})
+	require.NoError(t, err)
+	assert.NotEqual(t, "secret", stored, "the field must be encrypted when written")
+	assert.Equal(t, "secret", v.Secret, "the field must be decrypted back for the caller")
+}
+
+func TestDatabaseEncryptionCreate(t *testing.T) {
+	db := newTestDatabase(t)
+	ctx := dao.WithTenant(context.Background(), "t1")
+
+	first := &secretModel{Secret: "first"}
+	require.NoError(t, db.CreateEntity(ctx, entitySecret, first))
+	assert.NotEmpty(t, first.ID, "the id must be generated before the encryption")
+	assert.False(t, first.CreatedAt.IsZero())
+	assert.Equal(t, "first", first.Secret)
+
+	stored, err := db.db.GetEntityByID(ctx, entitySecret, first.ID)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(stored.(*secretModel).Secret, prefix))
+	found, err := db.GetEntityByID(ctx, entitySecret, first.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "first", found.(*secretModel).Secret)
+
+	// the encrypted value can't be copied to another row
+	second := &secretModel{Secret: "second"}
+	require.NoError(t, db.CreateEntity(ctx, entitySecret, second))
+	copied := *stored.(*secretModel)
+	copied.ID = second.ID
+	require.NoError(t, db.db.UpdateEntity(ctx, entitySecret, &copied))
+	_, err = db.GetEntityByID(ctx, entitySecret, second.ID)
+	assert.True(t, errors.Is(err, ErrInvalidValue))
+
+	// the templates, without encrypted field, keep the ids generated by the database
+	template := &model.Template{TemplateEditable: model.TemplateEditable{Name: "template"}}
+	require.NoError(t, db.CreateEntity(ctx, "template", template))
+	assert.NotEmpty(t, template.ID)
+}
+
+func TestReencrypt(t *testing.T) {
+	db := newTestDatabase(t)
+	ctx := context.Background()
+	for i := 0; i < 5; i++ {
+		template := &model.Template{TemplateEditable: model.TemplateEditable{Name: fmt.Sprintf("template-%d", i)}}
+		require.NoError(t, db.CreateEntity(ctx, "template", template))
+	}
+
+	reports, err := Reencrypt(ctx, db, 2)
+	require.NoError(t, err)
+	require.Len(t, reports, len(reencryptedEntities()))
+	// the entities are re-encrypted by name, the templates last
+	assert.Equal(t, Report{Entity: "template", Scanned: 5}, reports[len(reports)-1])
+}
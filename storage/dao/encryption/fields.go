@@ -0,0 +1,109 @@
+package encryption
+
+import (
+	"reflect"
+	"sync"
+)
+
+const (
+	tagName  = "encrypt"
+	tagValue = "true"
+)
+
+// encryptedField is a string field tagged with encrypt:"true"
+type encryptedField struct {
+	index []int
+	// path names the field in the additional data of its values: the names of the field and of its nested
+	// structs, separated by dots, the embedded structs being omitted, eg. Address.Street
+	path string
+}
+
+// fieldPaths caches the encrypted fields of each struct type
+var fieldPaths sync.Map // map[reflect.Type][]encryptedField
+
+// encryptedFields returns the string fields tagged with encrypt:"true" in the given struct type, looking into
+// the embedded and nested structs
+func encryptedFields(t reflect.Type) []encryptedField {
+	if fields, ok := fieldPaths.Load(t); ok {
+		return fields.([]encryptedField)
+	}
+
+	fields := make([]encryptedField, 0)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" && !field.Anonymous {
+			continue // unexported
+		}
+		switch {
+		case field.Tag.Get(tagName) == tagValue && (field.Type.Kind() == reflect.String || field.Type.Kind() == reflect.Ptr && field.Type.Elem().Kind() == reflect.String):
+			fields = append(fields, encryptedField{index: []int{i}, path: field.Name})
+		case field.Type.Kind() == reflect.Struct:
+			for _, nested := range encryptedFields(field.Type) {
+				path := nested.path
+				if !field.Anonymous {
+					path = field.Name + "." + path
+				}
+				fields = append(fields, encryptedField{index: append([]int{i}, nested.index...), path: path})
+			}
+		}
+	}
+	fieldPaths.Store(t, fields)
+	return fields
+}
+
+// transformFields replaces the value of each encrypted field of the struct pointed by v by the result of fn,
+// called with the path of the field. The values which are not pointers to structs are ignored.
+func transformFields(v interface{}, fn func(path, value string) (string, error)) error {
+	value := reflect.ValueOf(v)
+	if value.Kind() != reflect.Ptr || value.IsNil() || value.Elem().Kind() != reflect.Struct {
+		return nil
+	}
+	value = value.Elem()
+
+	for _, f := range encryptedFields(value.Type()) {
+		field := value.FieldByIndex(f.index)
+		if field.Kind() == reflect.Ptr {
+			if field.IsNil() {
+				continue
+			}
+			field = field.Elem()
+		}
+		result, err := fn(f.path, field.String())
+		if err != nil {
+			return err
+		}
+		field.SetString(result)
+	}
+	return nil
+}
+
+// hasEncryptedFields tells if v is a pointer to a struct having encrypted fields
+func hasEncryptedFields(v interface{}) bool {
+	t := reflect.TypeOf(v)
+	return t != nil && t.Kind() == reflect.Ptr && t.Elem().Kind() == reflect.Struct && len(encryptedFields(t.Elem())) > 0
+}
+
+// EncryptFields encrypts with the current key the encrypted fields of the struct pointed by v, the value of the
+// given owner, in place
+func (k *Keyring) EncryptFields(owner Owner, v interface{}) error {
+	return transformFields(v, func(path, value string) (string, error) {
+		return k.Encrypt(value, owner, path)
+	})
+}
+
+// DecryptFields decrypts the encrypted fields of the struct pointed by v, the value of the given owner, in place
+func (k *Keyring) DecryptFields(owner Owner, v interface{}) error {
+	return transformFields(v, func(path, value string) (string, error) {
+		return k.Decrypt(value, owner, path)
+	})
+}
+
+// IsCurrentFields tells if all the encrypted fields of the struct pointed by v are encrypted with the current key
+func (k *Keyring) IsCurrentFields(v interface{}) bool {
+	current := true
+	_ = transformFields(v, func(_, value string) (string, error) {
+		current = current && k.IsCurrent(value)
+		return value, nil
+	})
+	return current
+}
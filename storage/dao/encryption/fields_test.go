@@ -0,0 +1,74 @@
+package encryption
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var secretOwner = Owner{Entity: "secret", ID: "id"}
+
+type secretEditable struct {
+	Secret string `encrypt:"true"`
+}
+
+type secretEntity struct {
+	secretEditable
+	ID        string
+	Optional  *string `encrypt:"true"`
+	Missing   *string `encrypt:"true"`
+	Public    string
+	Nested    secretEditable
+	CreatedAt time.Time
+}
+
+func TestEncryptedFieldPaths(t *testing.T) {
+	paths := make([]string, 0)
+	for _, f := range encryptedFields(reflect.TypeOf(secretEntity{})) {
+		paths = append(paths, f.path)
+	}
+	assert.Equal(t, []string{"Secret", "Optional", "Missing", "Nested.Secret"}, paths)
+}
+
+func TestFields(t *testing.T) {
+	k, err := NewKeyring(map[string]string{"k1": key1}, "")
+	require.NoError(t, err)
+
+	optional := "optional"
+	v := &secretEntity{
+		secretEditable: secretEditable{Secret: "secret"},
+		ID:             "id",
+		Optional:       &optional,
+		Public:         "public",
+		Nested:         secretEditable{Secret: "nested"},
+		CreatedAt:      time.Now(),
+	}
+	assert.False(t, k.IsCurrentFields(v))
+
+	require.NoError(t, k.EncryptFields(secretOwner, v))
+	assert.True(t, strings.HasPrefix(v.Secret, prefix), "the embedded fields must be encrypted")
+	assert.True(t, strings.HasPrefix(*v.Optional, prefix))
+	assert.True(t, strings.HasPrefix(v.Nested.Secret, prefix), "the nested fields must be encrypted")
+	assert.Nil(t, v.Missing)
+	assert.Equal(t, "id", v.ID)
+	assert.Equal(t, "public", v.Public)
+	assert.True(t, k.IsCurrentFields(v))
+
+	// the fields are bound to their path
+	moved := *v
+	moved.Nested.Secret = v.Secret
+	assert.True(t, errors.Is(k.DecryptFields(secretOwner, &moved), ErrInvalidValue))
+
+	require.NoError(t, k.DecryptFields(secretOwner, v))
+	assert.Equal(t, "secret", v.Secret)
+	assert.Equal(t, "optional", *v.Optional)
+	assert.Equal(t, "nested", v.Nested.Secret)
+
+	assert.NoError(t, k.EncryptFields(secretOwner, secretEntity{}), "the values which are not pointers must be ignored")
+	assert.NoError(t, k.EncryptFields(secretOwner, nil))
+}
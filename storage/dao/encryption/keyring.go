@@ -0,0 +1,163 @@
+// Package encryption provides a dao.Database decorator encrypting the model fields tagged with encrypt:"true"
+// before storing them, and decrypting them when reading them, with AES-GCM.
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+const (
+	// prefix starts the encrypted values, followed by the id of the key and the encrypted value:
+	// enc:v3:<key id>:<base64 of the nonce and the ciphertext>. The values are bound to their entity, its tenant
+	// and its id, their field and their key, see Owner.additionalData.
+	prefix = "enc:v3:"
+	// prefixV2 starts the values bound to their entity type, field and key only, which are read until they are
+	// re-encrypted
+	prefixV2 = "enc:v2:"
+	// prefixV1 starts the values encrypted without additional data, which are read until they are re-encrypted
+	prefixV1  = "enc:v1:"
+	separator = ":"
+)
+
+var (
+	// ErrUnknownKey is returned when decrypting a value encrypted with a key missing from the keyring
+	ErrUnknownKey = errors.New("encryption: unknown key")
+	// ErrInvalidValue is returned when decrypting a value which has not been encrypted by the keyring, or altered
+	ErrInvalidValue = errors.New("encryption: invalid encrypted value")
+
+	keyIDPattern = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,32}$`)
+)
+
+// Keyring holds the keys decrypting the values, by id, and the current key encrypting them.
+// The previous keys are kept to read the values encrypted before a rotation.
+type Keyring struct {
+	keys    map[string]cipher.AEAD
+	current string
+}
+
+// NewKeyring returns a keyring from the base64 encoded AES keys (16, 24 or 32 bytes) by id. The current key
+// encrypts the new values, it can be empty when there is only one key.
+func NewKeyring(keys map[string]string, current string) (*Keyring, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("encryption: no key given")
+	}
+	if current == "" && len(keys) == 1 {
+		for id := range keys {
+			current = id
+		}
+	}
+	if _, ok := keys[current]; !ok {
+		return nil, fmt.Errorf("encryption: the current key %q is not in the keys", current)
+	}
+
+	result := &Keyring{keys: make(map[string]cipher.AEAD, len(keys)), current: current}
+	for id, encoded := range keys {
+		if !keyIDPattern.MatchString(id) {
+			return nil, fmt.Errorf("encryption: invalid key id %q, it must only contain letters, digits, '_' and '-'", id)
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("encryption: the key %s is not base64 encoded: %v", id, err)
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, fmt.Errorf("encryption: key %s: %v", id, err)
+		}
+		result.keys[id], err = cipher.NewGCM(block)
+		if err != nil {
+			return nil, fmt.Errorf("encryption: key %s: %v", id, err)
+		}
+	}
+	return result, nil
+}
+
+// Owner identifies the entity owning encrypted values: the name of its type, its tenant and its id
+type Owner struct {
+	Entity string
+	Tenant string
+	ID     string
+}
+
+// additionalData is authenticated with the value of the field of the owner encrypted with the key, in the given
+// format, so that a value copied to another row, tenant, entity type or field, or relabeled with another key, can't
+// be decrypted. The values of the first format have none.
+func (o Owner) additionalData(version, field, keyID string) []byte {
+	switch version {
+	case prefix:
+		// the id, which may contain the separator, is the only part not restricted to letters, digits, '_' and '-'
+		return []byte(prefix + o.Entity + separator + o.Tenant + separator + o.ID + separator + field + separator + keyID)
+	case prefixV2:
+		return []byte(prefixV2 + o.Entity + separator + field + separator + keyID)
+	}
+	return nil
+}
+
+// Encrypt encrypts the value of the field of the owner with the current key, the empty values are kept as is
+func (k *Keyring) Encrypt(value string, owner Owner, field string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+	aead := k.keys[k.current]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(value), owner.additionalData(prefix, field, k.current))
+	return prefix + k.current + separator + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt decrypts the value of the field of the owner with the key it has been encrypted with. The values which
+// are not encrypted, stored before the encryption of their field, are returned as is.
+func (k *Keyring) Decrypt(value string, owner Owner, field string) (string, error) {
+	version, id, encoded, ok := split(value)
+	if !ok {
+		return value, nil
+	}
+	aead, ok := k.keys[id]
+	if !ok {
+		return "", fmt.Errorf("%w %s", ErrUnknownKey, id)
+	}
+	sealed, err := base64.RawStdEncoding.DecodeString(encoded)
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return "", ErrInvalidValue
+	}
+	data := owner.additionalData(version, field, id)
+	plaintext, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], data)
+	if err != nil {
+		return "", ErrInvalidValue
+	}
+	return string(plaintext), nil
+}
+
+// IsCurrent tells if the value is encrypted with the current key and the current format, or empty
+func (k *Keyring) IsCurrent(value string) bool {
+	if value == "" {
+		return true
+	}
+	version, id, _, ok := split(value)
+	return ok && version == prefix && id == k.current
+}
+
+// split returns the prefix, the key id and the encoded part of an encrypted value, ok is false if the value is
+// not encrypted
+func split(value string) (version, id, encoded string, ok bool) {
+	for _, p := range []string{prefix, prefixV2, prefixV1} {
+		if !strings.HasPrefix(value, p) {
+			continue
+		}
+		parts := strings.SplitN(strings.TrimPrefix(value, p), separator, 2)
+		if len(parts) != 2 {
+			return "", "", "", false
+		}
+		return p, parts[0], parts[1], true
+	}
+	return "", "", "", false
+}
@@ -0,0 +1,138 @@
+package encryption
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var user = Owner{Entity: "user", Tenant: "t1", ID: "1"}
+
+const (
+	key1 = "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY=" // 32 bytes
+	key2 = "ZmVkY2JhOTg3NjU0MzIxMA=="                     // 16 bytes
+)
+
+func TestNewKeyring(t *testing.T) {
+	k, err := NewKeyring(map[string]string{"k1": key1}, "")
+	require.NoError(t, err)
+	assert.Equal(t, "k1", k.current, "the only key must be the current one")
+
+	_, err = NewKeyring(nil, "")
+	assert.Error(t, err)
+	_, err = NewKeyring(map[string]string{"k1": key1, "k2": key2}, "")
+	assert.Error(t, err, "the current key must be given with several keys")
+	_, err = NewKeyring(map[string]string{"k1": key1}, "k2")
+	assert.Error(t, err)
+	_, err = NewKeyring(map[string]string{"k:1": key1}, "")
+	assert.Error(t, err, "the key id must not contain the separator")
+	_, err = NewKeyring(map[string]string{"k1": "not base64"}, "")
+	assert.Error(t, err)
+	_, err = NewKeyring(map[string]string{"k1": "c2hvcnQ="}, "")
+	assert.Error(t, err, "the key must have an AES size")
+}
+
+func TestKeyringEncryptDecrypt(t *testing.T) {
+	k, err := NewKeyring(map[string]string{"k1": key1}, "")
+	require.NoError(t, err)
+
+	encrypted, err := k.Encrypt("secret", user, "Email")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(encrypted, "enc:v3:k1:"))
+	assert.NotContains(t, encrypted, "secret")
+	other, _ := k.Encrypt("secret", user, "Email")
+	assert.NotEqual(t, encrypted, other, "the nonce must be random")
+
+	decrypted, err := k.Decrypt(encrypted, user, "Email")
+	require.NoError(t, err)
+	assert.Equal(t, "secret", decrypted)
+
+	empty, err := k.Encrypt("", user, "Email")
+	require.NoError(t, err)
+	assert.Equal(t, "", empty)
+
+	plaintext, err := k.Decrypt("stored before the encryption", user, "Email")
+	require.NoError(t, err)
+	assert.Equal(t, "stored before the encryption", plaintext)
+
+	_, err = k.Decrypt(encrypted[:len(encrypted)-2]+"AA", user, "Email")
+	assert.True(t, errors.Is(err, ErrInvalidValue))
+	_, err = k.Decrypt("enc:v3:unknown:AAAA", user, "Email")
+	assert.True(t, errors.Is(err, ErrUnknownKey))
+}
+
+func TestKeyringBinding(t *testing.T) {
+	k, err := NewKeyring(map[string]string{"k1": key1, "k2": key1}, "k1")
+	require.NoError(t, err)
+	encrypted, err := k.Encrypt("secret", user, "Email")
+	require.NoError(t, err)
+
+	// the value can't be moved to another entity type or field, nor relabeled with another key
+	_, err = k.Decrypt(encrypted, Owner{Entity: "customer", Tenant: "t1", ID: "1"}, "Email")
+	assert.True(t, errors.Is(err, ErrInvalidValue))
+	_, err = k.Decrypt(encrypted, user, "Phone")
+	assert.True(t, errors.Is(err, ErrInvalidValue))
+
+	// nor to another row, nor to another tenant
+	_, err = k.Decrypt(encrypted, Owner{Entity: "user", Tenant: "t1", ID: "2"}, "Email")
+	assert.True(t, errors.Is(err, ErrInvalidValue))
+	_, err = k.Decrypt(encrypted, Owner{Entity: "user", Tenant: "t2", ID: "1"}, "Email")
+	assert.True(t, errors.Is(err, ErrInvalidValue))
+	_, err = k.Decrypt(strings.Replace(encrypted, "enc:v3:k1:", "enc:v3:k2:", 1), user, "Email")
+	assert.True(t, errors.Is(err, ErrInvalidValue))
+}
+
+func TestKeyringV1(t *testing.T) {
+	k, err := NewKeyring(map[string]string{"k1": key1}, "")
+	require.NoError(t, err)
+
+	// a value encrypted without additional data
+	aead := k.keys["k1"]
+	nonce := make([]byte, aead.NonceSize())
+	encrypted := "enc:v1:k1:" + base64.RawStdEncoding.EncodeToString(aead.Seal(nonce, nonce, []byte("secret"), nil))
+
+	decrypted, err := k.Decrypt(encrypted, user, "Email")
+	require.NoError(t, err)
+	assert.Equal(t, "secret", decrypted)
+	assert.False(t, k.IsCurrent(encrypted), "the values without additional data must be re-encrypted")
+}
+
+func TestKeyringV2(t *testing.T) {
+	k, err := NewKeyring(map[string]string{"k1": key1}, "")
+	require.NoError(t, err)
+
+	// a value bound to its entity type, field and key only
+	aead := k.keys["k1"]
+	nonce := make([]byte, aead.NonceSize())
+	sealed := aead.Seal(nonce, nonce, []byte("secret"), []byte("enc:v2:user:Email:k1"))
+	encrypted := "enc:v2:k1:" + base64.RawStdEncoding.EncodeToString(sealed)
+
+	decrypted, err := k.Decrypt(encrypted, Owner{Entity: "user", Tenant: "t2", ID: "2"}, "Email")
+	require.NoError(t, err)
+	assert.Equal(t, "secret", decrypted)
+	assert.False(t, k.IsCurrent(encrypted), "the values without their id and tenant must be re-encrypted")
+}
+
+func TestKeyringRotation(t *testing.T) {
+	before, err := NewKeyring(map[string]string{"k1": key1}, "")
+	require.NoError(t, err)
+	encrypted, _ := before.Encrypt("secret", user, "Email")
+
+	after, err := NewKeyring(map[string]string{"k1": key1, "k2": key2}, "k2")
+	require.NoError(t, err)
+	assert.False(t, after.IsCurrent(encrypted))
+	assert.False(t, after.IsCurrent("plaintext"))
+	assert.True(t, after.IsCurrent(""))
+
+	decrypted, err := after.Decrypt(encrypted, user, "Email")
+	require.NoError(t, err)
+	assert.Equal(t, "secret", decrypted, "the values encrypted with the previous key must stay readable")
+
+	reencrypted, _ := after.Encrypt(decrypted, user, "Email")
+	assert.True(t, after.IsCurrent(reencrypted))
+	assert.True(t, strings.HasPrefix(reencrypted, "enc:v3:k2:"))
+}
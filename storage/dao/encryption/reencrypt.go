@@ -0,0 +1,57 @@
+package encryption
+
+import (
+	"context"
+
+	"github.com/adeo/turbine-go-api-skeleton/storage/dao"
+)
+
+// entity re-encrypts the entities of one type
+type entity struct {
+	name string
+	// reencrypt re-encrypts with the current key the entities of a page of at most limit entities after the
+	// given id, it returns the number of scanned and re-encrypted entities, and the id of the last scanned one
+	reencrypt func(ctx context.Context, db *DatabaseEncryption, afterID string, limit int) (scanned int, reencrypted int, lastID string, err error)
+}
+
+// reencryptedEntities returns the re-encrypted entities: the registered ones, sorted by name
+func reencryptedEntities() []entity {
+	var result []entity
+	for _, e := range dao.Entities() {
+		result = append(result, entityRegistered(e))
+	}
+	return result
+}
+
+// Report is the result of the re-encryption of an entity
+type Report struct {
+	Entity      string
+	Scanned     int
+	Reencrypted int
+}
+
+// Reencrypt encrypts with the current key of the keyring the fields of all the entities of the tenant of the
+// context which are encrypted with a previous key, or not encrypted yet. The entities are read by pages of
+// batchSize entities, and updated one by one.
+func Reencrypt(ctx context.Context, db *DatabaseEncryption, batchSize int) ([]Report, error) {
+	all := reencryptedEntities()
+	reports := make([]Report, 0, len(all))
+	for _, e := range all {
+		report := Report{Entity: e.name}
+		afterID := ""
+		for {
+			scanned, reencrypted, lastID, err := e.reencrypt(ctx, db, afterID, batchSize)
+			report.Scanned += scanned
+			report.Reencrypted += reencrypted
+			if err != nil {
+				return append(reports, report), err
+			}
+			if scanned < batchSize {
+				break
+			}
+			afterID = lastID
+		}
+		reports = append(reports, report)
+	}
+	return reports, nil
+}
@@ -0,0 +1,422 @@
+package dao
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrUnknownEntity is the cause of the errors of the generic methods called with an entity which is not registered
+var ErrUnknownEntity = errors.New("unknown entity")
+
+// EntityDatabase stores the entities registered with RegisterEntity, without code specific to each of them.
+// The values are pointers to the registered models (eg. *model.Product), the lists are []interface{} of them.
+// Every call is scoped to the tenant of the given context, like the other methods of Database.
+type EntityDatabase interface {
+	GetAllEntities(ctx context.Context, entity string) ([]interface{}, error)
+	GetEntityByID(ctx context.Context, entity, id string) (interface{}, error)
+	CreateEntity(ctx context.Context, entity string, v interface{}) error
+	DeleteEntity(ctx context.Context, entity, id string) error
+	// UpdateEntity replaces the fields of the entity which are not managed by the database
+	UpdateEntity(ctx context.Context, entity string, v interface{}) error
+	// GetEntitiesAfter returns at most limit entities ordered by id, after the given id (excluded) if not empty
+	GetEntitiesAfter(ctx context.Context, entity, afterID string, limit int) ([]interface{}, error)
+	// RestoreEntity inserts an entity keeping its id and timestamps, to copy it from another database
+	RestoreEntity(ctx context.Context, entity string, v interface{}) error
+}
+
+// the names of the fields managed by the databases, looked up in the models of the entities
+const (
+	fieldID        = "ID"
+	fieldTenantID  = "TenantID"
+	fieldCreatedAt = "CreatedAt"
+	fieldUpdatedAt = "UpdatedAt"
+	fieldExpiresAt = "ExpiresAt"
+
+	// editableSuffix ends the name of the embedded struct holding the fields sent by the clients, eg. ProductEditable
+	editableSuffix = "Editable"
+)
+
+var (
+	typeString  = reflect.TypeOf("")
+	typeTime    = reflect.TypeOf(time.Time{})
+	typeTimePtr = reflect.TypeOf(&time.Time{})
+)
+
+// Field is a field of the model of an entity, the fields of the embedded structs being flattened
+type Field struct {
+	Name    string // name of the Go field
+	Index   []int  // index of the field in the model, for reflect.Value.FieldByIndex
+	Type    reflect.Type
+	JSON    string // name in the JSON form of the model
+	BSON    string // name in the mongodb documents
+	Column  string // name of the postgresql column: the db tag, or the JSON name
+	Managed bool   // set by the databases: ID, TenantID, CreatedAt and UpdatedAt
+}
+
+// Entity is a model stored by the generic methods of EntityDatabase. Its model is a struct having the fields
+// managed by the databases, possibly in embedded structs:
+//
+//	ID        string     `json:"id" bson:"_id"`
+//	TenantID  string     `json:"tenant_id,omitempty" bson:"tenant_id"`
+//	CreatedAt time.Time  `json:"created_at" bson:"created_at"`
+//	UpdatedAt *time.Time `json:"updated_at" bson:"updated_at"`
+//
+// and optionally an ExpiresAt *time.Time field, the date after which the entity is removed.
+type Entity struct {
+	Name     string       // singular lower case name, eg. product: name of the table, collection, route and ttl
+	Type     reflect.Type // the model struct
+	Unique   []string     // JSON names of the fields unique by tenant
+	Fields   []Field
+	Editable reflect.Type // the embedded <Model>Editable struct, nil when the model has none
+
+	editable                                []int
+	id, tenantID, createdAt, updatedAt, exp []int
+}
+
+var (
+	entitiesMu sync.RWMutex
+	entities   = make(map[string]Entity)
+)
+
+// RegisterEntity makes the model, given by a value of its type, available to the generic methods under the given
+// name, with unique indexes on the given JSON fields. It is meant to be called from an init function, and panics
+// if the model does not have the managed fields or if the name is already registered.
+func RegisterEntity(name string, model interface{}, unique ...string) {
+	entity, err := newEntity(name, reflect.TypeOf(model), unique)
+	if err != nil {
+		panic("dao: RegisterEntity " + name + ": " + err.Error())
+	}
+
+	entitiesMu.Lock()
+	defer entitiesMu.Unlock()
+	if _, exists := entities[name]; exists {
+		panic("dao: RegisterEntity called twice for entity " + name)
+	}
+	entities[name] = entity
+}
+
+// LookupEntity returns the registered entity with the given name, or an error wrapping ErrUnknownEntity
+func LookupEntity(name string) (Entity, error) {
+	entitiesMu.RLock()
+	defer entitiesMu.RUnlock()
+	entity, ok := entities[name]
+	if !ok {
+		return Entity{}, fmt.Errorf("%w %q", ErrUnknownEntity, name)
+	}
+	return entity, nil
+}
+
+// Entities returns the registered entities, sorted by name
+func Entities() []Entity {
+	entitiesMu.RLock()
+	defer entitiesMu.RUnlock()
+	result := make([]Entity, 0, len(entities))
+	for _, entity := range entities {
+		result = append(result, entity)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
+
+// EntityMethod names a generic method called for an entity, eg. GetAllEntities/product, in the metrics,
+// the logs and the chaos rules
+func EntityMethod(method, entity string) string {
+	return method + "/" + entity
+}
+
+func newEntity(name string, t reflect.Type, unique []string) (Entity, error) {
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return Entity{}, errors.New("the model must be a struct")
+	}
+
+	entity := Entity{Name: name, Type: t, Unique: unique}
+	entity.addFields(t, nil)
+
+	for _, managed := range []struct {
+		name     string
+		t        reflect.Type
+		index    *[]int
+		optional bool
+	}{
+		{fieldID, typeString, &entity.id, false},
+		{fieldTenantID, typeString, &entity.tenantID, false},
+		{fieldCreatedAt, typeTime, &entity.createdAt, false},
+		{fieldUpdatedAt, typeTimePtr, &entity.updatedAt, false},
+		{fieldExpiresAt, typeTimePtr, &entity.exp, true},
+	} {
+		field, ok := entity.field(func(f Field) bool { return f.Name == managed.name })
+		switch {
+		case !ok && managed.optional:
+			continue
+		case !ok:
+			return Entity{}, fmt.Errorf("no %s field", managed.name)
+		case field.Type != managed.t:
+			return Entity{}, fmt.Errorf("the %s field must be a %s", managed.name, managed.t)
+		}
+		*managed.index = field.Index
+	}
+	for _, u := range unique {
+		if _, ok := entity.FieldByJSON(u); !ok {
+			return Entity{}, fmt.Errorf("no field %s to make unique", u)
+		}
+	}
+	return entity, nil
+}
+
+// addFields adds the exported fields of the struct type, looking into its embedded structs
+func (e *Entity) addFields(t reflect.Type, index []int) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" && !f.Anonymous {
+			continue // unexported
+		}
+		fieldIndex := append(append([]int(nil), index...), i)
+		if f.Anonymous && f.Type.Kind() == reflect.Struct {
+			// an unexported editable struct can't be replaced, its fields are then copied one by one
+			if e.Editable == nil && f.PkgPath == "" && strings.HasSuffix(f.Type.Name(), editableSuffix) {
+				e.Editable, e.editable = f.Type, fieldIndex
+			}
+			e.addFields(f.Type, fieldIndex)
+			continue
+		}
+
+		field := Field{
+			Name:  f.Name,
+			Index: fieldIndex,
+			Type:  f.Type,
+			JSON:  tagName(f.Tag.Get("json"), f.Name),
+			BSON:  tagName(f.Tag.Get("bson"), strings.ToLower(f.Name)),
+		}
+		field.Column = tagName(f.Tag.Get("db"), field.JSON)
+		switch f.Name {
+		case fieldID, fieldTenantID, fieldCreatedAt, fieldUpdatedAt:
+			field.Managed = true
+		}
+		if field.JSON == "-" {
+			continue
+		}
+		e.Fields = append(e.Fields, field)
+	}
+}
+
+// tagName returns the name given by a struct tag, or the default one when the tag has no name
+func tagName(tag, defaultName string) string {
+	name := strings.Split(tag, ",")[0]
+	if name == "" {
+		return defaultName
+	}
+	return name
+}
+
+func (e Entity) field(match func(Field) bool) (Field, bool) {
+	for _, field := range e.Fields {
+		if match(field) {
+			return field, true
+		}
+	}
+	return Field{}, false
+}
+
+// FieldByJSON returns the field having the given name in the JSON form of the model
+func (e Entity) FieldByJSON(name string) (Field, bool) {
+	return e.field(func(f Field) bool { return f.JSON == name })
+}
+
+// Expires tells if the model has an ExpiresAt field
+func (e Entity) Expires() bool {
+	return e.exp != nil
+}
+
+// New returns a pointer to a new model
+func (e Entity) New() interface{} {
+	return reflect.New(e.Type).Interface()
+}
+
+// Slice returns the values as a slice of pointers to the model, eg. []*model.Product, for their encoding
+func (e Entity) Slice(values []interface{}) interface{} {
+	result := reflect.MakeSlice(reflect.SliceOf(reflect.PtrTo(e.Type)), 0, len(values))
+	for _, v := range values {
+		result = reflect.Append(result, reflect.ValueOf(v))
+	}
+	return result.Interface()
+}
+
+// value returns the model pointed by v, it panics if v is not a pointer to the model
+func (e Entity) value(v interface{}) reflect.Value {
+	value := reflect.ValueOf(v)
+	if value.Kind() != reflect.Ptr || value.Type().Elem() != e.Type {
+		panic(fmt.Sprintf("dao: %T is not a *%s", v, e.Type))
+	}
+	return value.Elem()
+}
+
+// Value returns the field of the model pointed by v
+func (e Entity) Value(v interface{}, field Field) reflect.Value {
+	return e.value(v).FieldByIndex(field.Index)
+}
+
+func (e Entity) ID(v interface{}) string {
+	return e.value(v).FieldByIndex(e.id).String()
+}
+
+func (e Entity) SetID(v interface{}, id string) {
+	e.value(v).FieldByIndex(e.id).SetString(id)
+}
+
+func (e Entity) TenantID(v interface{}) string {
+	return e.value(v).FieldByIndex(e.tenantID).String()
+}
+
+func (e Entity) SetTenantID(v interface{}, tenant string) {
+	e.value(v).FieldByIndex(e.tenantID).SetString(tenant)
+}
+
+func (e Entity) CreatedAt(v interface{}) time.Time {
+	return e.value(v).FieldByIndex(e.createdAt).Interface().(time.Time)
+}
+
+func (e Entity) SetCreatedAt(v interface{}, createdAt time.Time) {
+	e.value(v).FieldByIndex(e.createdAt).Set(reflect.ValueOf(createdAt))
+}
+
+func (e Entity) UpdatedAt(v interface{}) *time.Time {
+	return e.value(v).FieldByIndex(e.updatedAt).Interface().(*time.Time)
+}
+
+func (e Entity) SetUpdatedAt(v interface{}, updatedAt *time.Time) {
+	e.value(v).FieldByIndex(e.updatedAt).Set(reflect.ValueOf(updatedAt))
+}
+
+// ExpiresAt returns the expiry date of the entity, nil when it never expires or when the model has no ExpiresAt
+func (e Entity) ExpiresAt(v interface{}) *time.Time {
+	if e.exp == nil {
+		return nil
+	}
+	return e.value(v).FieldByIndex(e.exp).Interface().(*time.Time)
+}
+
+// SetExpiresAt sets the expiry date of the entity, if the model has an ExpiresAt field
+func (e Entity) SetExpiresAt(v interface{}, expiresAt *time.Time) {
+	if e.exp != nil {
+		e.value(v).FieldByIndex(e.exp).Set(reflect.ValueOf(expiresAt))
+	}
+}
+
+// NewEditable returns a pointer to a new editable struct, or to a new model when the model has none
+func (e Entity) NewEditable() interface{} {
+	if e.Editable == nil {
+		return e.New()
+	}
+	return reflect.New(e.Editable).Interface()
+}
+
+// SetEditable replaces the editable struct of the model pointed by v with the one pointed by editable, a value
+// returned by NewEditable. Without editable struct, all the fields which are not managed are replaced.
+func (e Entity) SetEditable(v, editable interface{}) {
+	if e.Editable == nil {
+		e.CopyEditable(v, editable)
+		return
+	}
+	e.value(v).FieldByIndex(e.editable).Set(reflect.ValueOf(editable).Elem())
+}
+
+// CopyEditable copies the fields which are not managed by the databases from the model pointed by src to the
+// one pointed by dst. The pointers, slices and maps are shared.
+func (e Entity) CopyEditable(dst, src interface{}) {
+	dstValue, srcValue := e.value(dst), e.value(src)
+	for _, field := range e.Fields {
+		if !field.Managed {
+			dstValue.FieldByIndex(field.Index).Set(srcValue.FieldByIndex(field.Index))
+		}
+	}
+}
+
+// Copy returns a shallow copy of the model pointed by v
+func (e Entity) Copy(v interface{}) interface{} {
+	result := reflect.New(e.Type)
+	result.Elem().Set(e.value(v))
+	return result.Interface()
+}
+
+// Comparable returns a deep copy of the model pointed by v whose timestamps have the precision of all the backends,
+// and without UpdatedAt if withUpdatedAt is false, as the databases set it independently. It shares nothing with
+// v, to be compared while v is changed.
+func (e Entity) Comparable(v interface{}, withUpdatedAt bool) interface{} {
+	result := reflect.New(e.Type)
+	result.Elem().Set(deepCopy(e.value(v)))
+	c := result.Interface()
+
+	e.SetCreatedAt(c, e.CreatedAt(c).UTC().Truncate(time.Millisecond))
+	e.SetUpdatedAt(c, truncate(e.UpdatedAt(c)))
+	if !withUpdatedAt {
+		e.SetUpdatedAt(c, nil)
+	}
+	e.SetExpiresAt(c, truncate(e.ExpiresAt(c)))
+	return c
+}
+
+// deepCopy returns a copy of v sharing no pointer, slice nor map with it. The unexported fields are shallow copies.
+func deepCopy(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.New(v.Elem().Type())
+		c.Elem().Set(deepCopy(v.Elem()))
+		return c
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.New(v.Type()).Elem()
+		c.Set(deepCopy(v.Elem()))
+		return c
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			c.Index(i).Set(deepCopy(v.Index(i)))
+		}
+		return c
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			c.SetMapIndex(iter.Key(), deepCopy(iter.Value()))
+		}
+		return c
+	case reflect.Struct:
+		c := reflect.New(v.Type()).Elem()
+		c.Set(v)
+		for i := 0; i < v.NumField(); i++ {
+			if c.Field(i).CanSet() {
+				c.Field(i).Set(deepCopy(v.Field(i)))
+			}
+		}
+		return c
+	}
+	return v
+}
+
+func truncate(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	result := t.UTC().Truncate(time.Millisecond)
+	return &result
+}
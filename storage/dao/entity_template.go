@@ -0,0 +1,8 @@
+package dao
+
+import "github.com/adeo/turbine-go-api-skeleton/storage/model"
+
+func init() {
+	// stored in the template table or collection, with a unique name by tenant
+	RegisterEntity("template", model.Template{}, "name")
+}
@@ -0,0 +1,108 @@
+package dao
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type EntityTestEditable struct {
+	Name      string     `json:"name"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+type EntityTest struct {
+	EntityTestEditable
+	ID        string     `json:"id"`
+	TenantID  string     `json:"tenant_id"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt *time.Time `json:"updated_at"`
+}
+
+func TestRegisterEntity(t *testing.T) {
+	RegisterEntity("entitytest", EntityTest{}, "name")
+	assert.Panics(t, func() { RegisterEntity("entitytest", EntityTest{}) }, "a name can't be registered twice")
+	assert.Panics(t, func() { RegisterEntity("entitytest-unique", EntityTest{}, "unknown") })
+	assert.Panics(t, func() { RegisterEntity("entitytest-managed", struct{ ID string }{}) })
+
+	entity, err := LookupEntity("entitytest")
+	require.NoError(t, err)
+	assert.True(t, entity.Expires())
+	assert.Equal(t, []string{"name"}, entity.Unique)
+	assert.Contains(t, Entities(), entity)
+
+	_, err = LookupEntity("entitytest-unknown")
+	assert.True(t, errors.Is(err, ErrUnknownEntity))
+}
+
+func TestEntityValues(t *testing.T) {
+	entity, err := newEntity("entitytest-values", reflect.TypeOf(EntityTest{}), nil)
+	require.NoError(t, err)
+
+	v := entity.New().(*EntityTest)
+	entity.SetID(v, "id-1")
+	entity.SetTenantID(v, "tenant")
+	assert.Equal(t, "id-1", entity.ID(v))
+	assert.Equal(t, "tenant", v.TenantID)
+
+	editable := entity.NewEditable().(*EntityTestEditable)
+	editable.Name = "name-1"
+	entity.SetEditable(v, editable)
+	assert.Equal(t, "name-1", v.Name)
+	assert.Equal(t, "id-1", v.ID, "the managed fields are kept")
+
+	copied := entity.Copy(v).(*EntityTest)
+	assert.Equal(t, v, copied)
+	assert.False(t, copied == v)
+}
+
+func TestEntityComparable(t *testing.T) {
+	type model struct {
+		ID         string
+		TenantID   string
+		CreatedAt  time.Time
+		UpdatedAt  *time.Time
+		Tags       []string
+		Attributes map[string]interface{}
+	}
+	entity, err := newEntity("entitytest-comparable", reflect.TypeOf(model{}), nil)
+	require.NoError(t, err)
+
+	createdAt := time.Date(2024, 1, 2, 3, 4, 5, 6789012, time.UTC)
+	v := &model{ID: "id-1", CreatedAt: createdAt, UpdatedAt: &createdAt,
+		Tags: []string{"a"}, Attributes: map[string]interface{}{"list": []interface{}{"b"}}}
+	c := entity.Comparable(v, false).(*model)
+	assert.Equal(t, createdAt.Truncate(time.Millisecond), c.CreatedAt)
+	assert.Nil(t, c.UpdatedAt)
+
+	// the comparable value shares nothing with the compared one
+	v.Tags[0] = "changed"
+	v.Attributes["list"].([]interface{})[0] = "changed"
+	assert.Equal(t, []string{"a"}, c.Tags)
+	assert.Equal(t, map[string]interface{}{"list": []interface{}{"b"}}, c.Attributes)
+}
+
+func TestEntityUnexportedEditable(t *testing.T) {
+	type unexportedEditable struct {
+		Name string `json:"name"`
+	}
+	type model struct {
+		unexportedEditable
+		ID        string
+		TenantID  string
+		CreatedAt time.Time
+		UpdatedAt *time.Time
+	}
+	entity, err := newEntity("entitytest-unexported", reflect.TypeOf(model{}), []string{"name"})
+	require.NoError(t, err)
+	assert.Nil(t, entity.Editable)
+
+	v := &model{ID: "id-1"}
+	entity.SetEditable(v, &model{unexportedEditable: unexportedEditable{Name: "name-1"}})
+	assert.Equal(t, "name-1", v.Name)
+	assert.Equal(t, "id-1", v.ID)
+}
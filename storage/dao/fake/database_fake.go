@@ -2,29 +2,87 @@ package fake
 
 import (
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
 
-	"github.com/coocood/freecache"
 	"github.com/adeo/turbine-go-api-skeleton/storage/dao"
-	"github.com/adeo/turbine-go-api-skeleton/storage/model"
 	"github.com/adeo/turbine-go-api-skeleton/utils"
 )
 
-const (
-	cacheMaxMemory = 32 * 1024 * 1024 // bytes
-)
-
+// DatabaseFake is an in memory database. Each entity is stored in a typed table, emulating the unique
+// indexes of the mongodb and postgresql databases. The stored entities are never shared with the callers:
+// they are copied on each read and write.
 type DatabaseFake struct {
-	Cache *freecache.Cache
+	mu sync.RWMutex
+
+	snapshotFile string
+	stop         chan struct{}
+	stopOnce     sync.Once        // Close may be called several times, eg. by a routing database and the handlers
+	purged       map[string]int64 // number of purged expired entities, by entity
+
+	tables map[string]*tableEntity // tables of the entities registered with dao.RegisterEntity, by name
+}
+
+// uniqueKey is the key of a unique index: as in the other databases, the unique indexes are scoped by tenant
+type uniqueKey struct {
+	tenant string
+	value  string
+}
+
+// URIPrefix prefixes the snapshot file of an in memory database in its connection uri, memory:// alone
+// being a database without snapshots
+const URIPrefix = "memory://"
+
+// Config is the driver setting of the in memory databases
+type Config struct {
+	ImportFile       string
+	SnapshotInterval time.Duration
+}
+
+func init() {
+	dao.Register("memory", open)
 }
 
-func NewDatabaseFake(file string) dao.Database {
-	result := &DatabaseFake{
-		Cache: freecache.NewCache(cacheMaxMemory),
+// open is the dao driver of the in memory databases, configured by a Config setting
+func open(opts dao.Options) (dao.Database, error) {
+	config := Config{}
+	expiry := dao.ExpiryConfig{}
+	for _, setting := range opts.Settings {
+		switch s := setting.(type) {
+		case Config:
+			config = s
+		case dao.ExpiryConfig:
+			expiry = s
+		}
+	}
+	db := NewDatabaseFake(config.ImportFile, strings.TrimPrefix(opts.ConnectionURI, URIPrefix), config.SnapshotInterval)
+	if expiry.PurgeInterval > 0 {
+		go db.(*DatabaseFake).purgeEvery(expiry.PurgeInterval)
 	}
+	return db, nil
+}
 
-	if file != "" {
-		data, err := ioutil.ReadFile(file)
+// NewDatabaseFake returns an in memory database. If snapshotFile is given, the database is reloaded from it
+// when it exists, and saved to it every snapshotInterval (if not zero) and when closing the database.
+// Otherwise the data are imported from importFile, if given.
+func NewDatabaseFake(importFile, snapshotFile string, snapshotInterval time.Duration) dao.Database {
+	result := newDatabaseFake()
+	result.snapshotFile = snapshotFile
+
+	if snapshotFile != "" {
+		if _, err := os.Stat(snapshotFile); err == nil {
+			utils.GetLogger().WithField("file", snapshotFile).Info("reloading in memory database from snapshot")
+			importFile = snapshotFile
+		}
+	}
+
+	if importFile != "" {
+		data, err := ioutil.ReadFile(importFile)
 		if err != nil {
 			utils.GetLogger().WithError(err).Error("error while loading data for in memory database")
 		}
@@ -35,31 +93,203 @@ func NewDatabaseFake(file string) dao.Database {
 			utils.GetLogger().WithError(err).Error("error while reading data from file for in memory database")
 		}
 
-		result.saveTemplates(export.Templates) // Template export
+		result.load(&export)
+	}
+
+	if snapshotFile != "" && snapshotInterval > 0 {
+		go result.snapshotEvery(snapshotInterval)
 	}
 
 	return result
 }
 
-func (db *DatabaseFake) save(key string, data []interface{}) {
-	b, err := json.Marshal(data)
-	if err != nil {
-		utils.GetLogger().WithError(err).Errorf("Error while marshal fake %s", key)
-		_ = db.Cache.Set([]byte(key), []byte("[]"), 0)
-		return
+func newDatabaseFake() *DatabaseFake {
+	return &DatabaseFake{
+		stop:   make(chan struct{}),
+		purged: make(map[string]int64),
+		tables: newTablesEntity(),
 	}
-	err = db.Cache.Set([]byte(key), b, 0)
-	if err != nil {
-		utils.GetLogger().WithError(err).Errorf("Error while saving fake %s", key)
+}
+
+// load replaces all the data of the database with the given export
+func (db *DatabaseFake) load(export *Export) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	for name, t := range db.tables {
+		values, err := t.decode(export.Entities[name])
+		if err != nil {
+			utils.GetLogger().WithError(err).Error("error while reading data from file for in memory database")
+		}
+		t.load(values)
 	}
 }
 
 type Export struct {
-	Templates []*model.Template // Template export
+	// Entities are the JSON arrays of the entities registered with dao.RegisterEntity, by name
+	Entities map[string]json.RawMessage `json:",omitempty"`
 }
 
 func (db *DatabaseFake) Export() *Export {
-	return &Export{
-		Templates: db.loadTemplates(), // Template export
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	export := &Export{
+		Entities: make(map[string]json.RawMessage, len(db.tables)),
+	}
+	for name, t := range db.tables {
+		data, err := t.export()
+		if err != nil {
+			utils.GetLogger().WithError(err).WithField("entity", name).Error("error while exporting in memory database")
+			continue
+		}
+		export.Entities[name] = data
+	}
+	return export
+}
+
+// Entity returns the data of the entity with the given name, as named in --db-entity-datasources, false if the
+// entity is unknown
+func (export *Export) Entity(name string) (interface{}, bool) {
+	data, ok := export.Entities[name]
+	return data, ok
+}
+
+// entities returns the values of the registered entities of the export, by name
+func (db *DatabaseFake) entities(export *Export) (map[string][]interface{}, error) {
+	for name := range export.Entities {
+		if _, ok := db.tables[name]; !ok {
+			return nil, fmt.Errorf("%w: unknown entity %s", ErrInvalidExport, name)
+		}
+	}
+	entities := make(map[string][]interface{}, len(db.tables))
+	for name, t := range db.tables {
+		values, err := t.decode(export.Entities[name])
+		if err != nil {
+			return nil, err
+		}
+		entities[name] = values
+	}
+	return entities, nil
+}
+
+// Import replaces all the data of the database with the given export, or merges it with the existing data
+// when merge is true: the imported entities replace the existing ones having the same id.
+// Nothing is imported if the result violates a unique index, or if the data of an entity can't be read.
+func (db *DatabaseFake) Import(export *Export, merge bool) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	entities, err := db.entities(export)
+	if err != nil {
+		return err
+	}
+
+	if merge {
+		for name, t := range db.tables {
+			entities[name] = t.merge(t.list(), entities[name])
+		}
+	}
+
+	for name, t := range db.tables {
+		if err := t.check(entities[name]); err != nil {
+			return err
+		}
+	}
+
+	for name, t := range db.tables {
+		t.load(entities[name])
+	}
+	return nil
+}
+
+// Snapshot saves the whole database to the snapshot file. The file is replaced atomically,
+// so that a crash during the snapshot never leaves a truncated file.
+func (db *DatabaseFake) Snapshot() error {
+	if db.snapshotFile == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(db.Export())
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(db.snapshotFile), filepath.Base(db.snapshotFile)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // no-op once renamed
+
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if errClose := tmp.Close(); err == nil {
+		err = errClose
 	}
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), db.snapshotFile)
+}
+
+func (db *DatabaseFake) snapshotEvery(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := db.Snapshot(); err != nil {
+				utils.GetLogger().WithError(err).Error("error while saving in memory database snapshot")
+			}
+		case <-db.stop:
+			return
+		}
+	}
+}
+
+// Purge removes the expired entities, they are already hidden from the reads
+func (db *DatabaseFake) Purge() {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	now := time.Now()
+	for name, t := range db.tables {
+		if count := t.purge(now); count > 0 {
+			db.purged[name] += int64(count)
+		}
+	}
+}
+
+func (db *DatabaseFake) purgeEvery(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			db.Purge()
+		case <-db.stop:
+			return
+		}
+	}
+}
+
+// PurgedCounts returns the number of expired entities purged since the database was created, by entity
+func (db *DatabaseFake) PurgedCounts() map[string]int64 {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	counts := make(map[string]int64, len(db.purged))
+	for entity, count := range db.purged {
+		counts[entity] = count
+	}
+	return counts
+}
+
+// Close stops the periodic snapshots and purges, and saves a last snapshot
+func (db *DatabaseFake) Close() error {
+	db.stopOnce.Do(func() { close(db.stop) })
+	return db.Snapshot()
 }
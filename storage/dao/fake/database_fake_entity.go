@@ -0,0 +1,431 @@
+package fake
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/adeo/turbine-go-api-skeleton/storage/dao"
+	"github.com/adeo/turbine-go-api-skeleton/utils"
+	"github.com/satori/go.uuid"
+)
+
+// ErrInvalidExport is the cause of the errors of the imports whose data can't be read
+var ErrInvalidExport = errors.New("invalid export")
+
+// tableEntity stores the values of a registered entity, with the unique indexes of the entity.
+// It is not safe for concurrent use, DatabaseFake.mu must be held.
+type tableEntity struct {
+	entity dao.Entity
+	rows   map[string]interface{}
+	ids    []string                        // ids in insertion order, to list the entities by creation date
+	unique map[string]map[uniqueKey]string // unique indexes on tenant and field, by JSON name of the field
+}
+
+func newTableEntity(entity dao.Entity) *tableEntity {
+	t := &tableEntity{
+		entity: entity,
+		rows:   make(map[string]interface{}),
+		ids:    make([]string, 0),
+		unique: make(map[string]map[uniqueKey]string),
+	}
+	for _, name := range entity.Unique {
+		t.unique[name] = make(map[uniqueKey]string)
+	}
+	return t
+}
+
+// newTablesEntity returns the tables of the entities registered when the database is created
+func newTablesEntity() map[string]*tableEntity {
+	tables := make(map[string]*tableEntity)
+	for _, entity := range dao.Entities() {
+		tables[entity.Name] = newTableEntity(entity)
+	}
+	return tables
+}
+
+// copyEntity returns a deep copy of the value pointed by v
+func copyEntity(v interface{}) interface{} {
+	return deepCopy(reflect.ValueOf(v)).Interface()
+}
+
+// deepCopy returns a deep copy of the value, the unexported fields being copied as they are (eg. in time.Time)
+func deepCopy(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return reflect.Zero(v.Type())
+		}
+		result := reflect.New(v.Type().Elem())
+		result.Elem().Set(deepCopy(v.Elem()))
+		return result
+	case reflect.Interface:
+		if v.IsNil() {
+			return reflect.Zero(v.Type())
+		}
+		result := reflect.New(v.Type()).Elem()
+		result.Set(deepCopy(v.Elem()))
+		return result
+	case reflect.Slice:
+		if v.IsNil() {
+			return reflect.Zero(v.Type())
+		}
+		result := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			result.Index(i).Set(deepCopy(v.Index(i)))
+		}
+		return result
+	case reflect.Map:
+		if v.IsNil() {
+			return reflect.Zero(v.Type())
+		}
+		result := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			result.SetMapIndex(deepCopy(iter.Key()), deepCopy(iter.Value()))
+		}
+		return result
+	case reflect.Array:
+		result := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.Len(); i++ {
+			result.Index(i).Set(deepCopy(v.Index(i)))
+		}
+		return result
+	case reflect.Struct:
+		result := reflect.New(v.Type()).Elem()
+		result.Set(v)
+		for i := 0; i < v.NumField(); i++ {
+			if result.Field(i).CanSet() {
+				result.Field(i).Set(deepCopy(v.Field(i)))
+			}
+		}
+		return result
+	}
+	return v
+}
+
+// keys returns the keys of the value in the unique indexes, by JSON name of the field
+func (t *tableEntity) keys(v interface{}) map[string]uniqueKey {
+	keys := make(map[string]uniqueKey, len(t.entity.Unique))
+	for _, name := range t.entity.Unique {
+		field, _ := t.entity.FieldByJSON(name)
+		value, _ := json.Marshal(t.entity.Value(v, field).Interface())
+		keys[name] = uniqueKey{tenant: t.entity.TenantID(v), value: string(value)}
+	}
+	return keys
+}
+
+// duplicate returns the unique field of the value already used by another entity than the one with the given id
+func (t *tableEntity) duplicate(keys map[string]uniqueKey, id string) (string, bool) {
+	for _, name := range t.entity.Unique {
+		if existing, ok := t.unique[name][keys[name]]; ok && existing != id {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// list returns the values of all the tenants
+func (t *tableEntity) list() []interface{} {
+	values := make([]interface{}, 0, len(t.ids))
+	for _, id := range t.ids {
+		values = append(values, copyEntity(t.rows[id]))
+	}
+	return values
+}
+
+// listTenant returns the values of the given tenant, except the expired ones
+func (t *tableEntity) listTenant(tenant string) []interface{} {
+	values := make([]interface{}, 0)
+	now := time.Now()
+	for _, id := range t.ids {
+		v := t.rows[id]
+		if t.entity.TenantID(v) == tenant && !dao.IsExpired(t.entity.ExpiresAt(v), now) {
+			values = append(values, copyEntity(v))
+		}
+	}
+	return values
+}
+
+// get returns the stored value with the given id, if it belongs to the given tenant and is not expired
+func (t *tableEntity) get(tenant, id string) (interface{}, bool) {
+	v, ok := t.rows[id]
+	if !ok || t.entity.TenantID(v) != tenant || dao.IsExpired(t.entity.ExpiresAt(v), time.Now()) {
+		return nil, false
+	}
+	return v, true
+}
+
+func (t *tableEntity) insert(v interface{}) {
+	id := t.entity.ID(v)
+	t.rows[id] = copyEntity(v)
+	t.ids = append(t.ids, id)
+	for name, key := range t.keys(v) {
+		t.unique[name][key] = id
+	}
+}
+
+func (t *tableEntity) remove(id string) {
+	for name, key := range t.keys(t.rows[id]) {
+		delete(t.unique[name], key)
+	}
+	delete(t.rows, id)
+	for i, existing := range t.ids {
+		if existing == id {
+			t.ids = append(t.ids[:i], t.ids[i+1:]...)
+			break
+		}
+	}
+}
+
+// purge removes the values expired at the given date, and returns how many were removed
+func (t *tableEntity) purge(now time.Time) int {
+	count := 0
+	for _, id := range append([]string(nil), t.ids...) {
+		if dao.IsExpired(t.entity.ExpiresAt(t.rows[id]), now) {
+			t.remove(id)
+			count++
+		}
+	}
+	return count
+}
+
+// load replaces all the values, ignoring the ones violating the unique indexes
+func (t *tableEntity) load(values []interface{}) {
+	*t = *newTableEntity(t.entity)
+	for _, v := range values {
+		id := t.entity.ID(v)
+		if id == "" {
+			id = uuid.NewV4().String()
+			t.entity.SetID(v, id)
+		}
+		if _, ok := t.rows[id]; ok {
+			utils.GetLogger().WithField("entity", t.entity.Name).WithField("id", id).Error("duplicated id in data to load, ignoring it")
+			continue
+		}
+		if field, ok := t.duplicate(t.keys(v), id); ok {
+			utils.GetLogger().WithField("entity", t.entity.Name).WithField("id", id).Errorf("duplicated %s in data to load, ignoring it", field)
+			continue
+		}
+		t.insert(v)
+	}
+}
+
+// check checks the values to import do not violate the unique indexes
+func (t *tableEntity) check(values []interface{}) error {
+	ids := make(map[string]bool)
+	unique := newTableEntity(t.entity).unique
+	for _, v := range values {
+		id := t.entity.ID(v)
+		if id != "" && ids[id] {
+			return dao.NewDAOError(dao.ErrTypeDuplicate, fmt.Errorf("duplicated %s id %s", t.entity.Name, id))
+		}
+		for name, key := range t.keys(v) {
+			if _, ok := unique[name][key]; ok {
+				return dao.NewDAOError(dao.ErrTypeDuplicate, fmt.Errorf("duplicated %s %s %s", t.entity.Name, name, key.value))
+			}
+			unique[name][key] = id
+		}
+		ids[id] = true
+	}
+	return nil
+}
+
+// merge returns the current values, with the imported ones replacing the ones having the same id
+func (t *tableEntity) merge(current, imported []interface{}) []interface{} {
+	positions := make(map[string]int)
+	for i, v := range current {
+		positions[t.entity.ID(v)] = i
+	}
+	for _, v := range imported {
+		if i, ok := positions[t.entity.ID(v)]; ok && t.entity.ID(v) != "" {
+			current[i] = v
+			continue
+		}
+		current = append(current, v)
+	}
+	return current
+}
+
+// export returns the JSON array of the values of all the tenants
+func (t *tableEntity) export() (json.RawMessage, error) {
+	return json.Marshal(t.entity.Slice(t.list()))
+}
+
+// decode returns the values of a JSON array of the entity, none when data is empty
+func (t *tableEntity) decode(data json.RawMessage) ([]interface{}, error) {
+	if len(data) == 0 {
+		return make([]interface{}, 0), nil
+	}
+	slice := reflect.New(reflect.SliceOf(reflect.PtrTo(t.entity.Type)))
+	if err := json.Unmarshal(data, slice.Interface()); err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrInvalidExport, t.entity.Name, err)
+	}
+	values := make([]interface{}, 0, slice.Elem().Len())
+	for i := 0; i < slice.Elem().Len(); i++ {
+		if v := slice.Elem().Index(i); !v.IsNil() {
+			values = append(values, v.Interface())
+		}
+	}
+	return values, nil
+}
+
+// table returns the table of the entity
+func (db *DatabaseFake) table(entity string) (*tableEntity, error) {
+	t, ok := db.tables[entity]
+	if !ok {
+		_, err := dao.LookupEntity(entity)
+		if err == nil {
+			err = fmt.Errorf("%w %q: registered after the creation of the database", dao.ErrUnknownEntity, entity)
+		}
+		return nil, err
+	}
+	return t, nil
+}
+
+func notFound(entity string) error {
+	return dao.NewDAOError(dao.ErrTypeNotFound, fmt.Errorf("%s not found", entity))
+}
+
+func alreadyExists(entity string) error {
+	return dao.NewDAOError(dao.ErrTypeDuplicate, fmt.Errorf("%s already exists", entity))
+}
+
+func (db *DatabaseFake) GetAllEntities(ctx context.Context, entity string) ([]interface{}, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	t, err := db.table(entity)
+	if err != nil {
+		return nil, err
+	}
+	return t.listTenant(dao.TenantFromContext(ctx)), nil
+}
+
+func (db *DatabaseFake) GetEntityByID(ctx context.Context, entity, id string) (interface{}, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	t, err := db.table(entity)
+	if err != nil {
+		return nil, err
+	}
+	v, ok := t.get(dao.TenantFromContext(ctx), id)
+	if !ok {
+		return nil, notFound(entity)
+	}
+	return copyEntity(v), nil
+}
+
+func (db *DatabaseFake) CreateEntity(ctx context.Context, entity string, v interface{}) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	t, err := db.table(entity)
+	if err != nil {
+		return err
+	}
+	t.entity.SetTenantID(v, dao.TenantFromContext(ctx))
+	if _, ok := t.duplicate(t.keys(v), ""); ok {
+		return alreadyExists(entity)
+	}
+
+	t.entity.SetID(v, uuid.NewV4().String())
+	t.entity.SetCreatedAt(v, time.Now())
+	t.entity.SetUpdatedAt(v, nil)
+	t.insert(v)
+	return nil
+}
+
+func (db *DatabaseFake) DeleteEntity(ctx context.Context, entity, id string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	t, err := db.table(entity)
+	if err != nil {
+		return err
+	}
+	if _, ok := t.get(dao.TenantFromContext(ctx), id); !ok {
+		return notFound(entity)
+	}
+	t.remove(id)
+	return nil
+}
+
+func (db *DatabaseFake) UpdateEntity(ctx context.Context, entity string, v interface{}) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	t, err := db.table(entity)
+	if err != nil {
+		return err
+	}
+	id := t.entity.ID(v)
+	found, ok := t.get(dao.TenantFromContext(ctx), id)
+	if !ok {
+		return notFound(entity)
+	}
+
+	updated := copyEntity(found)
+	t.entity.CopyEditable(updated, copyEntity(v))
+	now := time.Now()
+	t.entity.SetUpdatedAt(updated, &now)
+	if _, ok := t.duplicate(t.keys(updated), id); ok {
+		return alreadyExists(entity)
+	}
+
+	// the row keeps its position in the insertion order
+	for name, key := range t.keys(found) {
+		delete(t.unique[name], key)
+	}
+	for name, key := range t.keys(updated) {
+		t.unique[name][key] = id
+	}
+	t.rows[id] = updated
+
+	reflect.ValueOf(v).Elem().Set(reflect.ValueOf(copyEntity(updated)).Elem())
+	return nil
+}
+
+func (db *DatabaseFake) GetEntitiesAfter(ctx context.Context, entity, afterID string, limit int) ([]interface{}, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	t, err := db.table(entity)
+	if err != nil {
+		return nil, err
+	}
+	values := t.listTenant(dao.TenantFromContext(ctx))
+	sort.Slice(values, func(i, j int) bool { return t.entity.ID(values[i]) < t.entity.ID(values[j]) })
+	start := sort.Search(len(values), func(i int) bool { return t.entity.ID(values[i]) > afterID })
+	values = values[start:]
+	if len(values) > limit {
+		values = values[:limit]
+	}
+	return values, nil
+}
+
+func (db *DatabaseFake) RestoreEntity(ctx context.Context, entity string, v interface{}) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	t, err := db.table(entity)
+	if err != nil {
+		return err
+	}
+	t.entity.SetTenantID(v, dao.TenantFromContext(ctx))
+	id := t.entity.ID(v)
+	if _, ok := t.rows[id]; ok {
+		return alreadyExists(entity)
+	}
+	if _, ok := t.duplicate(t.keys(v), id); ok {
+		return alreadyExists(entity)
+	}
+
+	t.insert(v)
+	return nil
+}
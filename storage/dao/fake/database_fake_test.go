@@ -0,0 +1,204 @@
+package fake
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/adeo/turbine-go-api-skeleton/storage/dao"
+	"github.com/adeo/turbine-go-api-skeleton/storage/dao/daotest"
+	"github.com/adeo/turbine-go-api-skeleton/storage/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDatabaseFake(t *testing.T) {
+	daotest.Run(t, func(t *testing.T) dao.Database {
+		return NewDatabaseFake("", "", 0)
+	})
+}
+
+func TestDatabaseFakeConcurrentWrites(t *testing.T) {
+	db := NewDatabaseFake("", "", 0)
+
+	const count = 100
+	var wg sync.WaitGroup
+	for i := 0; i < count; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			err := db.CreateEntity(context.Background(), "template", &model.Template{
+				TemplateEditable: model.TemplateEditable{Name: fmt.Sprintf("template-%d", i)},
+			})
+			assert.NoError(t, err)
+		}(i)
+	}
+	wg.Wait()
+
+	templates, err := db.GetAllEntities(context.Background(), "template")
+	require.NoError(t, err)
+	assert.Len(t, templates, count)
+}
+
+// exportedTemplates returns the templates of the export
+func exportedTemplates(t *testing.T, export *Export) []*model.Template {
+	var templates []*model.Template
+	require.NoError(t, json.Unmarshal(export.Entities["template"], &templates))
+	return templates
+}
+
+// templatesExport returns an export of the given templates
+func templatesExport(t *testing.T, templates ...*model.Template) *Export {
+	data, err := json.Marshal(templates)
+	require.NoError(t, err)
+	return &Export{Entities: map[string]json.RawMessage{"template": data}}
+}
+
+func TestDatabaseFakeCopies(t *testing.T) {
+	db := NewDatabaseFake("", "", 0)
+
+	template := &model.Template{TemplateEditable: model.TemplateEditable{Name: "template-1"}}
+	require.NoError(t, db.CreateEntity(context.Background(), "template", template))
+
+	// modifying the created or the read entities must not modify the stored ones
+	template.Name = "modified"
+	found, err := db.GetEntityByID(context.Background(), "template", template.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "template-1", found.(*model.Template).Name)
+
+	found.(*model.Template).Name = "modified"
+	found, err = db.GetEntityByID(context.Background(), "template", template.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "template-1", found.(*model.Template).Name)
+}
+
+func TestDatabaseFakeExportImport(t *testing.T) {
+	db := newDatabaseFake()
+	require.NoError(t, db.CreateEntity(context.Background(), "template", &model.Template{TemplateEditable: model.TemplateEditable{Name: "template-1"}}))
+	require.NoError(t, db.CreateEntity(context.Background(), "template", &model.Template{TemplateEditable: model.TemplateEditable{Name: "template-2"}}))
+
+	export := db.Export()
+	require.Len(t, exportedTemplates(t, export), 2)
+
+	imported := newDatabaseFake()
+	imported.load(export)
+	assert.Equal(t, export, imported.Export())
+
+	// unique indexes are restored
+	err := imported.CreateEntity(context.Background(), "template", &model.Template{TemplateEditable: model.TemplateEditable{Name: "template-1"}})
+	require.Error(t, err)
+	assert.Equal(t, dao.ErrTypeDuplicate, err.(*dao.DAOError).Type)
+}
+
+func TestDatabaseFakeImport(t *testing.T) {
+	db := newDatabaseFake()
+	existing := &model.Template{TemplateEditable: model.TemplateEditable{Name: "template-1"}}
+	require.NoError(t, db.CreateEntity(context.Background(), "template", existing))
+
+	// merge: the template with the same id is replaced, the other one is added
+	replaced := *existing
+	replaced.Name = "template-1-bis"
+	err := db.Import(templatesExport(t,
+		&replaced,
+		&model.Template{ID: "id-2", TemplateEditable: model.TemplateEditable{Name: "template-2"}},
+	), true)
+	require.NoError(t, err)
+	templates, _ := db.GetAllEntities(context.Background(), "template")
+	require.Len(t, templates, 2)
+	assert.Equal(t, "template-1-bis", templates[0].(*model.Template).Name)
+	assert.Equal(t, "id-2", templates[1].(*model.Template).ID)
+
+	// merge violating a unique index: nothing is imported
+	err = db.Import(templatesExport(t,
+		&model.Template{ID: "id-3", TemplateEditable: model.TemplateEditable{Name: "template-2"}},
+	), true)
+	require.Error(t, err)
+	assert.Equal(t, dao.ErrTypeDuplicate, err.(*dao.DAOError).Type)
+	templates, _ = db.GetAllEntities(context.Background(), "template")
+	assert.Len(t, templates, 2)
+
+	// replace
+	err = db.Import(templatesExport(t,
+		&model.Template{ID: "id-3", TemplateEditable: model.TemplateEditable{Name: "template-3"}},
+	), false)
+	require.NoError(t, err)
+	templates, _ = db.GetAllEntities(context.Background(), "template")
+	require.Len(t, templates, 1)
+	assert.Equal(t, "id-3", templates[0].(*model.Template).ID)
+}
+
+func TestDatabaseFakeExportImportEntities(t *testing.T) {
+	db := newDatabaseFake()
+	ctx := context.Background()
+	item := &daotest.Item{ItemEditable: daotest.ItemEditable{Name: "item-1", Tags: []string{"a"}}}
+	require.NoError(t, db.CreateEntity(ctx, daotest.EntityItem, item))
+
+	export := db.Export()
+	require.Contains(t, export.Entities, daotest.EntityItem)
+	data, ok := export.Entity(daotest.EntityItem)
+	assert.True(t, ok)
+	assert.Equal(t, export.Entities[daotest.EntityItem], data)
+	for _, name := range []string{"unknown", "Entities", "templates"} {
+		_, ok = export.Entity(name)
+		assert.False(t, ok, name)
+	}
+
+	imported := newDatabaseFake()
+	require.NoError(t, imported.Import(export, false))
+	assert.Equal(t, export, imported.Export())
+	found, err := imported.GetEntityByID(ctx, daotest.EntityItem, item.ID)
+	require.NoError(t, err)
+	assert.Equal(t, item.ItemEditable, found.(*daotest.Item).ItemEditable)
+
+	// unique indexes are restored
+	err = imported.CreateEntity(ctx, daotest.EntityItem, &daotest.Item{ItemEditable: daotest.ItemEditable{Name: "item-1"}})
+	require.Error(t, err)
+	assert.Equal(t, dao.ErrTypeDuplicate, err.(*dao.DAOError).Type)
+
+	// unknown entities and invalid rows are rejected
+	err = imported.Import(&Export{Entities: map[string]json.RawMessage{"unknown": json.RawMessage("[]")}}, true)
+	assert.True(t, errors.Is(err, ErrInvalidExport), "unexpected error: %v", err)
+	err = imported.Import(&Export{Entities: map[string]json.RawMessage{daotest.EntityItem: json.RawMessage("{}")}}, true)
+	assert.True(t, errors.Is(err, ErrInvalidExport), "unexpected error: %v", err)
+}
+
+func TestDatabaseFakeSnapshot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "database_fake")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "snapshot.json")
+
+	db := NewDatabaseFake("", file, 0).(*DatabaseFake)
+	require.NoError(t, db.CreateEntity(context.Background(), "template", &model.Template{TemplateEditable: model.TemplateEditable{Name: "template-1"}}))
+	require.NoError(t, db.Close())
+	require.NoError(t, db.Close(), "closing twice must not panic")
+
+	reloaded := NewDatabaseFake("", file, 0).(*DatabaseFake)
+	expected, _ := json.Marshal(db.Export())
+	actual, _ := json.Marshal(reloaded.Export())
+	assert.JSONEq(t, string(expected), string(actual))
+}
+
+func TestDatabaseFakePurge(t *testing.T) {
+	db := newDatabaseFake()
+	expiredAt := time.Now().Add(-time.Minute)
+	expiresAt := time.Now().Add(time.Hour)
+	require.NoError(t, db.CreateEntity(context.Background(), "template", &model.Template{TemplateEditable: model.TemplateEditable{Name: "template-1", ExpiresAt: &expiredAt}}))
+	require.NoError(t, db.CreateEntity(context.Background(), "template", &model.Template{TemplateEditable: model.TemplateEditable{Name: "template-2", ExpiresAt: &expiresAt}}))
+	require.NoError(t, db.CreateEntity(context.Background(), "template", &model.Template{TemplateEditable: model.TemplateEditable{Name: "template-3"}}))
+	require.Len(t, exportedTemplates(t, db.Export()), 3, "the expired templates are kept until purged")
+
+	db.Purge()
+	assert.Len(t, exportedTemplates(t, db.Export()), 2)
+	assert.Equal(t, map[string]int64{"template": 1}, db.PurgedCounts())
+
+	// the name of a purged template is free again
+	assert.NoError(t, db.CreateEntity(context.Background(), "template", &model.Template{TemplateEditable: model.TemplateEditable{Name: "template-1"}}))
+}
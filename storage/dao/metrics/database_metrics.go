@@ -0,0 +1,94 @@
+// Package metrics provides a dao.Database decorator exposing prometheus metrics about the database calls.
+package metrics
+
+import (
+	"sync"
+	"time"
+
+	"github.com/adeo/turbine-go-api-skeleton/storage/dao"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	latencyName  = "dao_requests_seconds"
+	errorsName   = "dao_errors_total"
+	inFlightName = "dao_requests_in_flight"
+
+	// errorTypeOther is the error type label of the errors which are not a *dao.DAOError
+	errorTypeOther = "other"
+)
+
+var (
+	registerOnce sync.Once
+	latency      *prometheus.HistogramVec
+	errorsTotal  *prometheus.CounterVec
+	inFlight     *prometheus.GaugeVec
+)
+
+// register creates and registers the collectors, shared by all the instrumented databases
+func register(service string) {
+	registerOnce.Do(func() {
+		labels := prometheus.Labels{"service": service}
+
+		latency = prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:        latencyName,
+				Help:        "How long it took to process the database call, partitioned by backend and method.",
+				ConstLabels: labels,
+			},
+			[]string{"backend", "method"},
+		)
+		errorsTotal = prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name:        errorsName,
+				Help:        "How many database calls failed, partitioned by backend, method and dao error type.",
+				ConstLabels: labels,
+			},
+			[]string{"backend", "method", "type"},
+		)
+		inFlight = prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name:        inFlightName,
+				Help:        "How many database calls are being processed, partitioned by backend.",
+				ConstLabels: labels,
+			},
+			[]string{"backend"},
+		)
+
+		prometheus.MustRegister(latency, errorsTotal, inFlight)
+	})
+}
+
+type DatabaseMetrics struct {
+	db      dao.Database
+	backend string
+}
+
+// NewDatabaseMetrics returns a decorator of the given database, recording its calls in the metrics
+// labeled with the given backend name (eg. mongodb)
+func NewDatabaseMetrics(db dao.Database, service, backend string) dao.Database {
+	register(service)
+	return &DatabaseMetrics{
+		db:      db,
+		backend: backend,
+	}
+}
+
+// observe records the beginning of a call, the returned func must be called with the result of the call
+func (db *DatabaseMetrics) observe(method string) func(error) {
+	start := time.Now()
+	inFlight.WithLabelValues(db.backend).Inc()
+
+	return func(err error) {
+		inFlight.WithLabelValues(db.backend).Dec()
+		latency.WithLabelValues(db.backend, method).Observe(time.Since(start).Seconds())
+
+		if err != nil {
+			errorType := errorTypeOther
+			if e, ok := dao.AsDAOError(err); ok {
+				errorType = e.Type.String()
+			}
+			errorsTotal.WithLabelValues(db.backend, method, errorType).Inc()
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package metrics
+
+import (
+	"context"
+
+	"github.com/adeo/turbine-go-api-skeleton/storage/dao"
+)
+
+func (db *DatabaseMetrics) GetAllEntities(ctx context.Context, entity string) ([]interface{}, error) {
+	done := db.observe(dao.EntityMethod("GetAllEntities", entity))
+	values, err := db.db.GetAllEntities(ctx, entity)
+	done(err)
+	return values, err
+}
+
+func (db *DatabaseMetrics) GetEntityByID(ctx context.Context, entity, id string) (interface{}, error) {
+	done := db.observe(dao.EntityMethod("GetEntityByID", entity))
+	v, err := db.db.GetEntityByID(ctx, entity, id)
+	done(err)
+	return v, err
+}
+
+func (db *DatabaseMetrics) CreateEntity(ctx context.Context, entity string, v interface{}) error {
+	done := db.observe(dao.EntityMethod("CreateEntity", entity))
+	err := db.db.CreateEntity(ctx, entity, v)
+	done(err)
+	return err
+}
+
+func (db *DatabaseMetrics) DeleteEntity(ctx context.Context, entity, id string) error {
+	done := db.observe(dao.EntityMethod("DeleteEntity", entity))
+	err := db.db.DeleteEntity(ctx, entity, id)
+	done(err)
+	return err
+}
+
+func (db *DatabaseMetrics) UpdateEntity(ctx context.Context, entity string, v interface{}) error {
+	done := db.observe(dao.EntityMethod("UpdateEntity", entity))
+	err := db.db.UpdateEntity(ctx, entity, v)
+	done(err)
+	return err
+}
+
+func (db *DatabaseMetrics) GetEntitiesAfter(ctx context.Context, entity, afterID string, limit int) ([]interface{}, error) {
+	done := db.observe(dao.EntityMethod("GetEntitiesAfter", entity))
+	values, err := db.db.GetEntitiesAfter(ctx, entity, afterID, limit)
+	done(err)
+	return values, err
+}
+
+func (db *DatabaseMetrics) RestoreEntity(ctx context.Context, entity string, v interface{}) error {
+	done := db.observe(dao.EntityMethod("RestoreEntity", entity))
+	err := db.db.RestoreEntity(ctx, entity, v)
+	done(err)
+	return err
+}
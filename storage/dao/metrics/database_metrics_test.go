@@ -0,0 +1,31 @@
+package metrics
+
+import (
+	"context"
+	"testing"
+
+	"github.com/adeo/turbine-go-api-skeleton/storage/dao"
+	"github.com/adeo/turbine-go-api-skeleton/storage/dao/daotest"
+	"github.com/adeo/turbine-go-api-skeleton/storage/dao/fake"
+	"github.com/adeo/turbine-go-api-skeleton/storage/model"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDatabaseMetricsConformance(t *testing.T) {
+	daotest.Run(t, func(t *testing.T) dao.Database {
+		return NewDatabaseMetrics(fake.NewDatabaseFake("", "", 0), "test", "conformance")
+	})
+}
+
+func TestDatabaseMetrics(t *testing.T) {
+	db := NewDatabaseMetrics(fake.NewDatabaseFake("", "", 0), "test", "metrics")
+
+	_, _ = db.GetEntityByID(context.Background(), "template", "unknown")
+	_ = db.CreateEntity(context.Background(), "template", &model.Template{TemplateEditable: model.TemplateEditable{Name: "template-1"}})
+	_ = db.CreateEntity(context.Background(), "template", &model.Template{TemplateEditable: model.TemplateEditable{Name: "template-1"}})
+
+	assert.Equal(t, float64(1), testutil.ToFloat64(errorsTotal.WithLabelValues("metrics", dao.EntityMethod("GetEntityByID", "template"), "not_found")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(errorsTotal.WithLabelValues("metrics", dao.EntityMethod("CreateEntity", "template"), "duplicate")))
+	assert.Equal(t, float64(0), testutil.ToFloat64(inFlight.WithLabelValues("metrics")))
+}
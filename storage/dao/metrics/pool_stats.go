@@ -0,0 +1,74 @@
+package metrics
+
+import (
+	"github.com/adeo/turbine-go-api-skeleton/storage/dao"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// PoolStatsCollector is a prometheus collector exposing the statistics of the connection pools of a database
+type PoolStatsCollector struct {
+	provider dao.PoolStatsProvider
+
+	connections      *prometheus.Desc
+	maxConnections   *prometheus.Desc
+	waitTotal        *prometheus.Desc
+	waitSeconds      *prometheus.Desc
+	closedTotal      *prometheus.Desc
+	checkOutFailures *prometheus.Desc
+}
+
+// NewPoolStatsCollector returns a collector of the pool statistics of the given database,
+// labeled with the given backend name (eg. postgresql)
+func NewPoolStatsCollector(provider dao.PoolStatsProvider, service, backend string) *PoolStatsCollector {
+	labels := prometheus.Labels{"service": service, "backend": backend}
+	return &PoolStatsCollector{
+		provider: provider,
+		connections: prometheus.NewDesc("dao_pool_connections",
+			"How many connections are open, partitioned by pool and state (in_use or idle).",
+			[]string{"pool", "state"}, labels),
+		maxConnections: prometheus.NewDesc("dao_pool_max_connections",
+			"The maximum number of open connections of the pool, 0 means unlimited.",
+			[]string{"pool"}, labels),
+		waitTotal: prometheus.NewDesc("dao_pool_wait_total",
+			"How many times a call waited for a connection, partitioned by pool.",
+			[]string{"pool"}, labels),
+		waitSeconds: prometheus.NewDesc("dao_pool_wait_seconds_total",
+			"How long the calls waited for a connection, partitioned by pool.",
+			[]string{"pool"}, labels),
+		closedTotal: prometheus.NewDesc("dao_pool_closed_total",
+			"How many connections were closed by the pool settings, partitioned by pool and reason (max_idle or max_lifetime).",
+			[]string{"pool", "reason"}, labels),
+		checkOutFailures: prometheus.NewDesc("dao_pool_check_out_failures_total",
+			"How many times a connection could not be got from the pool, partitioned by pool.",
+			[]string{"pool"}, labels),
+	}
+}
+
+// RegisterPoolStats registers a collector of the pool statistics of the given database
+func RegisterPoolStats(provider dao.PoolStatsProvider, service, backend string) {
+	prometheus.MustRegister(NewPoolStatsCollector(provider, service, backend))
+}
+
+// Describe implements prometheus.Collector
+func (c *PoolStatsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.connections
+	ch <- c.maxConnections
+	ch <- c.waitTotal
+	ch <- c.waitSeconds
+	ch <- c.closedTotal
+	ch <- c.checkOutFailures
+}
+
+// Collect implements prometheus.Collector
+func (c *PoolStatsCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, s := range c.provider.PoolStats() {
+		ch <- prometheus.MustNewConstMetric(c.connections, prometheus.GaugeValue, float64(s.InUse), s.Name, "in_use")
+		ch <- prometheus.MustNewConstMetric(c.connections, prometheus.GaugeValue, float64(s.Idle), s.Name, "idle")
+		ch <- prometheus.MustNewConstMetric(c.maxConnections, prometheus.GaugeValue, float64(s.MaxOpenConnections), s.Name)
+		ch <- prometheus.MustNewConstMetric(c.waitTotal, prometheus.CounterValue, float64(s.WaitCount), s.Name)
+		ch <- prometheus.MustNewConstMetric(c.waitSeconds, prometheus.CounterValue, s.WaitDurationSeconds, s.Name)
+		ch <- prometheus.MustNewConstMetric(c.closedTotal, prometheus.CounterValue, float64(s.MaxIdleClosed), s.Name, "max_idle")
+		ch <- prometheus.MustNewConstMetric(c.closedTotal, prometheus.CounterValue, float64(s.MaxLifetimeClosed), s.Name, "max_lifetime")
+		ch <- prometheus.MustNewConstMetric(c.checkOutFailures, prometheus.CounterValue, float64(s.CheckOutFailures), s.Name)
+	}
+}
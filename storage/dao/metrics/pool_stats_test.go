@@ -0,0 +1,34 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/adeo/turbine-go-api-skeleton/storage/dao"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+type staticPoolStats []dao.PoolStats
+
+func (s staticPoolStats) PoolStats() []dao.PoolStats {
+	return s
+}
+
+func TestPoolStatsCollector(t *testing.T) {
+	collector := NewPoolStatsCollector(staticPoolStats{
+		{Name: "primary", MaxOpenConnections: 10, OpenConnections: 3, InUse: 2, Idle: 1},
+	}, "test", "pool")
+
+	expected := `
+# HELP dao_pool_connections How many connections are open, partitioned by pool and state (in_use or idle).
+# TYPE dao_pool_connections gauge
+dao_pool_connections{backend="pool",pool="primary",service="test",state="idle"} 1
+dao_pool_connections{backend="pool",pool="primary",service="test",state="in_use"} 2
+# HELP dao_pool_max_connections The maximum number of open connections of the pool, 0 means unlimited.
+# TYPE dao_pool_max_connections gauge
+dao_pool_max_connections{backend="pool",pool="primary",service="test"} 10
+`
+	err := testutil.CollectAndCompare(collector, strings.NewReader(expected), "dao_pool_connections", "dao_pool_max_connections")
+	assert.NoError(t, err)
+}
@@ -0,0 +1,42 @@
+package metrics
+
+import (
+	"github.com/adeo/turbine-go-api-skeleton/storage/dao"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// PurgeStatsCollector is a prometheus collector exposing the number of expired entities purged by a database
+type PurgeStatsCollector struct {
+	provider dao.PurgeStatsProvider
+
+	purgedTotal *prometheus.Desc
+}
+
+// NewPurgeStatsCollector returns a collector of the purge statistics of the given database,
+// labeled with the given backend name (eg. postgresql)
+func NewPurgeStatsCollector(provider dao.PurgeStatsProvider, service, backend string) *PurgeStatsCollector {
+	labels := prometheus.Labels{"service": service, "backend": backend}
+	return &PurgeStatsCollector{
+		provider: provider,
+		purgedTotal: prometheus.NewDesc("dao_expired_purged_total",
+			"How many expired entities were purged, partitioned by entity.",
+			[]string{"entity"}, labels),
+	}
+}
+
+// RegisterPurgeStats registers a collector of the purge statistics of the given database
+func RegisterPurgeStats(provider dao.PurgeStatsProvider, service, backend string) {
+	prometheus.MustRegister(NewPurgeStatsCollector(provider, service, backend))
+}
+
+// Describe implements prometheus.Collector
+func (c *PurgeStatsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.purgedTotal
+}
+
+// Collect implements prometheus.Collector
+func (c *PurgeStatsCollector) Collect(ch chan<- prometheus.Metric) {
+	for entity, count := range c.provider.PurgedCounts() {
+		ch <- prometheus.MustNewConstMetric(c.purgedTotal, prometheus.CounterValue, float64(count), entity)
+	}
+}
@@ -0,0 +1,27 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+type staticPurgeStats map[string]int64
+
+func (s staticPurgeStats) PurgedCounts() map[string]int64 {
+	return s
+}
+
+func TestPurgeStatsCollector(t *testing.T) {
+	collector := NewPurgeStatsCollector(staticPurgeStats{"template": 3}, "test", "memory")
+
+	expected := `
+# HELP dao_expired_purged_total How many expired entities were purged, partitioned by entity.
+# TYPE dao_expired_purged_total counter
+dao_expired_purged_total{backend="memory",entity="template",service="test"} 3
+`
+	err := testutil.CollectAndCompare(collector, strings.NewReader(expected), "dao_expired_purged_total")
+	assert.NoError(t, err)
+}
@@ -0,0 +1,82 @@
+package migrate
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/adeo/turbine-go-api-skeleton/storage/dao"
+)
+
+// Progress is the progress of the copy of an entity
+type Progress struct {
+	LastID string `json:"last_id,omitempty"` // id of the last copied entity
+	Done   bool   `json:"done,omitempty"`
+}
+
+// Checkpoint records the progress of a copy in a file, to resume it after a failure.
+// The methods of a nil Checkpoint do nothing.
+type Checkpoint struct {
+	file     string
+	progress map[string]*Progress
+}
+
+// LoadCheckpoint reads the progress of a previous copy from the given file, if it exists
+func LoadCheckpoint(file string) (*Checkpoint, error) {
+	checkpoint := &Checkpoint{file: file, progress: map[string]*Progress{}}
+	data, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return checkpoint, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return checkpoint, json.Unmarshal(data, &checkpoint.progress)
+}
+
+// checkpointKey identifies an entity of the tenant of the context in the checkpoint
+func checkpointKey(ctx context.Context, entity string) string {
+	if tenant := dao.TenantFromContext(ctx); tenant != "" {
+		return tenant + "/" + entity
+	}
+	return entity
+}
+
+func (c *Checkpoint) get(key string) Progress {
+	if c == nil || c.progress[key] == nil {
+		return Progress{}
+	}
+	return *c.progress[key]
+}
+
+// set records the progress of an entity, the file is replaced atomically
+func (c *Checkpoint) set(key string, progress *Progress) error {
+	if c == nil {
+		return nil
+	}
+	c.progress[key] = progress
+
+	data, err := json.MarshalIndent(c.progress, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(c.file), filepath.Base(c.file)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // no-op once renamed
+
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if errClose := tmp.Close(); err == nil {
+		err = errClose
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), c.file)
+}
@@ -0,0 +1,223 @@
+// Package migrate copies the entities from a database to another, whatever their backends.
+package migrate
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/adeo/turbine-go-api-skeleton/storage/dao"
+	"github.com/adeo/turbine-go-api-skeleton/utils"
+)
+
+// ConflictStrategy is what to do when an entity to copy already exists in the destination database
+type ConflictStrategy string
+
+const (
+	// ConflictFail stops the copy
+	ConflictFail ConflictStrategy = "fail"
+	// ConflictSkip keeps the existing entity
+	ConflictSkip ConflictStrategy = "skip"
+	// ConflictOverwrite replaces the existing entity having the same id
+	ConflictOverwrite ConflictStrategy = "overwrite"
+)
+
+// entity copies the entities of one type, handled as interface{} values
+type entity struct {
+	name string
+	// page returns at most limit values of db ordered by id, after the given id
+	page    func(ctx context.Context, db dao.Database, afterID string, limit int) ([]interface{}, error)
+	id      func(v interface{}) string
+	get     func(ctx context.Context, db dao.Database, id string) (interface{}, error)
+	restore func(ctx context.Context, db dao.Database, v interface{}) error
+	remove  func(ctx context.Context, db dao.Database, id string) error
+	// checksum returns the bytes identifying the value in the verification pass, whatever the backend
+	checksum func(v interface{}) ([]byte, error)
+}
+
+// copiedEntities returns the copied entities: the registered ones, sorted by name
+func copiedEntities() []entity {
+	var result []entity
+	for _, e := range dao.Entities() {
+		result = append(result, entityRegistered(e))
+	}
+	return result
+}
+
+// Options are the options of a copy
+type Options struct {
+	BatchSize int
+	Conflict  ConflictStrategy
+	// Checkpoint records the progress of the copy, to resume it. Nil to always copy everything.
+	Checkpoint *Checkpoint
+}
+
+// Report is the result of the copy of an entity
+type Report struct {
+	Entity      string
+	Copied      int
+	Skipped     int
+	Overwritten int
+	Unchanged   int  // already copied, eg. by an interrupted copy
+	Resumed     bool // true when the copy started from a checkpoint
+}
+
+// Copy copies all the entities of the tenant of the context from src to dst, keeping their ids
+func Copy(ctx context.Context, src, dst dao.Database, opts Options) ([]Report, error) {
+	all := copiedEntities()
+	reports := make([]Report, 0, len(all))
+	for _, e := range all {
+		report, err := copyEntity(ctx, src, dst, e, opts)
+		reports = append(reports, report)
+		if err != nil {
+			return reports, fmt.Errorf("copy of %s: %w", e.name, err)
+		}
+	}
+	return reports, nil
+}
+
+func copyEntity(ctx context.Context, src, dst dao.Database, e entity, opts Options) (Report, error) {
+	report := Report{Entity: e.name}
+	key := checkpointKey(ctx, e.name)
+	progress := opts.Checkpoint.get(key)
+	if progress.Done {
+		report.Resumed = true
+		return report, nil
+	}
+	report.Resumed = progress.LastID != ""
+
+	afterID := progress.LastID
+	for {
+		values, err := e.page(ctx, src, afterID, opts.BatchSize)
+		if err != nil {
+			return report, err
+		}
+		for _, v := range values {
+			err := restore(ctx, dst, e, v, opts.Conflict, &report)
+			if err != nil {
+				return report, err
+			}
+		}
+		if len(values) < opts.BatchSize {
+			break
+		}
+
+		afterID = e.id(values[len(values)-1])
+		err = opts.Checkpoint.set(key, &Progress{LastID: afterID})
+		if err != nil {
+			return report, err
+		}
+		utils.GetLogger().WithField("entity", e.name).WithField("last_id", afterID).Info("batch copied")
+	}
+	return report, opts.Checkpoint.set(key, &Progress{Done: true})
+}
+
+func restore(ctx context.Context, dst dao.Database, e entity, v interface{}, conflict ConflictStrategy, report *Report) error {
+	err := e.restore(ctx, dst, v)
+	if errors.Is(err, dao.ErrInvalidID) {
+		// eg. a MongoDB id copied to a PostgreSQL table with a uuid id column, the ids being kept
+		return fmt.Errorf("the destination database can't store the id %s, migrate its id column to text: %w", e.id(v), err)
+	}
+	if !isDuplicate(err) {
+		if err == nil {
+			report.Copied++
+		}
+		return err
+	}
+
+	id := e.id(v)
+	if existing, errGet := e.get(ctx, dst, id); errGet == nil && sameChecksum(e, existing, v) {
+		report.Unchanged++
+		return nil
+	}
+
+	switch conflict {
+	case ConflictSkip:
+		report.Skipped++
+		return nil
+	case ConflictOverwrite:
+		errRemove := e.remove(ctx, dst, id)
+		if errRemove != nil {
+			// the conflict is on another unique index than the id, it can't be overwritten
+			return fmt.Errorf("unable to overwrite %s: %v", id, errRemove)
+		}
+		err = e.restore(ctx, dst, v)
+		if err != nil {
+			return err
+		}
+		report.Overwritten++
+		return nil
+	}
+	return fmt.Errorf("%s already exists: %v", id, err)
+}
+
+func sameChecksum(e entity, v1, v2 interface{}) bool {
+	b1, err1 := e.checksum(v1)
+	b2, err2 := e.checksum(v2)
+	return err1 == nil && err2 == nil && string(b1) == string(b2)
+}
+
+func isDuplicate(err error) bool {
+	return errors.Is(err, dao.ErrDuplicate)
+}
+
+// Verification compares the entities of the source and destination databases
+type Verification struct {
+	Entity              string
+	SourceCount         int
+	DestinationCount    int
+	SourceChecksum      string
+	DestinationChecksum string
+}
+
+// OK returns whether both databases contain the same entities
+func (v Verification) OK() bool {
+	return v.SourceCount == v.DestinationCount && v.SourceChecksum == v.DestinationChecksum
+}
+
+// Verify compares the counts and checksums of the entities of the tenant of the context in src and dst
+func Verify(ctx context.Context, src, dst dao.Database, batchSize int) ([]Verification, error) {
+	all := copiedEntities()
+	verifications := make([]Verification, 0, len(all))
+	for _, e := range all {
+		verification := Verification{Entity: e.name}
+		var err error
+		verification.SourceCount, verification.SourceChecksum, err = checksum(ctx, src, e, batchSize)
+		if err != nil {
+			return nil, fmt.Errorf("checksum of %s in the source database: %v", e.name, err)
+		}
+		verification.DestinationCount, verification.DestinationChecksum, err = checksum(ctx, dst, e, batchSize)
+		if err != nil {
+			return nil, fmt.Errorf("checksum of %s in the destination database: %v", e.name, err)
+		}
+		verifications = append(verifications, verification)
+	}
+	return verifications, nil
+}
+
+// checksum returns the count and the sha256 of the values of the entity in db, in id order
+func checksum(ctx context.Context, db dao.Database, e entity, batchSize int) (int, string, error) {
+	hash := sha256.New()
+	count := 0
+	afterID := ""
+	for {
+		values, err := e.page(ctx, db, afterID, batchSize)
+		if err != nil {
+			return 0, "", err
+		}
+		for _, v := range values {
+			b, err := e.checksum(v)
+			if err != nil {
+				return 0, "", err
+			}
+			hash.Write(b)
+			count++
+		}
+		if len(values) < batchSize {
+			return count, hex.EncodeToString(hash.Sum(nil)), nil
+		}
+		afterID = e.id(values[len(values)-1])
+	}
+}
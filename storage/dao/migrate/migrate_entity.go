@@ -0,0 +1,32 @@
+package migrate
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/adeo/turbine-go-api-skeleton/storage/dao"
+)
+
+// entityRegistered copies the values of a registered entity
+func entityRegistered(e dao.Entity) entity {
+	return entity{
+		name: e.Name,
+		page: func(ctx context.Context, db dao.Database, afterID string, limit int) ([]interface{}, error) {
+			return db.GetEntitiesAfter(ctx, e.Name, afterID, limit)
+		},
+		id: e.ID,
+		get: func(ctx context.Context, db dao.Database, id string) (interface{}, error) {
+			return db.GetEntityByID(ctx, e.Name, id)
+		},
+		restore: func(ctx context.Context, db dao.Database, v interface{}) error {
+			return db.RestoreEntity(ctx, e.Name, v)
+		},
+		remove: func(ctx context.Context, db dao.Database, id string) error {
+			return db.DeleteEntity(ctx, e.Name, id)
+		},
+		checksum: func(v interface{}) ([]byte, error) {
+			// the timestamps are compared with the precision of all the backends
+			return json.Marshal(e.Comparable(v, true))
+		},
+	}
+}
@@ -0,0 +1,141 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/adeo/turbine-go-api-skeleton/storage/dao"
+	"github.com/adeo/turbine-go-api-skeleton/storage/dao/daotest"
+	"github.com/adeo/turbine-go-api-skeleton/storage/dao/fake"
+	"github.com/adeo/turbine-go-api-skeleton/storage/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var ctx = context.Background()
+
+// newSourceDatabase returns a database having count templates, which are the second copied entity: the entities
+// are copied by name, after the daotest items
+func newSourceDatabase(t *testing.T, count int) dao.Database {
+	db := fake.NewDatabaseFake("", "", 0)
+	for i := 0; i < count; i++ {
+		err := db.CreateEntity(ctx, "template", &model.Template{TemplateEditable: model.TemplateEditable{Name: fmt.Sprintf("template-%d", i)}})
+		require.NoError(t, err)
+	}
+	return db
+}
+
+func TestCopy(t *testing.T) {
+	src := newSourceDatabase(t, 5)
+	dst := fake.NewDatabaseFake("", "", 0)
+
+	reports, err := Copy(ctx, src, dst, Options{BatchSize: 2, Conflict: ConflictFail})
+	require.NoError(t, err)
+	assert.Equal(t, []Report{{Entity: daotest.EntityItem}, {Entity: "template", Copied: 5}}, reports)
+
+	srcTemplates, _ := src.GetEntitiesAfter(ctx, "template", "", 10)
+	dstTemplates, _ := dst.GetEntitiesAfter(ctx, "template", "", 10)
+	assert.Equal(t, srcTemplates, dstTemplates)
+
+	verifications, err := Verify(ctx, src, dst, 2)
+	require.NoError(t, err)
+	require.Len(t, verifications, 2)
+	assert.True(t, verifications[1].OK())
+	assert.Equal(t, 5, verifications[1].DestinationCount)
+}
+
+func TestCopyAcrossBackends(t *testing.T) {
+	// the ids generated by MongoDB
+	src := fake.NewDatabaseFake("", "", 0)
+	for i := 0; i < 3; i++ {
+		template := &model.Template{
+			ID:               primitive.NewObjectID().Hex(),
+			TemplateEditable: model.TemplateEditable{Name: fmt.Sprintf("template-%d", i)},
+		}
+		require.NoError(t, src.RestoreEntity(ctx, "template", template))
+	}
+
+	// a PostgreSQL table with a uuid id column rejects them, before anything is copied
+	dst := fake.NewDatabaseFake("", "", 0)
+	_, err := Copy(ctx, src, daotest.UUIDIDs(dst), Options{BatchSize: 2, Conflict: ConflictFail})
+	assert.True(t, errors.Is(err, dao.ErrInvalidID), "unexpected error: %v", err)
+	dstTemplates, _ := dst.GetEntitiesAfter(ctx, "template", "", 10)
+	assert.Empty(t, dstTemplates)
+
+	// once migrated to a text id column, the ids are kept
+	reports, err := Copy(ctx, src, dst, Options{BatchSize: 2, Conflict: ConflictFail})
+	require.NoError(t, err)
+	assert.Equal(t, 3, reports[1].Copied)
+	verifications, err := Verify(ctx, src, dst, 2)
+	require.NoError(t, err)
+	assert.True(t, verifications[1].OK())
+}
+
+func TestCopyConflicts(t *testing.T) {
+	src := newSourceDatabase(t, 2)
+	templates, _ := src.GetEntitiesAfter(ctx, "template", "", 10)
+
+	newDestination := func() dao.Database {
+		dst := fake.NewDatabaseFake("", "", 0)
+		conflicting := *templates[0].(*model.Template)
+		conflicting.Name = "changed"
+		require.NoError(t, dst.RestoreEntity(ctx, "template", &conflicting))
+		return dst
+	}
+
+	_, err := Copy(ctx, src, newDestination(), Options{BatchSize: 10, Conflict: ConflictFail})
+	assert.Error(t, err)
+
+	dst := newDestination()
+	reports, err := Copy(ctx, src, dst, Options{BatchSize: 10, Conflict: ConflictSkip})
+	require.NoError(t, err)
+	assert.Equal(t, 1, reports[1].Copied)
+	assert.Equal(t, 1, reports[1].Skipped)
+	verifications, err := Verify(ctx, src, dst, 10)
+	require.NoError(t, err)
+	assert.False(t, verifications[1].OK())
+
+	dst = newDestination()
+	reports, err = Copy(ctx, src, dst, Options{BatchSize: 10, Conflict: ConflictOverwrite})
+	require.NoError(t, err)
+	assert.Equal(t, 1, reports[1].Copied)
+	assert.Equal(t, 1, reports[1].Overwritten)
+	verifications, err = Verify(ctx, src, dst, 10)
+	require.NoError(t, err)
+	assert.True(t, verifications[1].OK())
+}
+
+func TestCopyCheckpoint(t *testing.T) {
+	dir, err := ioutil.TempDir("", "migrate")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "checkpoint.json")
+
+	src := newSourceDatabase(t, 5)
+	templates, _ := src.GetEntitiesAfter(ctx, "template", "", 10)
+
+	// an interrupted copy: the first batch is checkpointed, the first template of the second batch copied
+	dst := fake.NewDatabaseFake("", "", 0)
+	for _, template := range templates[:3] {
+		require.NoError(t, dst.RestoreEntity(ctx, "template", template))
+	}
+	checkpoint, err := LoadCheckpoint(file)
+	require.NoError(t, err)
+	require.NoError(t, checkpoint.set("template", &Progress{LastID: templates[1].(*model.Template).ID}))
+
+	checkpoint, err = LoadCheckpoint(file)
+	require.NoError(t, err)
+	reports, err := Copy(ctx, src, dst, Options{BatchSize: 2, Conflict: ConflictFail, Checkpoint: checkpoint})
+	require.NoError(t, err)
+	assert.Equal(t, []Report{{Entity: daotest.EntityItem}, {Entity: "template", Copied: 2, Unchanged: 1, Resumed: true}}, reports)
+
+	checkpoint, err = LoadCheckpoint(file)
+	require.NoError(t, err)
+	assert.True(t, checkpoint.get("template").Done)
+}
@@ -0,0 +1,40 @@
+package mock
+
+import (
+	"context"
+)
+
+func (db *DatabaseMock) GetAllEntities(ctx context.Context, entity string) ([]interface{}, error) {
+	args := db.Called(ctx, entity)
+	return args.Get(0).([]interface{}), args.Error(1)
+}
+
+func (db *DatabaseMock) GetEntityByID(ctx context.Context, entity, id string) (interface{}, error) {
+	args := db.Called(ctx, entity, id)
+	return args.Get(0), args.Error(1)
+}
+
+func (db *DatabaseMock) CreateEntity(ctx context.Context, entity string, v interface{}) error {
+	args := db.Called(ctx, entity, v)
+	return args.Error(0)
+}
+
+func (db *DatabaseMock) DeleteEntity(ctx context.Context, entity, id string) error {
+	args := db.Called(ctx, entity, id)
+	return args.Error(0)
+}
+
+func (db *DatabaseMock) UpdateEntity(ctx context.Context, entity string, v interface{}) error {
+	args := db.Called(ctx, entity, v)
+	return args.Error(0)
+}
+
+func (db *DatabaseMock) GetEntitiesAfter(ctx context.Context, entity, afterID string, limit int) ([]interface{}, error) {
+	args := db.Called(ctx, entity, afterID, limit)
+	return args.Get(0).([]interface{}), args.Error(1)
+}
+
+func (db *DatabaseMock) RestoreEntity(ctx context.Context, entity string, v interface{}) error {
+	args := db.Called(ctx, entity, v)
+	return args.Error(0)
+}
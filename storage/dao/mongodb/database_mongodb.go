@@ -2,69 +2,317 @@ package mongodb
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/adeo/turbine-go-api-skeleton/storage/dao"
 	"github.com/adeo/turbine-go-api-skeleton/utils"
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"go.mongodb.org/mongo-driver/mongo/readpref"
 )
 
 const (
-	mongoWriteErrorDuplicate      = 11000
-	mongoWriteErrorDuplicateOther = 11001
+	defaultConnectTimeout = 10 * time.Second
+	defaultQueryTimeout   = 30 * time.Second
+	defaultMaxPoolSize    = 100 // default of the driver
+
+	// pingRetryDelay is the delay between the pings of a server which is not reachable yet, at connection
+	pingRetryDelay = 2 * time.Second
+)
+
+// codes of the mongodb server errors, see https://github.com/mongodb/mongo/blob/master/src/mongo/base/error_codes.yml
+const (
+	mongoErrorHostUnreachable              = 6
+	mongoErrorHostNotFound                 = 7
+	mongoErrorMaxTimeMSExpired             = 50
+	mongoErrorNetworkTimeout               = 89
+	mongoErrorShutdownInProgress           = 91
+	mongoErrorWriteConflict                = 112
+	mongoErrorDocumentValidationFailure    = 121
+	mongoErrorPrimarySteppedDown           = 189
+	mongoErrorNotMaster                    = 10107
+	mongoWriteErrorDuplicate               = 11000
+	mongoWriteErrorDuplicateOther          = 11001
+	mongoErrorInterruptedAtShutdown        = 11600
+	mongoErrorInterruptedDueToReplStateChg = 11602
+	mongoWriteErrorDuplicateUpdate         = 12582
+	mongoErrorNotMasterNoSlaveOk           = 13435
+	mongoErrorNotMasterOrSecondary         = 13436
+
+	// mongoLabelNetworkError labels the errors of the connection to the server
+	mongoLabelNetworkError = "NetworkError"
 )
 
+// duplicateIndexPattern extracts the index name from the message of the duplicate key errors
+var duplicateIndexPattern = regexp.MustCompile(`index: (\S+)`)
+
+// handleError converts the errors of the mongodb driver to dao errors, when they have a matching type
+func handleError(err error) error {
+	var we mongo.WriteException
+	if errors.As(err, &we) {
+		return handleWriteException(we)
+	}
+	var ce mongo.CommandError
+	if errors.As(err, &ce) {
+		return handleCommandError(ce)
+	}
+	return dao.Classify(err)
+}
+
+func handleWriteException(e mongo.WriteException) error {
+	if len(e.WriteErrors) > 0 {
+		return handleCode(e.WriteErrors[0].Code, e.WriteErrors[0].Message, e)
+	}
+	return e
+}
+
+// handleCommandError handles the errors of the commands not returning a mongo.WriteException, like findAndModify
+func handleCommandError(e mongo.CommandError) error {
+	for _, label := range e.Labels {
+		if label == mongoLabelNetworkError {
+			return dao.NewDAOError(dao.ErrTypeUnavailable, e)
+		}
+	}
+	return handleCode(int(e.Code), e.Message, e)
+}
+
+// handleCode converts the error having the given server error code and message
+func handleCode(code int, message string, e error) error {
+	switch code {
+	case mongoWriteErrorDuplicate, mongoWriteErrorDuplicateOther, mongoWriteErrorDuplicateUpdate:
+		index := ""
+		if m := duplicateIndexPattern.FindStringSubmatch(message); m != nil {
+			index = m[1]
+		}
+		return dao.NewFieldDAOError(dao.ErrTypeDuplicate, index, e)
+	case mongoErrorDocumentValidationFailure:
+		return dao.NewDAOError(dao.ErrTypeConstraintViolation, e)
+	case mongoErrorWriteConflict:
+		return dao.NewDAOError(dao.ErrTypeConflict, e)
+	case mongoErrorMaxTimeMSExpired, mongoErrorNetworkTimeout:
+		return dao.NewDAOError(dao.ErrTypeTimeout, e)
+	case mongoErrorHostUnreachable, mongoErrorHostNotFound, mongoErrorShutdownInProgress, mongoErrorPrimarySteppedDown,
+		mongoErrorNotMaster, mongoErrorInterruptedAtShutdown, mongoErrorInterruptedDueToReplStateChg,
+		mongoErrorNotMasterNoSlaveOk, mongoErrorNotMasterOrSecondary:
+		return dao.NewDAOError(dao.ErrTypeUnavailable, e)
+	}
+	return dao.Classify(e)
+}
+
+// PoolConfig is the configuration of the connection pool, the zero values keep the defaults
+type PoolConfig struct {
+	MaxPoolSize            uint64
+	ConnectTimeout         time.Duration
+	ServerSelectionTimeout time.Duration
+	QueryTimeout           time.Duration
+}
+
 type DatabaseMongoDB struct {
 	client       *mongo.Client
 	databaseName string
+	queryTimeout time.Duration
+	poolMonitor  *poolMonitor
+	tenancy      dao.Tenancy
+	// indexedDatabases are the names of the tenant databases whose indexes have been synchronized, in isolated tenancy
+	indexedDatabases sync.Map
 }
 
-func handleWriteException(e mongo.WriteException) error {
-	if len(e.WriteErrors) > 0 {
-		switch e.WriteErrors[0].Code {
-		case mongoWriteErrorDuplicate:
-			fallthrough
-		case mongoWriteErrorDuplicateOther:
-			return dao.NewDAOError(dao.ErrTypeDuplicate, e)
+// toDocument converts the given value to a bson document, using its bson tags
+func toDocument(v interface{}) (bson.M, error) {
+	b, err := bson.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	doc := bson.M{}
+	err = bson.Unmarshal(b, &doc)
+	return doc, err
+}
+
+// now returns the current time with the precision of the dates stored by mongodb
+func now() time.Time {
+	return time.Now().Truncate(time.Millisecond)
+}
+
+// notExpired is the filter of the documents which are not expired, the TTL monitor of mongodb removing
+// the expired ones up to a minute after their expiry
+func notExpired() bson.A {
+	return bson.A{bson.M{"expires_at": nil}, bson.M{"expires_at": bson.M{"$gt": now()}}}
+}
+
+// tenantFilter is the filter of the documents of the tenant of the call. The documents written before the
+// tenancy have no tenant_id: they belong to the empty tenant, the one of the calls when the tenancy is disabled.
+func tenantFilter(ctx context.Context) interface{} {
+	tenant := dao.TenantFromContext(ctx)
+	if tenant == "" {
+		return bson.M{"$in": bson.A{"", nil}}
+	}
+	return tenant
+}
+
+func init() {
+	dao.Register("mongodb", open)
+	dao.Register("mongodb+srv", open)
+}
+
+// open is the dao driver of mongodb, configured by a PoolConfig setting
+func open(opts dao.Options) (dao.Database, error) {
+	pool := PoolConfig{}
+	for _, setting := range opts.Settings {
+		if s, ok := setting.(PoolConfig); ok {
+			pool = s
 		}
 	}
-	return e
+	name := opts.Name
+	if name == "" {
+		name = databaseFromURI(opts.ConnectionURI)
+	}
+	return NewDatabaseMongoDB(opts.ConnectionURI, name, pool, opts.Tenancy)
+}
+
+// databaseFromURI returns the database given in the path of the connection uri, empty if none
+func databaseFromURI(connectionURI string) string {
+	// the uri is not parsed with net/url, which rejects the lists of hosts
+	path := connectionURI[strings.Index(connectionURI, "://")+len("://"):]
+	i := strings.Index(path, "/")
+	if i < 0 {
+		return ""
+	}
+	path = path[i+1:]
+	if i := strings.Index(path, "?"); i >= 0 {
+		path = path[:i]
+	}
+	return path
 }
 
-func NewDatabaseMongoDB(connectionURI, dbName string) dao.Database {
-	ctx, _ := context.WithTimeout(context.Background(), 10*time.Second)
-	client, err := mongo.Connect(ctx, options.Client().ApplyURI(connectionURI))
+// NewDatabaseMongoDB connects to mongodb and synchronizes the declared indexes
+func NewDatabaseMongoDB(connectionURI, dbName string, pool PoolConfig, tenancy dao.Tenancy) (dao.Database, error) {
+	db, err := ConnectMongoDB(connectionURI, dbName, pool, tenancy)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), indexesTimeout)
+	defer cancel()
+	drifts, err := db.SyncIndexes(ctx)
+	if err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("unable to create the mongodb indexes: %v", err)
+	}
+	logIndexDrifts(drifts)
+
+	return db, nil
+}
+
+// ConnectMongoDB connects to mongodb, without touching the indexes
+func ConnectMongoDB(connectionURI, dbName string, pool PoolConfig, tenancy dao.Tenancy) (*DatabaseMongoDB, error) {
+	if pool.ConnectTimeout <= 0 {
+		pool.ConnectTimeout = defaultConnectTimeout
+	}
+	if pool.QueryTimeout <= 0 {
+		pool.QueryTimeout = defaultQueryTimeout
+	}
+
+	clientOptions := options.Client().ApplyURI(connectionURI).SetConnectTimeout(pool.ConnectTimeout)
+	if pool.MaxPoolSize > 0 {
+		clientOptions.SetMaxPoolSize(pool.MaxPoolSize)
+	}
+	if pool.ServerSelectionTimeout > 0 {
+		clientOptions.SetServerSelectionTimeout(pool.ServerSelectionTimeout)
+	}
+	maxPoolSize := defaultMaxPoolSize
+	if clientOptions.MaxPoolSize != nil {
+		maxPoolSize = int(*clientOptions.MaxPoolSize)
+	}
+	monitor := newPoolMonitor(maxPoolSize)
+	clientOptions.SetPoolMonitor(monitor.monitor())
+
+	ctx, cancel := context.WithTimeout(context.Background(), pool.ConnectTimeout)
+	client, err := mongo.Connect(ctx, clientOptions)
+	cancel()
 	if err != nil {
-		utils.GetLogger().WithError(err).Fatal("Unable to get a connection to mongodb")
+		return nil, fmt.Errorf("unable to get a connection to mongodb: %v", err)
 	}
 
+	// the server may still be starting, the ping is retried until the connect timeout
+	deadline := time.Now().Add(pool.ConnectTimeout)
 	for {
+		ctx, cancel := context.WithDeadline(context.Background(), deadline)
 		err = client.Ping(ctx, readpref.Primary())
-		ctx, _ = context.WithTimeout(context.Background(), 2*time.Second)
-		if err != nil {
-			utils.GetLogger().WithError(err).Error("Unable to ping mongodb, waiting 2s before retrying...")
-			time.Sleep(2 * time.Second)
-			continue
+		cancel()
+		if err == nil {
+			break
+		}
+		if time.Until(deadline) < pingRetryDelay {
+			ctx, cancel := context.WithTimeout(context.Background(), pingRetryDelay)
+			_ = client.Disconnect(ctx)
+			cancel()
+			return nil, fmt.Errorf("unable to ping mongodb: %v", err)
 		}
-		break
+		utils.GetLogger().WithError(err).Errorf("Unable to ping mongodb, waiting %s before retrying...", pingRetryDelay)
+		time.Sleep(pingRetryDelay)
 	}
 
-	result := &DatabaseMongoDB{
+	return &DatabaseMongoDB{
 		client:       client,
 		databaseName: dbName,
-	}
+		queryTimeout: pool.QueryTimeout,
+		poolMonitor:  monitor,
+		tenancy:      tenancy,
+	}, nil
+}
 
-	result.populateTemplateIndexes() // Template index
+// Ping checks the primary server is reachable
+func (db *DatabaseMongoDB) Ping(ctx context.Context) error {
+	ctx, cancel := db.getCtx(ctx)
+	defer cancel()
+	return db.client.Ping(ctx, readpref.Primary())
+}
 
-	return result
+// Close disconnects the client
+func (db *DatabaseMongoDB) Close() error {
+	ctx, cancel := db.getCtx(context.Background())
+	defer cancel()
+	return db.client.Disconnect(ctx)
 }
 
-func (db *DatabaseMongoDB) getSession() *mongo.Database {
+// database returns the database of the tenant of the call in isolated tenancy, the configured one otherwise
+func (db *DatabaseMongoDB) database(ctx context.Context) *mongo.Database {
+	if tenant := dao.TenantFromContext(ctx); db.tenancy == dao.TenancyIsolated && tenant != "" {
+		return db.client.Database(db.databaseName + "_" + tenant)
+	}
 	return db.client.Database(db.databaseName)
 }
-func (db *DatabaseMongoDB) getCtx() context.Context {
-	ctx, _ := context.WithTimeout(context.Background(), 30*time.Second)
-	return ctx
+
+// getSession returns the database of the call, synchronizing its indexes the first time a tenant database is used
+func (db *DatabaseMongoDB) getSession(ctx context.Context) (*mongo.Database, error) {
+	database := db.database(ctx)
+	if database.Name() == db.databaseName {
+		return database, nil
+	}
+	if _, ok := db.indexedDatabases.Load(database.Name()); ok {
+		return database, nil
+	}
+
+	drifts, err := db.SyncIndexes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	logIndexDrifts(drifts)
+	db.indexedDatabases.Store(database.Name(), true)
+	return database, nil
+}
+
+func (db *DatabaseMongoDB) getCtx(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, db.queryTimeout)
+}
+
+// PoolStats returns the statistics of the connection pools, one per server
+func (db *DatabaseMongoDB) PoolStats() []dao.PoolStats {
+	return db.poolMonitor.stats()
 }
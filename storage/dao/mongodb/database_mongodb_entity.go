@@ -0,0 +1,233 @@
+package mongodb
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/adeo/turbine-go-api-skeleton/storage/dao"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// The registered entities are stored in the collection having their name, their documents being encoded with the
+// bson tags of their model.
+
+// indexesEntity returns the indexes of the collection of an entity: a unique index on the tenant and each unique
+// field, and the TTL index of the expiry date
+func indexesEntity(entity dao.Entity) []Index {
+	indexes := make([]Index, 0, len(entity.Unique)+1)
+	for _, name := range entity.Unique {
+		field, _ := entity.FieldByJSON(name)
+		indexes = append(indexes, Index{
+			Name:   fmt.Sprintf("tenant_id_1_%s_1", field.BSON),
+			Keys:   bson.D{{Key: "tenant_id", Value: 1}, {Key: field.BSON, Value: 1}},
+			Unique: true,
+		})
+	}
+	if entity.Expires() {
+		indexes = append(indexes, Index{Name: "expires_at_1", Keys: bson.D{{Key: "expires_at", Value: 1}}, ExpireAt: true})
+	}
+	return indexes
+}
+
+// declaredIndexes returns the indexes declared for the collection of each registered entity, with the previous
+// indexes they replace
+func declaredIndexes() map[string][]Index {
+	indexes := make(map[string][]Index)
+	for _, entity := range dao.Entities() {
+		declared := indexesEntity(entity)
+		for i := range declared {
+			declared[i].Replaces = replacedIndexes[entity.Name][declared[i].Name]
+		}
+		indexes[entity.Name] = declared
+	}
+	return indexes
+}
+
+// decodeEntities returns the values of the documents of the cursor
+func decodeEntities(ctx context.Context, entity dao.Entity, cur *mongo.Cursor) ([]interface{}, error) {
+	defer cur.Close(ctx)
+
+	results := make([]interface{}, 0)
+	for cur.Next(ctx) {
+		result := entity.New()
+		err := cur.Decode(result)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		results = append(results, result)
+	}
+	return results, handleError(cur.Err())
+}
+
+func (db *DatabaseMongoDB) GetAllEntities(ctx context.Context, name string) ([]interface{}, error) {
+	entity, err := dao.LookupEntity(name)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := db.getCtx(ctx)
+	defer cancel()
+	session, err := db.getSession(ctx)
+	if err != nil {
+		return nil, handleError(err)
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}})
+	filter := bson.M{"tenant_id": tenantFilter(ctx), "$or": notExpired()}
+	cur, err := session.Collection(entity.Name).Find(ctx, filter, opts)
+	if err != nil {
+		return nil, handleError(err)
+	}
+	return decodeEntities(ctx, entity, cur)
+}
+
+func (db *DatabaseMongoDB) GetEntityByID(ctx context.Context, name, id string) (interface{}, error) {
+	entity, err := dao.LookupEntity(name)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := db.getCtx(ctx)
+	defer cancel()
+	session, err := db.getSession(ctx)
+	if err != nil {
+		return nil, handleError(err)
+	}
+
+	result := entity.New()
+	filter := bson.M{"_id": id, "tenant_id": tenantFilter(ctx), "$or": notExpired()}
+	err = session.Collection(entity.Name).FindOne(ctx, filter).Decode(result)
+	if err == mongo.ErrNoDocuments {
+		return nil, dao.NewDAOError(dao.ErrTypeNotFound, err)
+	}
+	if err != nil {
+		return nil, handleError(err)
+	}
+	return result, nil
+}
+
+func (db *DatabaseMongoDB) CreateEntity(ctx context.Context, name string, v interface{}) error {
+	entity, err := dao.LookupEntity(name)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := db.getCtx(ctx)
+	defer cancel()
+	session, err := db.getSession(ctx)
+	if err != nil {
+		return handleError(err)
+	}
+
+	entity.SetID(v, primitive.NewObjectID().Hex())
+	entity.SetTenantID(v, dao.TenantFromContext(ctx))
+	entity.SetCreatedAt(v, now())
+	entity.SetUpdatedAt(v, nil)
+
+	_, err = session.Collection(entity.Name).InsertOne(ctx, v)
+	return handleError(err)
+}
+
+func (db *DatabaseMongoDB) DeleteEntity(ctx context.Context, name, id string) error {
+	entity, err := dao.LookupEntity(name)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := db.getCtx(ctx)
+	defer cancel()
+	session, err := db.getSession(ctx)
+	if err != nil {
+		return handleError(err)
+	}
+
+	filter := bson.M{"_id": id, "tenant_id": tenantFilter(ctx), "$or": notExpired()}
+	r, err := session.Collection(entity.Name).DeleteOne(ctx, filter)
+	if err != nil {
+		return handleError(err)
+	}
+	if r.DeletedCount == 0 {
+		return dao.NewDAOError(dao.ErrTypeNotFound, fmt.Errorf("%s not found", entity.Name))
+	}
+	return nil
+}
+
+func (db *DatabaseMongoDB) UpdateEntity(ctx context.Context, name string, v interface{}) error {
+	entity, err := dao.LookupEntity(name)
+	if err != nil {
+		return err
+	}
+
+	// only update the editable fields, the other ones are managed by the database
+	fields, err := toDocument(v)
+	if err != nil {
+		return err
+	}
+	for _, field := range entity.Fields {
+		if field.Managed {
+			delete(fields, field.BSON)
+		}
+	}
+	fields["updated_at"] = now()
+
+	ctx, cancel := db.getCtx(ctx)
+	defer cancel()
+	session, err := db.getSession(ctx)
+	if err != nil {
+		return handleError(err)
+	}
+
+	// decoded in a new value, the fields absent from the document would be kept otherwise
+	result := entity.New()
+	filter := bson.M{"_id": entity.ID(v), "tenant_id": tenantFilter(ctx), "$or": notExpired()}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err = session.Collection(entity.Name).
+		FindOneAndUpdate(ctx, filter, bson.M{"$set": fields}, opts).
+		Decode(result)
+	if err == mongo.ErrNoDocuments {
+		return dao.NewDAOError(dao.ErrTypeNotFound, err)
+	}
+	if err != nil {
+		return handleError(err)
+	}
+	reflect.ValueOf(v).Elem().Set(reflect.ValueOf(result).Elem())
+	return nil
+}
+
+func (db *DatabaseMongoDB) GetEntitiesAfter(ctx context.Context, name, afterID string, limit int) ([]interface{}, error) {
+	entity, err := dao.LookupEntity(name)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := db.getCtx(ctx)
+	defer cancel()
+	session, err := db.getSession(ctx)
+	if err != nil {
+		return nil, handleError(err)
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}).SetLimit(int64(limit))
+	filter := bson.M{"tenant_id": tenantFilter(ctx), "_id": bson.M{"$gt": afterID}, "$or": notExpired()}
+	cur, err := session.Collection(entity.Name).Find(ctx, filter, opts)
+	if err != nil {
+		return nil, handleError(err)
+	}
+	return decodeEntities(ctx, entity, cur)
+}
+
+func (db *DatabaseMongoDB) RestoreEntity(ctx context.Context, name string, v interface{}) error {
+	entity, err := dao.LookupEntity(name)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := db.getCtx(ctx)
+	defer cancel()
+	session, err := db.getSession(ctx)
+	if err != nil {
+		return handleError(err)
+	}
+
+	entity.SetTenantID(v, dao.TenantFromContext(ctx))
+	_, err = session.Collection(entity.Name).InsertOne(ctx, v)
+	return handleError(err)
+}
@@ -0,0 +1,361 @@
+package mongodb
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/adeo/turbine-go-api-skeleton/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	indexesTimeout = 5 * time.Minute
+
+	// indexID is the index created by mongodb on every collection, never declared
+	indexID = "_id_"
+	// indexKindText is the value of the text fields in the keys of an index
+	indexKindText = "text"
+
+	mongoErrorNamespaceNotFound = 26
+)
+
+// replacedIndexes are the names of the previous indexes replaced by the indexes of the collections, by collection
+// and index name, eg. the unique name of the templates before the tenancy, see Index.Replaces
+var replacedIndexes = map[string]map[string][]string{
+	"template": {"tenant_id_1_name_1": {"name_1"}}, // Template index
+}
+
+// Index is the declaration of a mongodb index
+type Index struct {
+	// Name identifies the index when looking for drift, it must be unique in the collection
+	Name string
+	// Keys are the indexed fields, in order, with their kind: 1, -1 or "text"
+	Keys   bson.D
+	Unique bool
+	// ExpireAfter makes a TTL index when not 0, the documents are removed this long after the date of the indexed field
+	ExpireAfter time.Duration
+	// ExpireAt makes a TTL index removing the documents at the date of the indexed field, ExpireAfter must be 0
+	ExpireAt bool
+	// PartialFilter only indexes the documents matching this filter when not nil
+	PartialFilter bson.M
+	// Replaces are the names of the previous indexes replaced by this one, dropped by a sync once it is created
+	Replaces []string
+}
+
+func (i Index) String() string {
+	keys := make([]string, 0, len(i.Keys))
+	for _, key := range i.Keys {
+		keys = append(keys, fmt.Sprintf("%s: %v", key.Key, key.Value))
+	}
+	description := fmt.Sprintf("%s {%s}", i.Name, strings.Join(keys, ", "))
+	if i.Unique {
+		description += " unique"
+	}
+	if i.ExpireAfter != 0 {
+		description += fmt.Sprintf(" expire after %s", i.ExpireAfter)
+	}
+	if i.ExpireAt {
+		description += " expire at date"
+	}
+	if i.PartialFilter != nil {
+		description += fmt.Sprintf(" partial filter %v", i.PartialFilter)
+	}
+	return description
+}
+
+func (i Index) model() mongo.IndexModel {
+	opts := options.Index().SetName(i.Name)
+	if i.Unique {
+		opts.SetUnique(true)
+	}
+	if i.ExpireAfter != 0 {
+		opts.SetExpireAfterSeconds(int32(i.ExpireAfter / time.Second))
+	}
+	if i.ExpireAt {
+		opts.SetExpireAfterSeconds(0)
+	}
+	if i.PartialFilter != nil {
+		opts.SetPartialFilterExpression(i.PartialFilter)
+	}
+	return mongo.IndexModel{Keys: i.Keys, Options: opts}
+}
+
+// equal returns whether both indexes have the same definition, ignoring the numeric types
+// and the order of the text fields
+func (i Index) equal(other Index) bool {
+	return i.Unique == other.Unique &&
+		i.ExpireAfter/time.Second == other.ExpireAfter/time.Second &&
+		i.ExpireAt == other.ExpireAt &&
+		reflect.DeepEqual(canonicalKeys(i.Keys), canonicalKeys(other.Keys)) &&
+		reflect.DeepEqual(canonical(i.PartialFilter), canonical(other.PartialFilter))
+}
+
+// IndexChange is an index existing with the name of a declared one, but another definition
+type IndexChange struct {
+	Declared Index
+	Existing Index
+}
+
+// IndexDrift is the difference between the declared and the existing indexes of a collection
+type IndexDrift struct {
+	Collection string
+	Missing    []Index       // declared but not existing
+	Different  []IndexChange // existing with another definition, they must be dropped to be recreated
+	Extra      []Index       // existing but not declared
+	Replaced   []Index       // existing but replaced by a declared one, see Index.Replaces
+}
+
+// Empty returns whether the existing indexes match the declared ones
+func (d IndexDrift) Empty() bool {
+	return len(d.Missing) == 0 && len(d.Different) == 0 && len(d.Extra) == 0 && len(d.Replaced) == 0
+}
+
+// DiffIndexes compares the declared indexes with the existing ones, collection by collection,
+// in the database of the tenant of the context in isolated tenancy
+func (db *DatabaseMongoDB) DiffIndexes(ctx context.Context) ([]IndexDrift, error) {
+	declared := declaredIndexes()
+	collections := make([]string, 0, len(declared))
+	for collection := range declared {
+		collections = append(collections, collection)
+	}
+	sort.Strings(collections)
+
+	drifts := make([]IndexDrift, 0, len(collections))
+	for _, collection := range collections {
+		existing, err := db.listIndexes(ctx, collection)
+		if err != nil {
+			return nil, fmt.Errorf("unable to list the indexes of %s: %v", collection, err)
+		}
+		drifts = append(drifts, diffIndexes(collection, declared[collection], existing))
+	}
+	return drifts, nil
+}
+
+// SyncIndexes creates the missing indexes, then drops the indexes they replace, and returns the drift found
+// before. The different and extra indexes are only reported, they are never dropped.
+func (db *DatabaseMongoDB) SyncIndexes(ctx context.Context) ([]IndexDrift, error) {
+	drifts, err := db.DiffIndexes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, drift := range drifts {
+		if len(drift.Missing) == 0 && len(drift.Replaced) == 0 {
+			continue
+		}
+		collection := db.database(ctx).Collection(drift.Collection)
+		err := backfillTenant(ctx, collection)
+		if err != nil {
+			return drifts, fmt.Errorf("unable to set the tenant of the documents of %s: %v", drift.Collection, err)
+		}
+		for _, index := range drift.Missing {
+			_, err := collection.Indexes().CreateOne(ctx, index.model())
+			if err != nil {
+				return drifts, fmt.Errorf("unable to create the index %s of %s: %v", index.Name, drift.Collection, err)
+			}
+		}
+		for _, index := range drift.Replaced {
+			_, err := collection.Indexes().DropOne(ctx, index.Name)
+			if err != nil {
+				return drifts, fmt.Errorf("unable to drop the replaced index %s of %s: %v", index.Name, drift.Collection, err)
+			}
+		}
+	}
+	return drifts, nil
+}
+
+// backfillTenant sets the empty tenant on the documents written before the tenancy, which have no tenant_id: they
+// belong to the empty tenant, but the unique indexes including the tenant_id would not compare them with the
+// documents written since with an empty tenant_id
+func backfillTenant(ctx context.Context, collection *mongo.Collection) error {
+	_, err := collection.UpdateMany(ctx, bson.M{"tenant_id": bson.M{"$exists": false}}, bson.M{"$set": bson.M{"tenant_id": ""}})
+	return err
+}
+
+// listIndexes returns the indexes of the collection, except the _id one
+func (db *DatabaseMongoDB) listIndexes(ctx context.Context, collection string) ([]Index, error) {
+	cur, err := db.database(ctx).Collection(collection).Indexes().List(ctx)
+	if ce, ok := err.(mongo.CommandError); ok && ce.Code == mongoErrorNamespaceNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	var indexes []Index
+	for cur.Next(ctx) {
+		var spec indexSpecification
+		err := cur.Decode(&spec)
+		if err != nil {
+			return nil, err
+		}
+		if spec.Name != indexID {
+			indexes = append(indexes, spec.index())
+		}
+	}
+	return indexes, cur.Err()
+}
+
+// indexSpecification is an index as listed by mongodb
+type indexSpecification struct {
+	Name                    string   `bson:"name"`
+	Key                     bson.D   `bson:"key"`
+	Unique                  bool     `bson:"unique"`
+	ExpireAfterSeconds      *float64 `bson:"expireAfterSeconds"`
+	PartialFilterExpression bson.M   `bson:"partialFilterExpression"`
+	Weights                 bson.M   `bson:"weights"`
+}
+
+func (s indexSpecification) index() Index {
+	index := Index{
+		Name:          s.Name,
+		Unique:        s.Unique,
+		PartialFilter: s.PartialFilterExpression,
+	}
+	if s.ExpireAfterSeconds != nil {
+		index.ExpireAfter = time.Duration(*s.ExpireAfterSeconds) * time.Second
+		index.ExpireAt = index.ExpireAfter == 0
+	}
+
+	// text indexes are listed with internal _fts and _ftsx keys, their fields are the weights
+	for _, key := range s.Key {
+		switch key.Key {
+		case "_fts":
+			fields := make([]string, 0, len(s.Weights))
+			for field := range s.Weights {
+				fields = append(fields, field)
+			}
+			sort.Strings(fields)
+			for _, field := range fields {
+				index.Keys = append(index.Keys, primitive.E{Key: field, Value: indexKindText})
+			}
+		case "_ftsx":
+		default:
+			index.Keys = append(index.Keys, key)
+		}
+	}
+	return index
+}
+
+func diffIndexes(collection string, declared, existing []Index) IndexDrift {
+	drift := IndexDrift{Collection: collection}
+
+	existingByName := make(map[string]Index, len(existing))
+	for _, index := range existing {
+		existingByName[index.Name] = index
+	}
+	replaced := make(map[string]bool)
+	for _, index := range declared {
+		for _, name := range index.Replaces {
+			replaced[name] = true
+		}
+	}
+	for _, index := range declared {
+		current, ok := existingByName[index.Name]
+		switch {
+		case !ok:
+			drift.Missing = append(drift.Missing, index)
+		case !index.equal(current):
+			drift.Different = append(drift.Different, IndexChange{Declared: index, Existing: current})
+		}
+		delete(existingByName, index.Name)
+	}
+	for _, index := range existing {
+		if _, ok := existingByName[index.Name]; !ok {
+			continue
+		}
+		if replaced[index.Name] {
+			drift.Replaced = append(drift.Replaced, index)
+		} else {
+			drift.Extra = append(drift.Extra, index)
+		}
+	}
+	return drift
+}
+
+// normalizeKeys sorts the consecutive text fields, their order does not matter to mongodb
+func normalizeKeys(keys bson.D) bson.D {
+	result := append(bson.D{}, keys...)
+	for start := 0; start < len(result); start++ {
+		end := start
+		for end < len(result) && result[end].Value == indexKindText {
+			end++
+		}
+		if end > start {
+			text := result[start:end]
+			sort.Slice(text, func(i, j int) bool { return text[i].Key < text[j].Key })
+			start = end
+		}
+	}
+	return result
+}
+
+// canonicalKeys converts index keys to a comparable form, keeping their order
+func canonicalKeys(keys bson.D) []interface{} {
+	result := make([]interface{}, 0, len(keys))
+	for _, key := range normalizeKeys(keys) {
+		result = append(result, key.Key, canonical(key.Value))
+	}
+	return result
+}
+
+// canonical converts a bson value to a comparable form: numbers become float64 and documents become maps
+func canonical(v interface{}) interface{} {
+	switch value := v.(type) {
+	case int:
+		return float64(value)
+	case int32:
+		return float64(value)
+	case int64:
+		return float64(value)
+	case bson.D:
+		result := make(map[string]interface{}, len(value))
+		for _, e := range value {
+			result[e.Key] = canonical(e.Value)
+		}
+		return result
+	case bson.M:
+		if value == nil {
+			return nil
+		}
+		result := make(map[string]interface{}, len(value))
+		for k, e := range value {
+			result[k] = canonical(e)
+		}
+		return result
+	case primitive.A:
+		result := make([]interface{}, 0, len(value))
+		for _, e := range value {
+			result = append(result, canonical(e))
+		}
+		return result
+	}
+	return v
+}
+
+// logIndexDrifts logs the indexes created by a sync, and the drift left
+func logIndexDrifts(drifts []IndexDrift) {
+	for _, drift := range drifts {
+		logger := utils.GetLogger().WithField("collection", drift.Collection)
+		for _, index := range drift.Missing {
+			logger.WithField("index", index.String()).Info("mongodb index created")
+		}
+		for _, change := range drift.Different {
+			logger.WithField("index", change.Declared.String()).WithField("existing", change.Existing.String()).
+				Warn("mongodb index differs from its declaration, drop it to recreate it")
+		}
+		for _, index := range drift.Extra {
+			logger.WithField("index", index.String()).Warn("mongodb index is not declared")
+		}
+		for _, index := range drift.Replaced {
+			logger.WithField("index", index.String()).Info("mongodb index replaced, dropped")
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package mongodb
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestIndexSpecification(t *testing.T) {
+	expire := float64(3600)
+	spec := indexSpecification{
+		Name:               "text",
+		Key:                bson.D{{Key: "tenant", Value: int32(1)}, {Key: "_fts", Value: "text"}, {Key: "_ftsx", Value: int32(1)}},
+		ExpireAfterSeconds: &expire,
+		Weights:            bson.M{"name": int32(1), "description": int32(1)},
+	}
+
+	assert.Equal(t, Index{
+		Name:        "text",
+		Keys:        bson.D{{Key: "tenant", Value: int32(1)}, {Key: "description", Value: "text"}, {Key: "name", Value: "text"}},
+		ExpireAfter: time.Hour,
+	}, spec.index())
+
+	expire = 0
+	spec = indexSpecification{Name: "expires_at_1", Key: bson.D{{Key: "expires_at", Value: int32(1)}}, ExpireAfterSeconds: &expire}
+	assert.Equal(t, Index{
+		Name:     "expires_at_1",
+		Keys:     bson.D{{Key: "expires_at", Value: int32(1)}},
+		ExpireAt: true,
+	}, spec.index())
+}
+
+func TestDiffIndexes(t *testing.T) {
+	declared := []Index{
+		{Name: "name_1", Keys: bson.D{{Key: "name", Value: 1}}, Unique: true},
+		{Name: "text", Keys: bson.D{{Key: "name", Value: "text"}, {Key: "description", Value: "text"}}},
+		{Name: "created_at_1", Keys: bson.D{{Key: "created_at", Value: 1}}, ExpireAfter: time.Hour},
+		{Name: "code_1", Keys: bson.D{{Key: "code", Value: 1}}, PartialFilter: bson.M{"code": bson.M{"$exists": true}}},
+	}
+	existing := []Index{
+		{Name: "name_1", Keys: bson.D{{Key: "name", Value: int32(1)}}, Unique: true},
+		{Name: "text", Keys: bson.D{{Key: "description", Value: "text"}, {Key: "name", Value: "text"}}},
+		{Name: "created_at_1", Keys: bson.D{{Key: "created_at", Value: int32(1)}}},
+		{Name: "updated_at_1", Keys: bson.D{{Key: "updated_at", Value: int32(1)}}},
+	}
+
+	drift := diffIndexes("template", declared, existing)
+
+	assert.False(t, drift.Empty())
+	assert.Equal(t, []Index{declared[3]}, drift.Missing)
+	assert.Equal(t, []IndexChange{{Declared: declared[2], Existing: existing[2]}}, drift.Different)
+	assert.Equal(t, []Index{existing[3]}, drift.Extra)
+	assert.True(t, diffIndexes("template", declared[:2], existing[:2]).Empty())
+
+	declared[3].Replaces = []string{"updated_at_1"}
+	drift = diffIndexes("template", declared, existing)
+	assert.Equal(t, []Index{existing[3]}, drift.Replaced)
+	assert.Empty(t, drift.Extra)
+}
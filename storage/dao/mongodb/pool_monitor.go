@@ -0,0 +1,78 @@
+package mongodb
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/adeo/turbine-go-api-skeleton/storage/dao"
+	"go.mongodb.org/mongo-driver/event"
+)
+
+// poolCounters are the counters of the connection pool of one server
+type poolCounters struct {
+	open             int
+	inUse            int
+	checkOutFailures int64
+}
+
+// poolMonitor keeps the statistics of the connection pools from the events of the driver, one pool per server
+type poolMonitor struct {
+	mu          sync.Mutex
+	maxPoolSize int
+	pools       map[string]*poolCounters
+}
+
+func newPoolMonitor(maxPoolSize int) *poolMonitor {
+	return &poolMonitor{
+		maxPoolSize: maxPoolSize,
+		pools:       map[string]*poolCounters{},
+	}
+}
+
+func (m *poolMonitor) monitor() *event.PoolMonitor {
+	return &event.PoolMonitor{Event: m.handle}
+}
+
+func (m *poolMonitor) handle(e *event.PoolEvent) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	pool, ok := m.pools[e.Address]
+	if !ok {
+		pool = &poolCounters{}
+		m.pools[e.Address] = pool
+	}
+	switch e.Type {
+	case event.ConnectionCreated:
+		pool.open++
+	case event.ConnectionClosed:
+		pool.open--
+	case event.GetSucceeded:
+		pool.inUse++
+	case event.ConnectionReturned:
+		pool.inUse--
+	case event.GetFailed:
+		pool.checkOutFailures++
+	case event.PoolClosedEvent:
+		delete(m.pools, e.Address)
+	}
+}
+
+func (m *poolMonitor) stats() []dao.PoolStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stats := make([]dao.PoolStats, 0, len(m.pools))
+	for address, pool := range m.pools {
+		stats = append(stats, dao.PoolStats{
+			Name:               address,
+			MaxOpenConnections: m.maxPoolSize,
+			OpenConnections:    pool.open,
+			InUse:              pool.inUse,
+			Idle:               pool.open - pool.inUse,
+			CheckOutFailures:   pool.checkOutFailures,
+		})
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Name < stats[j].Name })
+	return stats
+}
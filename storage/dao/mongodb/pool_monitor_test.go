@@ -0,0 +1,28 @@
+package mongodb
+
+import (
+	"testing"
+
+	"github.com/adeo/turbine-go-api-skeleton/storage/dao"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/event"
+)
+
+func TestPoolMonitor(t *testing.T) {
+	m := newPoolMonitor(10)
+	for _, e := range []string{
+		event.ConnectionCreated, event.ConnectionCreated, event.GetSucceeded,
+		event.GetSucceeded, event.ConnectionReturned, event.GetFailed,
+	} {
+		m.handle(&event.PoolEvent{Type: e, Address: "localhost:27017"})
+	}
+	m.handle(&event.PoolEvent{Type: event.ConnectionCreated, Address: "localhost:27018"})
+
+	assert.Equal(t, []dao.PoolStats{
+		{Name: "localhost:27017", MaxOpenConnections: 10, OpenConnections: 2, InUse: 1, Idle: 1, CheckOutFailures: 1},
+		{Name: "localhost:27018", MaxOpenConnections: 10, OpenConnections: 1, Idle: 1},
+	}, m.stats())
+
+	m.handle(&event.PoolEvent{Type: event.PoolClosedEvent, Address: "localhost:27018"})
+	assert.Len(t, m.stats(), 1)
+}
@@ -1,7 +1,14 @@
 package postgresql
 
 import (
+	"context"
 	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/adeo/turbine-go-api-skeleton/storage/dao"
 	"github.com/adeo/turbine-go-api-skeleton/utils"
@@ -9,33 +16,370 @@ import (
 )
 
 const (
-	pgCodeUniqueViolation     = "23505"
-	pgCodeForeingKeyViolation = "23503"
+	pgCodeUniqueViolation       = "23505"
+	pgCodeForeingKeyViolation   = "23503"
+	pgCodeNotNullViolation      = "23502"
+	pgCodeCheckViolation        = "23514"
+	pgCodeExclusionViolation    = "23P01"
+	pgCodeInvalidText           = "22P02"
+	pgCodeSerializationFailure  = "40001"
+	pgCodeDeadlockDetected      = "40P01"
+	pgCodeQueryCanceled         = "57014"
+	pgCodeAdminShutdown         = "57P01"
+	pgCodeCrashShutdown         = "57P02"
+	pgCodeCannotConnectNow      = "57P03"
+	pgClassConnectionException  = "08"
+	pgClassInsufficientResource = "53"
+
+	// readYourWritesWindow is the delay after a write during which the reads of its caller are sent to the primary,
+	// so that replication lag does not hide the data it has just written
+	readYourWritesWindow = 5 * time.Second
+
+	healthCheckInterval = 5 * time.Second
+	healthCheckTimeout  = 2 * time.Second
+
+	// defaultSchema is the schema of the tables, except in isolated tenancy where each tenant has its own schema
+	defaultSchema = "schema"
+	// tenantSchemaPrefix prefixes the tenant to name its schema in isolated tenancy
+	tenantSchemaPrefix = "tenant_"
 )
 
+// handleError converts the errors of the postgres driver to dao errors, when they have a matching type
+func handleError(err error) error {
+	var e *pq.Error
+	if errors.As(err, &e) {
+		return handlePgError(e)
+	}
+	if errors.Is(err, driver.ErrBadConn) {
+		return dao.NewDAOError(dao.ErrTypeUnavailable, err)
+	}
+	return dao.Classify(err)
+}
+
 func handlePgError(e *pq.Error) error {
-	if e.Code == pgCodeUniqueViolation {
-		return dao.NewDAOError(dao.ErrTypeDuplicate, e)
+	// the column is only given for some violations, the constraint name is the best hint otherwise
+	field := e.Column
+	if field == "" {
+		field = e.Constraint
 	}
 
-	if e.Code == pgCodeForeingKeyViolation {
-		return dao.NewDAOError(dao.ErrTypeForeignKeyViolation, e)
+	switch e.Code {
+	case pgCodeUniqueViolation:
+		return dao.NewFieldDAOError(dao.ErrTypeDuplicate, field, e)
+	case pgCodeForeingKeyViolation:
+		return dao.NewFieldDAOError(dao.ErrTypeForeignKeyViolation, field, e)
+	case pgCodeNotNullViolation, pgCodeCheckViolation, pgCodeExclusionViolation:
+		return dao.NewFieldDAOError(dao.ErrTypeConstraintViolation, field, e)
+	case pgCodeInvalidText:
+		// the ids are the only strings converted by the database, eg. an id which is not a uuid for a uuid column
+		return dao.NewDAOError(dao.ErrTypeInvalidID, e)
+	case pgCodeSerializationFailure, pgCodeDeadlockDetected:
+		return dao.NewDAOError(dao.ErrTypeConflict, e)
+	case pgCodeQueryCanceled:
+		// canceled by the statement timeout, or by the driver when the context is done
+		return dao.NewDAOError(dao.ErrTypeTimeout, e)
+	case pgCodeAdminShutdown, pgCodeCrashShutdown, pgCodeCannotConnectNow:
+		return dao.NewDAOError(dao.ErrTypeUnavailable, e)
+	}
+	switch string(e.Code.Class()) {
+	case pgClassConnectionException, pgClassInsufficientResource:
+		return dao.NewDAOError(dao.ErrTypeUnavailable, e)
 	}
 	return e
 }
 
+// PoolConfig is the configuration of the connection pools, the zero values keep the database/sql defaults
+type PoolConfig struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+}
+
+func (c PoolConfig) apply(db *sql.DB) {
+	if c.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(c.MaxOpenConns)
+	}
+	if c.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(c.MaxIdleConns)
+	}
+	if c.ConnMaxLifetime > 0 {
+		db.SetConnMaxLifetime(c.ConnMaxLifetime)
+	}
+}
+
 type DatabasePostgreSQL struct {
-	session *sql.DB
+	session     *sql.DB // primary, used for writes and as fallback for reads
+	readSession *sql.DB // read replicas, nil when no read connection uri is given
+	readHealthy int32   // 1 when the last health check of the read replicas succeeded
+	tenancy     dao.Tenancy
+	stop        chan struct{}
+	stopOnce    sync.Once // Close may be called several times, eg. by a routing database and the handlers
+
+	lastWritesMu sync.Mutex
+	lastWrites   map[string]time.Time // time of the last write of each caller, see callerKey
+
+	purgedMu sync.Mutex
+	purged   map[string]int64 // number of purged expired rows, by table
+}
+
+func init() {
+	dao.Register("postgresql", open)
+	dao.Register("postgres", open)
+}
+
+// open is the dao driver of postgresql, configured by a PoolConfig setting
+func open(opts dao.Options) (dao.Database, error) {
+	pool := PoolConfig{}
+	expiry := dao.ExpiryConfig{}
+	for _, setting := range opts.Settings {
+		switch s := setting.(type) {
+		case PoolConfig:
+			pool = s
+		case dao.ExpiryConfig:
+			expiry = s
+		}
+	}
+	db, err := NewDatabasePostgreSQL(opts.ConnectionURI, opts.ReadConnectionURI, pool, opts.Tenancy)
+	if err == nil && expiry.PurgeInterval > 0 {
+		go db.(*DatabasePostgreSQL).purgeEvery(expiry.PurgeInterval)
+	}
+	return db, err
 }
 
-func NewDatabasePostgreSQL(connectionURI string) dao.Database {
+func NewDatabasePostgreSQL(connectionURI, readConnectionURI string, pool PoolConfig, tenancy dao.Tenancy) (dao.Database, error) {
 	db, err := sql.Open("postgres", connectionURI)
 	if err != nil {
-		utils.GetLogger().WithError(err).Fatal("Unable to get a connection to the postgres db")
+		return nil, fmt.Errorf("unable to get a connection to the postgres db: %v", err)
 	}
+	pool.apply(db)
 	err = db.Ping()
 	if err != nil {
-		utils.GetLogger().WithError(err).Fatal("Unable to ping the postgres db")
+		_ = db.Close()
+		return nil, fmt.Errorf("unable to ping the postgres db: %v", err)
+	}
+
+	result := &DatabasePostgreSQL{
+		session:    db,
+		tenancy:    tenancy,
+		stop:       make(chan struct{}),
+		lastWrites: make(map[string]time.Time),
+		purged:     make(map[string]int64),
+	}
+
+	if readConnectionURI != "" {
+		result.readSession, err = sql.Open("postgres", readConnectionURI)
+		if err != nil {
+			_ = db.Close()
+			return nil, fmt.Errorf("unable to get a connection to the postgres read replicas: %v", err)
+		}
+		pool.apply(result.readSession)
+		// replicas being down at startup is not fatal, reads fall back to the primary until they are back
+		result.checkReadSession()
+		go result.monitorReadSession()
+	}
+
+	return result, nil
+}
+
+// table returns the qualified name of the given table, in the schema of the tenant of the call in isolated tenancy
+func (db *DatabasePostgreSQL) table(ctx context.Context, name string) string {
+	schema := defaultSchema
+	if tenant := dao.TenantFromContext(ctx); db.tenancy == dao.TenancyIsolated && tenant != "" {
+		schema = tenantSchemaPrefix + tenant
+	}
+	return pq.QuoteIdentifier(schema) + "." + pq.QuoteIdentifier(name)
+}
+
+// callerKey identifies the caller of the call in its tenant, to route its reads after its writes
+func callerKey(ctx context.Context) string {
+	return dao.TenantFromContext(ctx) + "/" + dao.CallerFromContext(ctx)
+}
+
+// reader returns the pool to use for the read queries: the read replicas when they are healthy and the caller
+// did not write recently, the primary otherwise
+func (db *DatabasePostgreSQL) reader(ctx context.Context) *sql.DB {
+	if db.readSession == nil || atomic.LoadInt32(&db.readHealthy) == 0 {
+		return db.session
+	}
+	db.lastWritesMu.Lock()
+	lastWrite, ok := db.lastWrites[callerKey(ctx)]
+	db.lastWritesMu.Unlock()
+	if ok && time.Since(lastWrite) < readYourWritesWindow {
+		return db.session
+	}
+	return db.readSession
+}
+
+// writer returns the primary pool, and routes the following reads of the caller to the primary for
+// readYourWritesWindow
+func (db *DatabasePostgreSQL) writer(ctx context.Context) *sql.DB {
+	if db.readSession != nil {
+		db.lastWritesMu.Lock()
+		db.lastWrites[callerKey(ctx)] = time.Now()
+		db.lastWritesMu.Unlock()
+	}
+	return db.session
+}
+
+// forgetWrites removes the callers whose last write is older than readYourWritesWindow
+func (db *DatabasePostgreSQL) forgetWrites() {
+	db.lastWritesMu.Lock()
+	defer db.lastWritesMu.Unlock()
+	for caller, lastWrite := range db.lastWrites {
+		if time.Since(lastWrite) >= readYourWritesWindow {
+			delete(db.lastWrites, caller)
+		}
+	}
+}
+
+func (db *DatabasePostgreSQL) monitorReadSession() {
+	ticker := time.NewTicker(healthCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			db.checkReadSession()
+			db.forgetWrites()
+		case <-db.stop:
+			return
+		}
+	}
+}
+
+func (db *DatabasePostgreSQL) checkReadSession() {
+	ctx, cancel := context.WithTimeout(context.Background(), healthCheckTimeout)
+	defer cancel()
+
+	err := db.readSession.PingContext(ctx)
+	if err != nil {
+		if atomic.SwapInt32(&db.readHealthy, 0) == 1 {
+			utils.GetLogger().WithError(err).Error("postgres read replicas are down, falling back to the primary for reads")
+		}
+		return
+	}
+	if atomic.SwapInt32(&db.readHealthy, 1) == 0 {
+		utils.GetLogger().Info("postgres read replicas are up, using them for reads")
+	}
+}
+
+// Ping checks the primary is reachable, the read replicas falling back to it when they are down
+func (db *DatabasePostgreSQL) Ping(ctx context.Context) error {
+	return db.session.PingContext(ctx)
+}
+
+// Purge deletes the expired rows of the tables of the registered entities having an expiry date, in every schema
+// having them: the expired rows are already hidden from the reads
+func (db *DatabasePostgreSQL) Purge(ctx context.Context) error {
+	for _, table := range expiringEntities() {
+		schemas, err := db.schemas(ctx, table)
+		if err != nil {
+			return handleError(err)
+		}
+		for _, schema := range schemas {
+			q := fmt.Sprintf(`
+				DELETE FROM %s
+				WHERE expires_at <= now()
+			`, pq.QuoteIdentifier(schema)+"."+pq.QuoteIdentifier(table))
+			// the purge is not a write of a caller, the reads stay on the read replicas
+			r, err := db.session.ExecContext(ctx, q)
+			if err != nil {
+				return handleError(err)
+			}
+			count, err := r.RowsAffected()
+			if err != nil {
+				return handleError(err)
+			}
+			db.purgedMu.Lock()
+			db.purged[table] += count
+			db.purgedMu.Unlock()
+		}
+	}
+	return nil
+}
+
+// schemas returns the schemas having the given table: the default one, and the ones of the tenants in isolated tenancy
+func (db *DatabasePostgreSQL) schemas(ctx context.Context, table string) ([]string, error) {
+	rows, err := db.session.QueryContext(ctx, `
+		SELECT table_schema
+		FROM information_schema.tables
+		WHERE table_name = $1 AND (table_schema = $2 OR left(table_schema, length($3)) = $3)
+	`, table, defaultSchema, tenantSchemaPrefix)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	schemas := make([]string, 0)
+	for rows.Next() {
+		var schema string
+		if err := rows.Scan(&schema); err != nil {
+			return nil, err
+		}
+		schemas = append(schemas, schema)
+	}
+	return schemas, rows.Err()
+}
+
+func (db *DatabasePostgreSQL) purgeEvery(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), interval)
+			if err := db.Purge(ctx); err != nil {
+				utils.GetLogger().WithError(err).Error("error while purging the expired rows")
+			}
+			cancel()
+		case <-db.stop:
+			return
+		}
+	}
+}
+
+// PurgedCounts returns the number of expired rows purged since the database was opened, by table
+func (db *DatabasePostgreSQL) PurgedCounts() map[string]int64 {
+	db.purgedMu.Lock()
+	defer db.purgedMu.Unlock()
+
+	counts := make(map[string]int64, len(db.purged))
+	for table, count := range db.purged {
+		counts[table] = count
+	}
+	return counts
+}
+
+// Close stops the background tasks and closes the pools
+func (db *DatabasePostgreSQL) Close() error {
+	db.stopOnce.Do(func() { close(db.stop) })
+	err := db.session.Close()
+	if db.readSession != nil {
+		if errRead := db.readSession.Close(); err == nil {
+			err = errRead
+		}
+	}
+	return err
+}
+
+// PoolStats returns the statistics of the primary pool, and of the read replicas pool if any
+func (db *DatabasePostgreSQL) PoolStats() []dao.PoolStats {
+	stats := []dao.PoolStats{newPoolStats("primary", db.session.Stats())}
+	if db.readSession != nil {
+		stats = append(stats, newPoolStats("replicas", db.readSession.Stats()))
+	}
+	return stats
+}
+
+func newPoolStats(name string, s sql.DBStats) dao.PoolStats {
+	return dao.PoolStats{
+		Name:                name,
+		MaxOpenConnections:  s.MaxOpenConnections,
+		OpenConnections:     s.OpenConnections,
+		InUse:               s.InUse,
+		Idle:                s.Idle,
+		WaitCount:           s.WaitCount,
+		WaitDurationSeconds: s.WaitDuration.Seconds(),
+		MaxIdleClosed:       s.MaxIdleClosed,
+		MaxLifetimeClosed:   s.MaxLifetimeClosed,
 	}
-	return &DatabasePostgreSQL{session: db}
 }
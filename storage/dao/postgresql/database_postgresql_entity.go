@@ -0,0 +1,372 @@
+package postgresql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/adeo/turbine-go-api-skeleton/storage/dao"
+	"github.com/lib/pq"
+)
+
+// The registered entities are stored in the table having their name, with the columns id (uuid or text generated by the
+// database), tenant_id, created_at (default now()), updated_at, and a column per other field of their model named
+// by its db tag, or by its JSON name. The structs, maps and slices are stored as JSON, in jsonb columns. The
+// expiry date must be in the expires_at column to be purged.
+
+var (
+	typeTime    = reflect.TypeOf(time.Time{})
+	typeScanner = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+)
+
+// columnsEntity maps the fields of an entity to the columns of its table
+type columnsEntity struct {
+	entity dao.Entity
+	fields []dao.Field // the fields which are not managed by the database
+	expiry string      // the column of the expiry date, empty when the entity never expires
+}
+
+func newColumnsEntity(entity dao.Entity) columnsEntity {
+	c := columnsEntity{entity: entity}
+	for _, field := range entity.Fields {
+		if field.Managed {
+			continue
+		}
+		c.fields = append(c.fields, field)
+		if field.Name == "ExpiresAt" {
+			c.expiry = field.Column
+		}
+	}
+	return c
+}
+
+// lookupEntity returns the columns of the registered entity with the given name
+func lookupEntity(name string) (columnsEntity, error) {
+	entity, err := dao.LookupEntity(name)
+	if err != nil {
+		return columnsEntity{}, err
+	}
+	return newColumnsEntity(entity), nil
+}
+
+// expiringEntities returns the tables of the registered entities having an expiry date
+func expiringEntities() []string {
+	tables := make([]string, 0)
+	for _, entity := range dao.Entities() {
+		if entity.Expires() {
+			tables = append(tables, entity.Name)
+		}
+	}
+	return tables
+}
+
+// columns returns the quoted columns of the fields which are not managed, prefixed by the given ones
+func (c columnsEntity) columns(managed ...string) string {
+	columns := append([]string(nil), managed...)
+	for _, field := range c.fields {
+		columns = append(columns, pq.QuoteIdentifier(field.Column))
+	}
+	return strings.Join(columns, ", ")
+}
+
+// placeholders returns the placeholders $from to $to, separated by commas
+func placeholders(from, to int) string {
+	result := make([]string, 0, to-from+1)
+	for i := from; i <= to; i++ {
+		result = append(result, fmt.Sprintf("$%d", i))
+	}
+	return strings.Join(result, ", ")
+}
+
+// assignments returns the assignments of the columns of the fields which are not managed, from the placeholder $from
+func (c columnsEntity) assignments(from int) string {
+	result := make([]string, 0, len(c.fields))
+	for i, field := range c.fields {
+		result = append(result, fmt.Sprintf("%s = $%d", pq.QuoteIdentifier(field.Column), from+i))
+	}
+	return strings.Join(result, ", ")
+}
+
+// notExpired returns the condition of the rows which are not expired
+func (c columnsEntity) notExpired() string {
+	if c.expiry == "" {
+		return "TRUE"
+	}
+	column := pq.QuoteIdentifier(c.expiry)
+	return fmt.Sprintf("(%s IS NULL OR %s > now())", column, column)
+}
+
+// selected returns the selected columns of the rows
+func (c columnsEntity) selected() string {
+	return c.columns("id", "tenant_id", "created_at", "updated_at")
+}
+
+// values returns the arguments of the columns of the fields which are not managed
+func (c columnsEntity) values(v interface{}) []interface{} {
+	values := make([]interface{}, 0, len(c.fields))
+	for _, field := range c.fields {
+		value := c.entity.Value(v, field)
+		if isJSON(field.Type) {
+			values = append(values, jsonColumn{value: value})
+			continue
+		}
+		values = append(values, value.Interface())
+	}
+	return values
+}
+
+// scan reads a row of the selected columns in a new value
+func (c columnsEntity) scan(row interface{ Scan(...interface{}) error }) (interface{}, error) {
+	v := c.entity.New()
+	var id, tenantID string
+	var createdAt time.Time
+	var updatedAt *time.Time
+	dest := []interface{}{&id, &tenantID, &createdAt, &updatedAt}
+	for _, field := range c.fields {
+		value := c.entity.Value(v, field)
+		if isJSON(field.Type) {
+			dest = append(dest, jsonColumn{value: value})
+			continue
+		}
+		dest = append(dest, value.Addr().Interface())
+	}
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+	c.entity.SetID(v, id)
+	c.entity.SetTenantID(v, tenantID)
+	c.entity.SetCreatedAt(v, createdAt)
+	c.entity.SetUpdatedAt(v, updatedAt)
+	return v, nil
+}
+
+// scanRows reads all the rows of the selected columns
+func (c columnsEntity) scanRows(rows *sql.Rows) ([]interface{}, error) {
+	defer rows.Close()
+
+	results := make([]interface{}, 0)
+	for rows.Next() {
+		v, err := c.scan(rows)
+		if err != nil {
+			return nil, handleError(err)
+		}
+		results = append(results, v)
+	}
+	return results, handleError(rows.Err())
+}
+
+// isJSON tells if the values of the type are stored as JSON
+func isJSON(t reflect.Type) bool {
+	if reflect.PtrTo(t).Implements(typeScanner) {
+		return false
+	}
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		return t != typeTime
+	case reflect.Slice:
+		return t.Elem().Kind() != reflect.Uint8
+	case reflect.Map, reflect.Array:
+		return true
+	}
+	return false
+}
+
+// jsonColumn reads and writes a field stored as JSON, SQL NULL being its zero value
+type jsonColumn struct {
+	value reflect.Value
+}
+
+func (c jsonColumn) Value() (driver.Value, error) {
+	switch c.value.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Map:
+		if c.value.IsNil() {
+			return nil, nil
+		}
+	}
+	b, err := json.Marshal(c.value.Interface())
+	return string(b), err
+}
+
+func (c jsonColumn) Scan(src interface{}) error {
+	switch data := src.(type) {
+	case nil:
+		c.value.Set(reflect.Zero(c.value.Type()))
+		return nil
+	case []byte:
+		return json.Unmarshal(data, c.value.Addr().Interface())
+	case string:
+		return json.Unmarshal([]byte(data), c.value.Addr().Interface())
+	}
+	return fmt.Errorf("unable to read %T as JSON", src)
+}
+
+func (db *DatabasePostgreSQL) GetAllEntities(ctx context.Context, name string) ([]interface{}, error) {
+	c, err := lookupEntity(name)
+	if err != nil {
+		return nil, err
+	}
+	q := fmt.Sprintf(`
+		SELECT %s
+		FROM %s
+		WHERE tenant_id = $1 AND %s
+		ORDER BY created_at, id
+	`, c.selected(), db.table(ctx, name), c.notExpired())
+	rows, err := db.reader(ctx).QueryContext(ctx, q, dao.TenantFromContext(ctx))
+	if err != nil {
+		return nil, handleError(err)
+	}
+	return c.scanRows(rows)
+}
+
+func (db *DatabasePostgreSQL) GetEntityByID(ctx context.Context, name, id string) (interface{}, error) {
+	c, err := lookupEntity(name)
+	if err != nil {
+		return nil, err
+	}
+	q := fmt.Sprintf(`
+		SELECT %s
+		FROM %s
+		WHERE id = $1 AND tenant_id = $2 AND %s
+	`, c.selected(), db.table(ctx, name), c.notExpired())
+	row := db.reader(ctx).QueryRowContext(ctx, q, id, dao.TenantFromContext(ctx))
+
+	v, err := c.scan(row)
+	if err == sql.ErrNoRows {
+		return nil, dao.NewDAOError(dao.ErrTypeNotFound, err)
+	}
+	if err != nil {
+		return nil, handleError(err)
+	}
+	return v, nil
+}
+
+func (db *DatabasePostgreSQL) CreateEntity(ctx context.Context, name string, v interface{}) error {
+	c, err := lookupEntity(name)
+	if err != nil {
+		return err
+	}
+	q := fmt.Sprintf(`
+		INSERT INTO %s
+			(%s)
+		VALUES
+			(%s)
+		RETURNING id, created_at
+	`, db.table(ctx, name), c.columns("tenant_id"), placeholders(1, len(c.fields)+1))
+
+	c.entity.SetTenantID(v, dao.TenantFromContext(ctx))
+	c.entity.SetUpdatedAt(v, nil)
+	var id string
+	var createdAt time.Time
+	args := append([]interface{}{c.entity.TenantID(v)}, c.values(v)...)
+	err = db.writer(ctx).QueryRowContext(ctx, q, args...).Scan(&id, &createdAt)
+	if err != nil {
+		return handleError(err)
+	}
+	c.entity.SetID(v, id)
+	c.entity.SetCreatedAt(v, createdAt)
+	return nil
+}
+
+func (db *DatabasePostgreSQL) DeleteEntity(ctx context.Context, name, id string) error {
+	c, err := lookupEntity(name)
+	if err != nil {
+		return err
+	}
+	q := fmt.Sprintf(`
+		DELETE FROM %s
+		WHERE id = $1 AND tenant_id = $2 AND %s
+	`, db.table(ctx, name), c.notExpired())
+
+	r, err := db.writer(ctx).ExecContext(ctx, q, id, dao.TenantFromContext(ctx))
+	if err != nil {
+		return handleError(err)
+	}
+	count, err := r.RowsAffected()
+	if err != nil {
+		return handleError(err)
+	}
+	if count == 0 {
+		return dao.NewDAOError(dao.ErrTypeNotFound, sql.ErrNoRows)
+	}
+	return nil
+}
+
+func (db *DatabasePostgreSQL) UpdateEntity(ctx context.Context, name string, v interface{}) error {
+	c, err := lookupEntity(name)
+	if err != nil {
+		return err
+	}
+	assignments := c.assignments(3)
+	if assignments != "" {
+		assignments += ","
+	}
+	q := fmt.Sprintf(`
+		UPDATE %s
+		SET
+			%s
+			updated_at = now()
+		WHERE id = $1 AND tenant_id = $2 AND %s
+		RETURNING tenant_id, created_at, updated_at
+	`, db.table(ctx, name), assignments, c.notExpired())
+
+	var tenantID string
+	var createdAt time.Time
+	var updatedAt *time.Time
+	args := append([]interface{}{c.entity.ID(v), dao.TenantFromContext(ctx)}, c.values(v)...)
+	err = db.writer(ctx).QueryRowContext(ctx, q, args...).Scan(&tenantID, &createdAt, &updatedAt)
+	if err == sql.ErrNoRows {
+		return dao.NewDAOError(dao.ErrTypeNotFound, err)
+	}
+	if err != nil {
+		return handleError(err)
+	}
+	c.entity.SetTenantID(v, tenantID)
+	c.entity.SetCreatedAt(v, createdAt)
+	c.entity.SetUpdatedAt(v, updatedAt)
+	return nil
+}
+
+func (db *DatabasePostgreSQL) GetEntitiesAfter(ctx context.Context, name, afterID string, limit int) ([]interface{}, error) {
+	c, err := lookupEntity(name)
+	if err != nil {
+		return nil, err
+	}
+	q := fmt.Sprintf(`
+		SELECT %s
+		FROM %s
+		WHERE tenant_id = $1 AND id::text > $2 COLLATE "C" AND %s
+		ORDER BY id::text COLLATE "C"
+		LIMIT $3
+	`, c.selected(), db.table(ctx, name), c.notExpired())
+	rows, err := db.reader(ctx).QueryContext(ctx, q, dao.TenantFromContext(ctx), afterID, limit)
+	if err != nil {
+		return nil, handleError(err)
+	}
+	return c.scanRows(rows)
+}
+
+func (db *DatabasePostgreSQL) RestoreEntity(ctx context.Context, name string, v interface{}) error {
+	c, err := lookupEntity(name)
+	if err != nil {
+		return err
+	}
+	q := fmt.Sprintf(`
+		INSERT INTO %s
+			(%s)
+		VALUES
+			(%s)
+	`, db.table(ctx, name), c.columns("id", "tenant_id", "created_at", "updated_at"), placeholders(1, len(c.fields)+4))
+
+	c.entity.SetTenantID(v, dao.TenantFromContext(ctx))
+	args := append([]interface{}{c.entity.ID(v), c.entity.TenantID(v), c.entity.CreatedAt(v), c.entity.UpdatedAt(v)}, c.values(v)...)
+	_, err = db.writer(ctx).ExecContext(ctx, q, args...)
+	return handleError(err)
+}
@@ -0,0 +1,99 @@
+package postgresql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/adeo/turbine-go-api-skeleton/storage/dao"
+	"github.com/adeo/turbine-go-api-skeleton/storage/dao/daotest"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	envTestConnectionURI = "DAOTEST_POSTGRESQL_URI"
+)
+
+// TestDatabasePostgreSQL runs the dao conformance suite against a local postgres, when its uri is given
+func TestDatabasePostgreSQL(t *testing.T) {
+	connectionURI := os.Getenv(envTestConnectionURI)
+	if connectionURI == "" {
+		t.Skipf("%s is not set, skipping postgresql conformance tests", envTestConnectionURI)
+	}
+
+	db, err := NewDatabasePostgreSQL(connectionURI, "", PoolConfig{}, dao.TenancyShared)
+	if err != nil {
+		t.Fatal(err)
+	}
+	daotest.Run(t, func(t *testing.T) dao.Database {
+		return db
+	})
+}
+
+func TestHandleError(t *testing.T) {
+	err := handleError(&pq.Error{Code: pgCodeUniqueViolation, Constraint: "template_code_key"})
+	assert.True(t, errors.Is(err, dao.ErrDuplicate))
+	e, _ := dao.AsDAOError(err)
+	assert.Equal(t, "template_code_key", e.Field)
+
+	err = handleError(&pq.Error{Code: pgCodeNotNullViolation, Column: "code"})
+	assert.True(t, errors.Is(err, dao.ErrConstraintViolation))
+	e, _ = dao.AsDAOError(err)
+	assert.Equal(t, "code", e.Field)
+
+	assert.True(t, errors.Is(handleError(&pq.Error{Code: pgCodeSerializationFailure}), dao.ErrConflict))
+	assert.True(t, errors.Is(handleError(&pq.Error{Code: pgCodeQueryCanceled}), dao.ErrTimeout))
+	assert.True(t, errors.Is(handleError(&pq.Error{Code: pgCodeInvalidText}), dao.ErrInvalidID))
+	assert.True(t, errors.Is(handleError(&pq.Error{Code: "08006"}), dao.ErrUnavailable))
+	assert.True(t, errors.Is(handleError(driver.ErrBadConn), dao.ErrUnavailable))
+	assert.True(t, errors.Is(handleError(context.Canceled), dao.ErrCanceled))
+
+	syntax := &pq.Error{Code: "42601"}
+	assert.Equal(t, syntax, handleError(syntax))
+	assert.Nil(t, handleError(nil))
+}
+
+func TestReadYourWrites(t *testing.T) {
+	// the pools connect on their first query only
+	primary, err := sql.Open("postgres", "postgres://primary/app")
+	require.NoError(t, err)
+	replicas, err := sql.Open("postgres", "postgres://replicas/app")
+	require.NoError(t, err)
+	db := &DatabasePostgreSQL{session: primary, readSession: replicas, readHealthy: 1, lastWrites: make(map[string]time.Time)}
+
+	caller := dao.WithCaller(dao.WithTenant(context.Background(), "tenant-a"), "caller-1")
+	otherCaller := dao.WithCaller(dao.WithTenant(context.Background(), "tenant-a"), "caller-2")
+	otherTenant := dao.WithCaller(dao.WithTenant(context.Background(), "tenant-b"), "caller-1")
+	assert.True(t, db.reader(caller) == replicas)
+
+	// only the caller reads its writes from the primary
+	assert.True(t, db.writer(caller) == primary)
+	assert.True(t, db.reader(caller) == primary)
+	assert.True(t, db.reader(otherCaller) == replicas)
+	assert.True(t, db.reader(otherTenant) == replicas)
+
+	// until the end of the window
+	db.lastWrites[callerKey(caller)] = time.Now().Add(-readYourWritesWindow)
+	assert.True(t, db.reader(caller) == replicas)
+	db.forgetWrites()
+	assert.Empty(t, db.lastWrites)
+
+	// the primary serves all the reads while the replicas are down
+	db.readHealthy = 0
+	assert.True(t, db.reader(otherCaller) == primary)
+}
+
+func TestCloseTwice(t *testing.T) {
+	primary, err := sql.Open("postgres", "postgres://primary/app")
+	require.NoError(t, err)
+	db := &DatabasePostgreSQL{session: primary, stop: make(chan struct{})}
+
+	require.NoError(t, db.Close())
+	assert.NotPanics(t, func() { _ = db.Close() })
+}
@@ -0,0 +1,111 @@
+package resilience
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/adeo/turbine-go-api-skeleton/storage/dao"
+)
+
+// state is the state of a circuit breaker, its value is exported in the metrics
+type state int
+
+const (
+	stateClosed state = iota
+	stateHalfOpen
+	stateOpen
+)
+
+// halfOpenRetryAfter is the delay given to the calls rejected while a probe call is running
+const halfOpenRetryAfter = 1 * time.Second
+
+// ErrCircuitOpen is the cause of the errors returned while the circuit breaker is open
+var ErrCircuitOpen = errors.New("circuit breaker is open, the database is considered as unavailable")
+
+// circuitBreaker stops calling the database after threshold consecutive failures. Once openDuration
+// has elapsed, one probe call is allowed: its success closes the circuit, its failure opens it again.
+type circuitBreaker struct {
+	threshold     int
+	openDuration  time.Duration
+	onStateChange func(state)
+
+	mu       sync.Mutex
+	state    state
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+func newCircuitBreaker(threshold int, openDuration time.Duration, onStateChange func(state)) *circuitBreaker {
+	b := &circuitBreaker{
+		threshold:     threshold,
+		openDuration:  openDuration,
+		onStateChange: onStateChange,
+	}
+	onStateChange(stateClosed)
+	return b
+}
+
+// allow returns an ErrTypeUnavailable error when the call must not be made
+func (b *circuitBreaker) allow() error {
+	if b.threshold <= 0 {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case stateOpen:
+		if elapsed := time.Since(b.openedAt); elapsed < b.openDuration {
+			return newUnavailableError(b.openDuration - elapsed)
+		}
+		b.setState(stateHalfOpen)
+		b.probing = true
+	case stateHalfOpen:
+		if b.probing {
+			return newUnavailableError(halfOpenRetryAfter)
+		}
+		b.probing = true
+	}
+	return nil
+}
+
+// record records the result of an allowed call
+func (b *circuitBreaker) record(failure bool) {
+	if b.threshold <= 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+	if !failure {
+		b.failures = 0
+		b.setState(stateClosed)
+		return
+	}
+
+	b.failures++
+	if b.state == stateHalfOpen || b.failures >= b.threshold {
+		b.openedAt = time.Now()
+		b.setState(stateOpen)
+	}
+}
+
+func (b *circuitBreaker) setState(s state) {
+	if b.state != s {
+		b.state = s
+		b.onStateChange(s)
+	}
+}
+
+func newUnavailableError(retryAfter time.Duration) error {
+	return &dao.DAOError{
+		Type:       dao.ErrTypeUnavailable,
+		Cause:      ErrCircuitOpen,
+		RetryAfter: retryAfter,
+	}
+}
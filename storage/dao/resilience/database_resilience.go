@@ -0,0 +1,161 @@
+// Package resilience provides a dao.Database decorator retrying the failed database calls
+// and failing fast with a circuit breaker when the database is down.
+package resilience
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/adeo/turbine-go-api-skeleton/storage/dao"
+)
+
+// policy tells when a database call can be retried
+type policy int
+
+const (
+	// policyRead is for the calls without side effects, always retried
+	policyRead policy = iota
+	// policyIdempotentWrite is for the writes giving the same result when applied twice, always retried
+	policyIdempotentWrite
+	// policyWrite is for the other writes, only retried when the error guarantees they have not been applied
+	policyWrite
+)
+
+type Config struct {
+	// MaxAttempts is the maximum number of calls made for each dao method call, 1 disables retries
+	MaxAttempts int
+	// InitialBackoff is the maximum delay before the first retry, doubled for each retry
+	InitialBackoff time.Duration
+	// MaxBackoff caps the maximum delay between two retries, 0 for no cap
+	MaxBackoff time.Duration
+	// FailureThreshold is the number of consecutive failures opening the circuit breaker, 0 disables it
+	FailureThreshold int
+	// OpenDuration is the time during which the calls fail fast once the circuit breaker is open
+	OpenDuration time.Duration
+}
+
+type DatabaseResilience struct {
+	db      dao.Database
+	config  Config
+	backend string
+	breaker *circuitBreaker
+
+	mu     sync.Mutex
+	random *rand.Rand
+}
+
+// NewDatabaseResilience returns a decorator of the given database, applying the retry policies
+// and the circuit breaker described by config. Its metrics are labeled with the given backend name.
+func NewDatabaseResilience(db dao.Database, config Config, service, backend string) dao.Database {
+	register(service)
+	if config.MaxAttempts < 1 {
+		config.MaxAttempts = 1
+	}
+	return &DatabaseResilience{
+		db:      db,
+		config:  config,
+		backend: backend,
+		breaker: newCircuitBreaker(config.FailureThreshold, config.OpenDuration, func(s state) {
+			breakerState.WithLabelValues(backend).Set(float64(s))
+		}),
+		random: rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// call calls fn through the circuit breaker, retrying it according to the policy until the context is done
+func (db *DatabaseResilience) call(ctx context.Context, method string, p policy, fn func() error) error {
+	var err error
+	for attempt := 0; attempt < db.config.MaxAttempts; attempt++ {
+		if attempt > 0 {
+			retriesTotal.WithLabelValues(db.backend, method).Inc()
+			if errCtx := sleep(ctx, db.backoff(attempt)); errCtx != nil {
+				return dao.Classify(errCtx)
+			}
+		}
+
+		if errBreaker := db.breaker.allow(); errBreaker != nil {
+			return errBreaker
+		}
+		err = fn()
+		db.breaker.record(isTransient(err))
+
+		if !shouldRetry(p, err) {
+			return err
+		}
+	}
+	return err
+}
+
+// backoff returns a random delay before the given retry ("full jitter" exponential backoff),
+// so that the instances of the application do not retry all together
+func (db *DatabaseResilience) backoff(attempt int) time.Duration {
+	if db.config.InitialBackoff <= 0 {
+		return 0
+	}
+	max := db.config.InitialBackoff << uint(attempt-1)
+	if max>>uint(attempt-1) != db.config.InitialBackoff || max <= 0 {
+		// overflow
+		max = math.MaxInt64
+	}
+	if db.config.MaxBackoff > 0 && max > db.config.MaxBackoff {
+		max = db.config.MaxBackoff
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	return time.Duration(db.random.Int63n(int64(max)))
+}
+
+// sleep waits for the given delay, returning the error of the context if it is done before
+func sleep(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// isTransient tells if the error is due to the state of the database rather than to the request: the errors
+// classified as unavailable or timeout, and the network errors. The other dao errors (not found, duplicate,
+// canceled...) are functional results, and the unclassified errors are bugs or invalid requests.
+func isTransient(err error) bool {
+	e, ok := dao.AsDAOError(dao.Classify(err))
+	return ok && (e.Type == dao.ErrTypeUnavailable || e.Type == dao.ErrTypeTimeout)
+}
+
+// isNotApplied tells if the error guarantees that the request has not been sent to the database
+func isNotApplied(err error) bool {
+	var e *net.OpError
+	return errors.As(err, &e) && e.Op == "dial"
+}
+
+func shouldRetry(p policy, err error) bool {
+	switch p {
+	case policyRead, policyIdempotentWrite:
+		return isTransient(err)
+	default:
+		return isNotApplied(err)
+	}
+}
+
+// ignoreNotFoundOnRetry wraps a delete: a not found error after a failed attempt means that the failed
+// attempt has deleted the entity, but that its result has been lost
+func ignoreNotFoundOnRetry(fn func() error) func() error {
+	failed := false
+	return func() error {
+		err := fn()
+		if errors.Is(err, dao.ErrNotFound) && failed {
+			return nil
+		}
+		failed = err != nil
+		return err
+	}
+}
@@ -0,0 +1,58 @@
+package resilience
+
+import (
+	"context"
+
+	"github.com/adeo/turbine-go-api-skeleton/storage/dao"
+)
+
+func (db *DatabaseResilience) GetAllEntities(ctx context.Context, entity string) ([]interface{}, error) {
+	var values []interface{}
+	err := db.call(ctx, dao.EntityMethod("GetAllEntities", entity), policyRead, func() (err error) {
+		values, err = db.db.GetAllEntities(ctx, entity)
+		return err
+	})
+	return values, err
+}
+
+func (db *DatabaseResilience) GetEntityByID(ctx context.Context, entity, id string) (interface{}, error) {
+	var v interface{}
+	err := db.call(ctx, dao.EntityMethod("GetEntityByID", entity), policyRead, func() (err error) {
+		v, err = db.db.GetEntityByID(ctx, entity, id)
+		return err
+	})
+	return v, err
+}
+
+func (db *DatabaseResilience) CreateEntity(ctx context.Context, entity string, v interface{}) error {
+	return db.call(ctx, dao.EntityMethod("CreateEntity", entity), policyWrite, func() error {
+		return db.db.CreateEntity(ctx, entity, v)
+	})
+}
+
+func (db *DatabaseResilience) DeleteEntity(ctx context.Context, entity, id string) error {
+	return db.call(ctx, dao.EntityMethod("DeleteEntity", entity), policyIdempotentWrite, ignoreNotFoundOnRetry(func() error {
+		return db.db.DeleteEntity(ctx, entity, id)
+	}))
+}
+
+func (db *DatabaseResilience) UpdateEntity(ctx context.Context, entity string, v interface{}) error {
+	return db.call(ctx, dao.EntityMethod("UpdateEntity", entity), policyIdempotentWrite, func() error {
+		return db.db.UpdateEntity(ctx, entity, v)
+	})
+}
+
+func (db *DatabaseResilience) GetEntitiesAfter(ctx context.Context, entity, afterID string, limit int) ([]interface{}, error) {
+	var values []interface{}
+	err := db.call(ctx, dao.EntityMethod("GetEntitiesAfter", entity), policyRead, func() (err error) {
+		values, err = db.db.GetEntitiesAfter(ctx, entity, afterID, limit)
+		return err
+	})
+	return values, err
+}
+
+func (db *DatabaseResilience) RestoreEntity(ctx context.Context, entity string, v interface{}) error {
+	return db.call(ctx, dao.EntityMethod("RestoreEntity", entity), policyWrite, func() error {
+		return db.db.RestoreEntity(ctx, entity, v)
+	})
+}
@@ -0,0 +1,183 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/adeo/turbine-go-api-skeleton/storage/dao"
+	"github.com/adeo/turbine-go-api-skeleton/storage/dao/daotest"
+	"github.com/adeo/turbine-go-api-skeleton/storage/dao/fake"
+	"github.com/adeo/turbine-go-api-skeleton/storage/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// flakyDatabase fails the first calls of GetAllEntities and CreateEntity with err
+type flakyDatabase struct {
+	dao.Database
+	failures int
+	err      error
+	calls    int
+}
+
+func (db *flakyDatabase) GetAllEntities(ctx context.Context, entity string) ([]interface{}, error) {
+	db.calls++
+	if db.calls <= db.failures {
+		return nil, db.err
+	}
+	return db.Database.GetAllEntities(ctx, entity)
+}
+
+func (db *flakyDatabase) CreateEntity(ctx context.Context, entity string, v interface{}) error {
+	db.calls++
+	if db.calls <= db.failures {
+		return db.err
+	}
+	return db.Database.CreateEntity(ctx, entity, v)
+}
+
+func newFlakyDatabase(failures int, err error) *flakyDatabase {
+	return &flakyDatabase{
+		Database: fake.NewDatabaseFake("", "", 0),
+		failures: failures,
+		err:      err,
+	}
+}
+
+var testConfig = Config{
+	MaxAttempts:    3,
+	InitialBackoff: time.Millisecond,
+	MaxBackoff:     5 * time.Millisecond,
+}
+
+// errConnectionReset is a network error, the call may succeed once retried
+var errConnectionReset = &net.OpError{Op: "read", Net: "tcp", Err: errors.New("connection reset by peer")}
+
+func TestDatabaseResilienceConformance(t *testing.T) {
+	daotest.Run(t, func(t *testing.T) dao.Database {
+		return NewDatabaseResilience(fake.NewDatabaseFake("", "", 0), testConfig, "test", "conformance")
+	})
+}
+
+func TestDatabaseResilienceRetryRead(t *testing.T) {
+	flaky := newFlakyDatabase(2, errConnectionReset)
+	db := NewDatabaseResilience(flaky, testConfig, "test", "retry-read")
+
+	_, err := db.GetAllEntities(context.Background(), "template")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, flaky.calls)
+}
+
+func TestDatabaseResilienceNoRetryOnDAOError(t *testing.T) {
+	flaky := newFlakyDatabase(1, dao.NewDAOError(dao.ErrTypeNotFound, errors.New("not found")))
+	db := NewDatabaseResilience(flaky, testConfig, "test", "dao-error")
+
+	_, err := db.GetAllEntities(context.Background(), "template")
+	assert.Error(t, err)
+	assert.Equal(t, 1, flaky.calls)
+}
+
+func TestDatabaseResilienceNoRetryOnUnclassifiedError(t *testing.T) {
+	flaky := newFlakyDatabase(2, errors.New("syntax error"))
+	db := NewDatabaseResilience(flaky, Config{
+		MaxAttempts:      3,
+		FailureThreshold: 1,
+		OpenDuration:     time.Minute,
+	}, "test", "unclassified")
+
+	// the error is not retried, and does not open the circuit
+	_, err := db.GetAllEntities(context.Background(), "template")
+	assert.Error(t, err)
+	_, err = db.GetAllEntities(context.Background(), "template")
+	assert.Error(t, err)
+	assert.Equal(t, 2, flaky.calls)
+	_, err = db.GetAllEntities(context.Background(), "template")
+	assert.NoError(t, err)
+}
+
+func TestDatabaseResilienceRetryWrite(t *testing.T) {
+	flaky := newFlakyDatabase(1, errConnectionReset)
+	db := NewDatabaseResilience(flaky, testConfig, "test", "retry-write")
+
+	// the write may have been applied, it is not retried
+	err := db.CreateEntity(context.Background(), "template", &model.Template{TemplateEditable: model.TemplateEditable{Name: "template-1"}})
+	assert.Error(t, err)
+	assert.Equal(t, 1, flaky.calls)
+
+	// the write has not been sent, it is retried
+	flaky = newFlakyDatabase(1, &net.OpError{Op: "dial", Err: errors.New("connection refused")})
+	db = NewDatabaseResilience(flaky, testConfig, "test", "retry-write")
+
+	err = db.CreateEntity(context.Background(), "template", &model.Template{TemplateEditable: model.TemplateEditable{Name: "template-1"}})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, flaky.calls)
+}
+
+func TestDatabaseResilienceCircuitBreaker(t *testing.T) {
+	flaky := newFlakyDatabase(2, errConnectionReset)
+	db := NewDatabaseResilience(flaky, Config{
+		MaxAttempts:      1,
+		FailureThreshold: 2,
+		OpenDuration:     50 * time.Millisecond,
+	}, "test", "breaker")
+
+	_, err := db.GetAllEntities(context.Background(), "template")
+	assert.Error(t, err)
+	_, err = db.GetAllEntities(context.Background(), "template")
+	assert.Error(t, err)
+
+	// the circuit is open, the database is not called
+	_, err = db.GetAllEntities(context.Background(), "template")
+	require.IsType(t, &dao.DAOError{}, err)
+	assert.Equal(t, dao.ErrTypeUnavailable, err.(*dao.DAOError).Type)
+	assert.True(t, err.(*dao.DAOError).RetryAfter > 0)
+	assert.Equal(t, 2, flaky.calls)
+
+	// the probe call succeeds and closes the circuit
+	time.Sleep(60 * time.Millisecond)
+	_, err = db.GetAllEntities(context.Background(), "template")
+	assert.NoError(t, err)
+	_, err = db.GetAllEntities(context.Background(), "template")
+	assert.NoError(t, err)
+	assert.Equal(t, 4, flaky.calls)
+}
+
+func TestDatabaseResilienceRetryCanceled(t *testing.T) {
+	flaky := newFlakyDatabase(2, errConnectionReset)
+	db := NewDatabaseResilience(flaky, Config{
+		MaxAttempts:    3,
+		InitialBackoff: time.Hour,
+	}, "test", "retry-canceled")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+
+	// the backoff is interrupted by the cancellation of the caller
+	start := time.Now()
+	_, err := db.GetAllEntities(ctx, "template")
+	assert.True(t, time.Since(start) < time.Second)
+	assert.True(t, errors.Is(err, dao.ErrCanceled))
+	assert.Equal(t, 1, flaky.calls)
+}
+
+func TestDatabaseResilienceBackoff(t *testing.T) {
+	db := NewDatabaseResilience(fake.NewDatabaseFake("", "", 0), Config{InitialBackoff: 10 * time.Millisecond}, "test", "backoff").(*DatabaseResilience)
+
+	// without MaxBackoff, the delays are not capped
+	var max time.Duration
+	for i := 0; i < 100; i++ {
+		delay := db.backoff(3)
+		assert.True(t, delay < 40*time.Millisecond)
+		if delay > max {
+			max = delay
+		}
+	}
+	assert.True(t, max > 10*time.Millisecond)
+	assert.True(t, db.backoff(100) > 0, "the overflows are not capped either")
+
+	db.config.MaxBackoff = 5 * time.Millisecond
+	assert.True(t, db.backoff(3) < 5*time.Millisecond)
+}
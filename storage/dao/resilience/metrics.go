@@ -0,0 +1,44 @@
+package resilience
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	breakerStateName = "dao_circuit_breaker_state"
+	retriesName      = "dao_retries_total"
+)
+
+var (
+	registerOnce sync.Once
+	breakerState *prometheus.GaugeVec
+	retriesTotal *prometheus.CounterVec
+)
+
+// register creates and registers the collectors, shared by all the resilient databases
+func register(service string) {
+	registerOnce.Do(func() {
+		labels := prometheus.Labels{"service": service}
+
+		breakerState = prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name:        breakerStateName,
+				Help:        "State of the database circuit breaker (0: closed, 1: half-open, 2: open), partitioned by backend.",
+				ConstLabels: labels,
+			},
+			[]string{"backend"},
+		)
+		retriesTotal = prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name:        retriesName,
+				Help:        "How many database calls have been retried, partitioned by backend and method.",
+				ConstLabels: labels,
+			},
+			[]string{"backend", "method"},
+		)
+
+		prometheus.MustRegister(breakerState, retriesTotal)
+	})
+}
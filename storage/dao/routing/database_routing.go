@@ -0,0 +1,104 @@
+// Package routing provides a dao.Database routing the calls of each entity to its own datasource,
+// to store some entities in a database and the others in another one.
+package routing
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/adeo/turbine-go-api-skeleton/storage/dao"
+)
+
+// DefaultDatasource is the name of the datasource of the entities without route
+const DefaultDatasource = "default"
+
+// routableEntities returns the names of the routable entities, as given in the routes: the registered ones
+func routableEntities() []string {
+	var names []string
+	for _, entity := range dao.Entities() {
+		names = append(names, entity.Name)
+	}
+	return names
+}
+
+// DatabaseRouting routes the calls of each entity to the datasource configured for it, or to the default datasource
+type DatabaseRouting struct {
+	datasources map[string]dao.Database
+	routes      map[string]dao.Database // datasource of each entity
+}
+
+// NewDatabaseRouting returns a database routing the calls to the given datasources, by name. The routes give
+// the name of the datasource of each entity, the entities without route use DefaultDatasource.
+func NewDatabaseRouting(datasources map[string]dao.Database, routes map[string]string) (*DatabaseRouting, error) {
+	if _, ok := datasources[DefaultDatasource]; !ok {
+		return nil, fmt.Errorf("routing: no %s datasource", DefaultDatasource)
+	}
+
+	result := &DatabaseRouting{
+		datasources: datasources,
+		routes:      make(map[string]dao.Database),
+	}
+	known := routableEntities()
+	for _, entity := range known {
+		result.routes[entity] = datasources[DefaultDatasource]
+	}
+	for entity, name := range routes {
+		if _, ok := result.routes[entity]; !ok {
+			return nil, fmt.Errorf("routing: unknown entity %q, known entities: %v", entity, known)
+		}
+		datasource, ok := datasources[name]
+		if !ok {
+			return nil, fmt.Errorf("routing: unknown datasource %q for the entity %s", name, entity)
+		}
+		result.routes[entity] = datasource
+	}
+	return result, nil
+}
+
+// route returns the datasource of the given entity, the default one for the unknown entities which fail there
+func (db *DatabaseRouting) route(entity string) dao.Database {
+	if datasource, ok := db.routes[entity]; ok {
+		return datasource
+	}
+	return db.datasources[DefaultDatasource]
+}
+
+// names returns the sorted names of the datasources
+func (db *DatabaseRouting) names() []string {
+	names := make([]string, 0, len(db.datasources))
+	for name := range db.datasources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// PoolStats returns the statistics of the connection pools of all the datasources, prefixed by their name
+func (db *DatabaseRouting) PoolStats() []dao.PoolStats {
+	stats := make([]dao.PoolStats, 0)
+	for _, name := range db.names() {
+		provider, ok := db.datasources[name].(dao.PoolStatsProvider)
+		if !ok {
+			continue
+		}
+		for _, s := range provider.PoolStats() {
+			s.Name = name + "/" + s.Name
+			stats = append(stats, s)
+		}
+	}
+	return stats
+}
+
+// Close closes all the datasources, and returns the first error
+func (db *DatabaseRouting) Close() error {
+	var result error
+	for _, name := range db.names() {
+		if closer, ok := db.datasources[name].(io.Closer); ok {
+			if err := closer.Close(); err != nil && result == nil {
+				result = fmt.Errorf("datasource %s: %v", name, err)
+			}
+		}
+	}
+	return result
+}
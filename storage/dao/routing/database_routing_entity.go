@@ -0,0 +1,35 @@
+package routing
+
+import (
+	"context"
+)
+
+// the registered entities are routed by their name
+
+func (db *DatabaseRouting) GetAllEntities(ctx context.Context, entity string) ([]interface{}, error) {
+	return db.route(entity).GetAllEntities(ctx, entity)
+}
+
+func (db *DatabaseRouting) GetEntityByID(ctx context.Context, entity, id string) (interface{}, error) {
+	return db.route(entity).GetEntityByID(ctx, entity, id)
+}
+
+func (db *DatabaseRouting) GetEntitiesAfter(ctx context.Context, entity, afterID string, limit int) ([]interface{}, error) {
+	return db.route(entity).GetEntitiesAfter(ctx, entity, afterID, limit)
+}
+
+func (db *DatabaseRouting) CreateEntity(ctx context.Context, entity string, v interface{}) error {
+	return db.route(entity).CreateEntity(ctx, entity, v)
+}
+
+func (db *DatabaseRouting) DeleteEntity(ctx context.Context, entity, id string) error {
+	return db.route(entity).DeleteEntity(ctx, entity, id)
+}
+
+func (db *DatabaseRouting) UpdateEntity(ctx context.Context, entity string, v interface{}) error {
+	return db.route(entity).UpdateEntity(ctx, entity, v)
+}
+
+func (db *DatabaseRouting) RestoreEntity(ctx context.Context, entity string, v interface{}) error {
+	return db.route(entity).RestoreEntity(ctx, entity, v)
+}
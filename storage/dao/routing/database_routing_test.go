@@ -0,0 +1,50 @@
+package routing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/adeo/turbine-go-api-skeleton/storage/dao"
+	"github.com/adeo/turbine-go-api-skeleton/storage/dao/daotest"
+	"github.com/adeo/turbine-go-api-skeleton/storage/dao/fake"
+	"github.com/adeo/turbine-go-api-skeleton/storage/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDatabaseRoutingConformance(t *testing.T) {
+	daotest.Run(t, func(t *testing.T) dao.Database {
+		db, err := NewDatabaseRouting(map[string]dao.Database{
+			DefaultDatasource: fake.NewDatabaseFake("", "", 0),
+			"other":           fake.NewDatabaseFake("", "", 0),
+		}, map[string]string{"template": "other"})
+		require.NoError(t, err)
+		return db
+	})
+}
+
+func TestDatabaseRoutingRoute(t *testing.T) {
+	ctx := context.Background()
+	defaultDatasource, other := fake.NewDatabaseFake("", "", 0), fake.NewDatabaseFake("", "", 0)
+	db, err := NewDatabaseRouting(map[string]dao.Database{DefaultDatasource: defaultDatasource, "other": other}, map[string]string{"template": "other"})
+	require.NoError(t, err)
+
+	template := &model.Template{TemplateEditable: model.TemplateEditable{Name: "template-1"}}
+	require.NoError(t, db.CreateEntity(ctx, "template", template))
+
+	_, err = other.GetEntityByID(ctx, "template", template.ID)
+	assert.NoError(t, err)
+	_, err = defaultDatasource.GetEntityByID(ctx, "template", template.ID)
+	assert.Error(t, err)
+}
+
+func TestNewDatabaseRoutingErrors(t *testing.T) {
+	datasources := map[string]dao.Database{DefaultDatasource: fake.NewDatabaseFake("", "", 0)}
+
+	_, err := NewDatabaseRouting(map[string]dao.Database{"other": fake.NewDatabaseFake("", "", 0)}, nil)
+	assert.Error(t, err, "the default datasource is required")
+	_, err = NewDatabaseRouting(datasources, map[string]string{"unknown": DefaultDatasource})
+	assert.Error(t, err, "the entity must exist")
+	_, err = NewDatabaseRouting(datasources, map[string]string{"template": "unknown"})
+	assert.Error(t, err, "the datasource must exist")
+}
@@ -0,0 +1,42 @@
+package dao
+
+import (
+	"context"
+	"regexp"
+)
+
+const (
+	// ContextKeyTenant is the key of the tenant of the request in the context given to the database
+	ContextKeyTenant = "ContextKeyTenant"
+)
+
+// Tenancy is the way the data of the tenants are separated in the database
+type Tenancy string
+
+const (
+	// TenancyShared stores all the tenants in the same database, the data are scoped by their tenant_id
+	TenancyShared Tenancy = "shared"
+	// TenancyIsolated stores each tenant in its own mongodb database or postgresql schema, the data are also scoped by their tenant_id
+	TenancyIsolated Tenancy = "isolated"
+)
+
+// tenantPattern restricts the tenants to names usable in database and schema names
+var tenantPattern = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,32}$`)
+
+// IsValidTenant returns whether the given tenant can be used to scope the data
+func IsValidTenant(tenant string) bool {
+	return tenantPattern.MatchString(tenant)
+}
+
+// WithTenant returns a copy of the given context scoping the database calls to the given tenant
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, ContextKeyTenant, tenant)
+}
+
+// TenantFromContext returns the tenant of the database call, empty when the tenancy is disabled
+func TenantFromContext(ctx context.Context) string {
+	if tenant, ok := ctx.Value(ContextKeyTenant).(string); ok {
+		return tenant
+	}
+	return ""
+}
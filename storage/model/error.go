@@ -3,6 +3,8 @@ package model
 import (
 	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 )
 
 var (
@@ -10,13 +12,18 @@ var (
 	ErrBadRequestFormat = APIError{
 		Type:        "bad_format",
 		HTTPCode:    http.StatusBadRequest,
-		Description: "unable to read request body, please check that the json is valid",
+		Description: "unable to read request body, please check that it is valid for its Content-Type",
 	}
 	ErrDataValidation = APIError{
 		Type:        "data_validation",
 		HTTPCode:    http.StatusBadRequest,
 		Description: "the data are not valid",
 	}
+	ErrTenantInvalid = APIError{
+		Type:        "tenant_invalid",
+		HTTPCode:    http.StatusBadRequest,
+		Description: "the tenant must only contain letters, digits, '_' and '-', and be at most 32 characters long",
+	}
 
 	// 401
 	ErrInvalidCredentials = APIError{
@@ -25,6 +32,18 @@ var (
 		Description: "Invalid credentials",
 	}
 
+	// 403
+	ErrTenantRequired = APIError{
+		Type:        "tenant_required",
+		HTTPCode:    http.StatusForbidden,
+		Description: "no tenant could be resolved for the request",
+	}
+	ErrTenantMismatch = APIError{
+		Type:        "tenant_mismatch",
+		HTTPCode:    http.StatusForbidden,
+		Description: "the requested tenant is not the tenant of the authenticated identity",
+	}
+
 	// 404
 	ErrNotFound = APIError{
 		Type:     "not_found",
@@ -32,6 +51,11 @@ var (
 	}
 
 	// 40x
+	ErrNotAcceptable = APIError{
+		Type:        "not_acceptable",
+		HTTPCode:    http.StatusNotAcceptable,
+		Description: "the resource can not be represented in the formats of the Accept header, please use application/json",
+	}
 	ErrAlreadyExists = APIError{
 		Type:     "already_exists",
 		HTTPCode: http.StatusConflict,
@@ -41,12 +65,48 @@ var (
 		HTTPCode:    http.StatusPreconditionFailed,
 		Description: "Model version mismatched",
 	}
+	ErrConflict = APIError{
+		Type:        "conflict",
+		HTTPCode:    http.StatusConflict,
+		Description: "The data have been modified concurrently, please retry",
+	}
+	ErrReferenceConflict = APIError{
+		Type:        "reference_conflict",
+		HTTPCode:    http.StatusConflict,
+		Description: "the entity references an entity which does not exist, or is still referenced by other entities",
+	}
+	ErrRequestEntityTooLarge = APIError{
+		Type:        "request_entity_too_large",
+		HTTPCode:    http.StatusRequestEntityTooLarge,
+		Description: "the request body is too large",
+	}
+	ErrUnsupportedMediaType = APIError{
+		Type:        "unsupported_media_type",
+		HTTPCode:    http.StatusUnsupportedMediaType,
+		Description: "the Content-Type of the request body is not supported, please use application/json",
+	}
+	// ErrRequestCanceled uses the non standard 499 code, the client having closed the connection
+	ErrRequestCanceled = APIError{
+		Type:        "request_canceled",
+		HTTPCode:    499,
+		Description: "The request has been canceled by the client",
+	}
 
 	// 50x
 	ErrInternalServer = APIError{
 		Type:     "internal_server_error",
 		HTTPCode: http.StatusInternalServerError,
 	}
+	ErrServiceUnavailable = APIError{
+		Type:        "service_unavailable",
+		HTTPCode:    http.StatusServiceUnavailable,
+		Description: "The service is temporarily unavailable, please retry later",
+	}
+	ErrTimeout = APIError{
+		Type:        "timeout",
+		HTTPCode:    http.StatusGatewayTimeout,
+		Description: "The database did not respond in time, please retry later",
+	}
 )
 
 // @openapi:schema
@@ -58,13 +118,106 @@ type APIError struct {
 	Headers     map[string][]string `json:"-"`
 }
 
+// Problem is the RFC 7807 representation of an APIError, sent as application/problem+json
+// @openapi:schema
+type Problem struct {
+	Type     string       `json:"type"`
+	Title    string       `json:"title"`
+	Status   int          `json:"status"`
+	Detail   string       `json:"detail,omitempty"`
+	Instance string       `json:"instance,omitempty"`
+	Errors   []FieldError `json:"errors,omitempty"`
+}
+
+// JSONAPIErrors is the JSON:API document of an APIError, sent as application/vnd.api+json
+// @openapi:schema
+type JSONAPIErrors struct {
+	Errors []JSONAPIError `json:"errors"`
+}
+
+// JSONAPIError is a JSON:API error object, the meta of the errors of a field being its FieldError
+// @openapi:schema
+type JSONAPIError struct {
+	Status string              `json:"status"`
+	Code   string              `json:"code"`
+	Title  string              `json:"title"`
+	Detail string              `json:"detail,omitempty"`
+	Source *JSONAPIErrorSource `json:"source,omitempty"`
+	Meta   *FieldError         `json:"meta,omitempty"`
+}
+
+// @openapi:schema
+type JSONAPIErrorSource struct {
+	Pointer string `json:"pointer"` // JSON pointer of the value in error in the request body, eg. /tags/1
+}
+
 // @openapi:schema
 type FieldError struct {
 	Field       string `json:"field"`
 	Constraint  string `json:"constraint"`
 	Description string `json:"description"`
+	Expected    string `json:"expected,omitempty"` // expected type of the value, for the errors of the body format
+	Offset      *int64 `json:"offset,omitempty"`   // offset in bytes of the error in the JSON body
+	// Params are the parameters of the constraint, formatting its translated descriptions
+	Params []string `json:"-"`
 }
 
 func (e *APIError) Error() string {
 	return fmt.Sprintf("error : %d, %s, %s, %v", e.HTTPCode, e.Type, e.Description, e.Details)
 }
+
+// Problem returns the RFC 7807 representation of the error: its type is the URI of the error type under
+// typeBaseURI, and instance identifies the occurrence of the error
+func (e APIError) Problem(typeBaseURI, instance string) Problem {
+	return Problem{
+		Type:     typeBaseURI + e.Type,
+		Title:    e.title(),
+		Status:   e.HTTPCode,
+		Detail:   e.Description,
+		Instance: instance,
+		Errors:   e.Details,
+	}
+}
+
+// JSONAPIErrors returns the JSON:API representation of the error: an error object per field error, or a single
+// one for the errors without details
+func (e APIError) JSONAPIErrors() JSONAPIErrors {
+	base := JSONAPIError{
+		Status: strconv.Itoa(e.HTTPCode),
+		Code:   e.Type,
+		Title:  e.title(),
+		Detail: e.Description,
+	}
+	if len(e.Details) == 0 {
+		return JSONAPIErrors{Errors: []JSONAPIError{base}}
+	}
+
+	errors := make([]JSONAPIError, 0, len(e.Details))
+	for _, detail := range e.Details {
+		detail := detail
+		jsonAPIError := base
+		jsonAPIError.Detail = detail.Description
+		jsonAPIError.Source = &JSONAPIErrorSource{Pointer: jsonPointer(detail.Field)}
+		jsonAPIError.Meta = &detail
+		errors = append(errors, jsonAPIError)
+	}
+	return JSONAPIErrors{Errors: errors}
+}
+
+// title is the short summary of the error, the text of its status
+func (e APIError) title() string {
+	if title := http.StatusText(e.HTTPCode); title != "" {
+		return title
+	}
+	return strings.Replace(e.Type, "_", " ", -1)
+}
+
+// jsonPointer converts the path of a field error, eg. $.tags[1] or nested.label, to a JSON pointer
+func jsonPointer(field string) string {
+	field = strings.TrimPrefix(strings.TrimPrefix(field, "$"), ".")
+	if field == "" {
+		return ""
+	}
+	replacer := strings.NewReplacer("~", "~0", "/", "~1", ".", "/", "[", "/", "]", "")
+	return "/" + replacer.Replace(field)
+}
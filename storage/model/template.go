@@ -6,12 +6,16 @@ import "time"
 type Template struct {
 	TemplateEditable `bson:",inline"` // avoid having a property "TemplateEditable" in your mongodb document
 	ID               string           `json:"id" bson:"_id"`
+	TenantID         string           `json:"tenant_id,omitempty" bson:"tenant_id"` // set by the database from the tenant of the request
 	CreatedAt        time.Time        `json:"created_at" bson:"created_at"`
 	UpdatedAt        *time.Time       `json:"updated_at" bson:"updated_at"`
 }
 
 // @openapi:schema
 type TemplateEditable struct {
-	// Add here your model properties, and don't forget to modify SQL request in corresponding DAO file if any
-	Name string `json:"name" bson:"name" validate:"required"`
+	// Add here your model properties, and their columns to the PostgreSQL table if any: a column per property,
+	// named by its db tag or its JSON name. Tag with encrypt:"true" the string properties to encrypt at rest.
+	Name string `json:"name" bson:"name" db:"code" validate:"required"`
+	// ExpiresAt is the date after which the template is removed, never when nil
+	ExpiresAt *time.Time `json:"expires_at" bson:"expires_at"`
 }
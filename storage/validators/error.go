@@ -1,12 +1,11 @@
 package validators
 
 import (
-	"fmt"
 	"regexp"
-	"strings"
 
 	"github.com/adeo/turbine-go-api-skeleton/storage/model"
 	"github.com/adeo/turbine-go-api-skeleton/utils"
+	"github.com/adeo/turbine-go-api-skeleton/utils/i18n"
 	"gopkg.in/go-playground/validator.v9"
 )
 
@@ -21,7 +20,7 @@ func NewDataValidationAPIError(err error) model.APIError {
 			for _, e := range err.(validator.ValidationErrors) {
 				reason := e.Tag()
 				if _, ok := CustomValidators[e.Tag()]; ok {
-					reason = truncatingSprintf(CustomValidators[e.Tag()].Message, e.Param())
+					reason = i18n.Sprintf(CustomValidators[e.Tag()].Message, e.Param())
 				}
 
 				namespaceWithoutStructName := regexpValidatorNamespacePrefix.ReplaceAllString(e.Namespace(), "$.")
@@ -29,6 +28,7 @@ func NewDataValidationAPIError(err error) model.APIError {
 					Field:       namespaceWithoutStructName,
 					Constraint:  e.Tag(),
 					Description: reason,
+					Params:      []string{e.Param()},
 				}
 				apiErr.Details = append(apiErr.Details, fe)
 			}
@@ -36,9 +36,3 @@ func NewDataValidationAPIError(err error) model.APIError {
 	}
 	return apiErr
 }
-
-// truncatingSprintf is used as fmt.Sprintf but allow to truncate the additional parameters given when there is more parameters than %v in str
-func truncatingSprintf(str string, args ...interface{}) string {
-	n := strings.Count(str, "%v")
-	return fmt.Sprintf(str, args[:n]...)
-}
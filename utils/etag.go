@@ -15,10 +15,13 @@ func GenerateEtag(data interface{}) (string, error) {
 	if err != nil {
 		return "", err
 	}
+	return GenerateEtagFromBytes(b), nil
+}
 
+// GenerateEtagFromBytes returns the ETag of a representation, GenerateEtag being the one of the JSON representation
+func GenerateEtagFromBytes(b []byte) string {
 	str := string(b)
-	tag := fmt.Sprintf("\"%d-%s\"", len(str), getHash(str))
-	return tag, nil
+	return fmt.Sprintf("\"%d-%s\"", len(str), getHash(str))
 }
 
 func IsSameVersion(expectedEtag string, resource interface{}) bool {
@@ -0,0 +1,436 @@
+package httputils
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/adeo/turbine-go-api-skeleton/storage/model"
+)
+
+// The reasons of the BodyErrors
+const (
+	BodyErrorSyntax       = "syntax"
+	BodyErrorType         = "type"
+	BodyErrorUnknownField = "unknown_field"
+	BodyErrorDuplicateKey = "duplicate_key"
+)
+
+// maxJSONDepth is the maximum nesting of the objects and arrays of a JSON body
+const maxJSONDepth = 1000
+
+// BodyError is a precise error of a request body, it matches ErrInvalidBody
+type BodyError struct {
+	Reason   string // one of the BodyError constants
+	Path     string // JSON path of the value in error, eg. $.tags[1]
+	Expected string // expected type of the value, for the type mismatches
+	Offset   int64  // offset in bytes of the error in the body, -1 when the body is not JSON
+	Message  string
+	params   []string // parameters of the message, formatting its translations
+}
+
+func (e *BodyError) Error() string {
+	return fmt.Sprintf("%s error at %s: %s", e.Reason, e.Path, e.Message)
+}
+
+// Is makes the body errors match ErrInvalidBody
+func (e *BodyError) Is(target error) bool {
+	return target == ErrInvalidBody
+}
+
+// FieldError returns the detail of the error sent to the client
+func (e *BodyError) FieldError() model.FieldError {
+	fieldError := model.FieldError{
+		Field:       e.Path,
+		Constraint:  e.Reason,
+		Description: e.Message,
+		Expected:    e.Expected,
+		Params:      e.params,
+	}
+	if e.Offset >= 0 {
+		offset := e.Offset
+		fieldError.Offset = &offset
+	}
+	return fieldError
+}
+
+// decodeJSON strictly reads a JSON body in v: the syntax errors, duplicate keys, unknown fields and type
+// mismatches are returned as BodyErrors locating the error
+func decodeJSON(data []byte, v interface{}) error {
+	scanner := &jsonScanner{data: data}
+	root, err := scanner.document()
+	if err != nil {
+		return err
+	}
+	if err := checkJSON(root, reflect.TypeOf(v)); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		// the values the scan can not check, eg. an overflowing integer
+		if typeErr, ok := err.(*json.UnmarshalTypeError); ok {
+			node := root.at(typeErr.Offset)
+			return &BodyError{Reason: BodyErrorType, Path: node.path(), Expected: typeErr.Type.String(), Offset: node.offset, Message: err.Error(), params: []string{typeErr.Type.String(), typeErr.Value}}
+		}
+		return err
+	}
+	return nil
+}
+
+// jsonNode is a value of a JSON document, located in the document
+type jsonNode struct {
+	kind       byte      // '{', '[', '"', 't' for the booleans, '0' for the numbers, 'n' for null
+	parent     *jsonNode // nil for the document
+	index      int       // index of the value in the values of its parent
+	offset     int64
+	end        int64
+	keys       []string
+	keyOffsets []int64
+	values     []*jsonNode // values of the keys of an object, or items of an array
+	raw        []byte
+}
+
+// path returns the JSON path of the value, eg. $.tags[1]. It is only built for the errors, the paths of all the
+// values of a deeply nested document would take a quadratic memory.
+func (n *jsonNode) path() string {
+	if n.parent == nil {
+		return "$"
+	}
+	return n.parent.childPath(n.index)
+}
+
+// childPath returns the JSON path of the value of the given index, which may not be scanned yet
+func (n *jsonNode) childPath(index int) string {
+	if n.kind == '{' {
+		return n.path() + "." + n.keys[index]
+	}
+	return n.path() + "[" + strconv.Itoa(index) + "]"
+}
+
+// at returns the deepest value containing the offset
+func (n *jsonNode) at(offset int64) *jsonNode {
+	for _, value := range n.values {
+		if value.offset < offset && offset <= value.end {
+			return value.at(offset)
+		}
+	}
+	return n
+}
+
+// jsonScanner parses a JSON document, to locate the values and find the duplicate keys
+type jsonScanner struct {
+	data  []byte
+	pos   int
+	depth int // nesting of the object or array being scanned
+}
+
+func (s *jsonScanner) document() (*jsonNode, error) {
+	root := &jsonNode{}
+	if err := s.value(root); err != nil {
+		return nil, err
+	}
+	s.skipSpaces()
+	if s.pos < len(s.data) {
+		return nil, s.syntaxError(root, "unexpected data after the document")
+	}
+	return root, nil
+}
+
+func (s *jsonScanner) syntaxError(node *jsonNode, message string) *BodyError {
+	return &BodyError{Reason: BodyErrorSyntax, Path: node.path(), Offset: int64(s.pos), Message: message}
+}
+
+func (s *jsonScanner) skipSpaces() {
+	for s.pos < len(s.data) {
+		switch s.data[s.pos] {
+		case ' ', '\t', '\n', '\r':
+			s.pos++
+		default:
+			return
+		}
+	}
+}
+
+// value scans the value at the current position in the node, whose parent and index are set
+func (s *jsonScanner) value(node *jsonNode) error {
+	s.skipSpaces()
+	if s.pos >= len(s.data) {
+		return s.syntaxError(node, "unexpected end of the body")
+	}
+
+	node.offset = int64(s.pos)
+	var err error
+	switch c := s.data[s.pos]; {
+	case c == '{', c == '[':
+		// the scan is recursive, the depth is limited not to exhaust the stack
+		if s.depth >= maxJSONDepth {
+			return s.syntaxError(node, fmt.Sprintf("the body is nested deeper than %d levels", maxJSONDepth))
+		}
+		s.depth++
+		node.kind = c
+		if c == '{' {
+			err = s.object(node)
+		} else {
+			err = s.array(node)
+		}
+		s.depth--
+	case c == '"':
+		node.kind = '"'
+		_, err = s.string(node)
+	case c == 't':
+		node.kind = 't'
+		err = s.literal(node, "true")
+	case c == 'f':
+		node.kind = 't'
+		err = s.literal(node, "false")
+	case c == 'n':
+		node.kind = 'n'
+		err = s.literal(node, "null")
+	case c == '-' || c >= '0' && c <= '9':
+		node.kind = '0'
+		err = s.number(node)
+	default:
+		err = s.syntaxError(node, fmt.Sprintf("invalid character %q", c))
+	}
+	if err != nil {
+		return err
+	}
+	node.end = int64(s.pos)
+	node.raw = s.data[node.offset:node.end]
+	return nil
+}
+
+func (s *jsonScanner) object(node *jsonNode) error {
+	s.pos++ // {
+	s.skipSpaces()
+	if s.pos < len(s.data) && s.data[s.pos] == '}' {
+		s.pos++
+		return nil
+	}
+
+	seen := make(map[string]bool)
+	for {
+		s.skipSpaces()
+		if s.pos >= len(s.data) || s.data[s.pos] != '"' {
+			return s.syntaxError(node, "expected a key")
+		}
+		keyOffset := int64(s.pos)
+		key, err := s.string(node)
+		if err != nil {
+			return err
+		}
+		// the key is added before its value is scanned, to name the value in the errors
+		node.keys = append(node.keys, key)
+		node.keyOffsets = append(node.keyOffsets, keyOffset)
+		value := &jsonNode{parent: node, index: len(node.values)}
+		if seen[key] {
+			return &BodyError{Reason: BodyErrorDuplicateKey, Path: value.path(), Offset: keyOffset, Message: fmt.Sprintf("the key %q is duplicated", key), params: []string{key}}
+		}
+		seen[key] = true
+
+		s.skipSpaces()
+		if s.pos >= len(s.data) || s.data[s.pos] != ':' {
+			return s.syntaxError(value, "expected ':' after the key")
+		}
+		s.pos++
+		if err := s.value(value); err != nil {
+			return err
+		}
+		node.values = append(node.values, value)
+
+		s.skipSpaces()
+		if s.pos < len(s.data) && s.data[s.pos] == ',' {
+			s.pos++
+			continue
+		}
+		if s.pos < len(s.data) && s.data[s.pos] == '}' {
+			s.pos++
+			return nil
+		}
+		return s.syntaxError(node, "expected ',' or '}' after the value")
+	}
+}
+
+func (s *jsonScanner) array(node *jsonNode) error {
+	s.pos++ // [
+	s.skipSpaces()
+	if s.pos < len(s.data) && s.data[s.pos] == ']' {
+		s.pos++
+		return nil
+	}
+
+	for {
+		value := &jsonNode{parent: node, index: len(node.values)}
+		if err := s.value(value); err != nil {
+			return err
+		}
+		node.values = append(node.values, value)
+
+		s.skipSpaces()
+		if s.pos < len(s.data) && s.data[s.pos] == ',' {
+			s.pos++
+			continue
+		}
+		if s.pos < len(s.data) && s.data[s.pos] == ']' {
+			s.pos++
+			return nil
+		}
+		return s.syntaxError(node, "expected ',' or ']' after the value")
+	}
+}
+
+func (s *jsonScanner) string(node *jsonNode) (string, error) {
+	start := s.pos
+	for s.pos++; s.pos < len(s.data); s.pos++ {
+		switch c := s.data[s.pos]; {
+		case c == '\\':
+			s.pos++
+		case c == '"':
+			s.pos++
+			var value string
+			if err := json.Unmarshal(s.data[start:s.pos], &value); err != nil {
+				s.pos = start
+				return "", s.syntaxError(node, "invalid string")
+			}
+			return value, nil
+		case c < 0x20:
+			return "", s.syntaxError(node, "invalid control character in string")
+		}
+	}
+	return "", s.syntaxError(node, "unexpected end of the body in string")
+}
+
+func (s *jsonScanner) literal(node *jsonNode, literal string) error {
+	if !bytes.HasPrefix(s.data[s.pos:], []byte(literal)) {
+		return s.syntaxError(node, fmt.Sprintf("invalid literal, expected %s", literal))
+	}
+	s.pos += len(literal)
+	return nil
+}
+
+func (s *jsonScanner) number(node *jsonNode) error {
+	start := s.pos
+	for s.pos < len(s.data) && strings.IndexByte("+-.eE0123456789", s.data[s.pos]) >= 0 {
+		s.pos++
+	}
+	if !json.Valid(s.data[start:s.pos]) {
+		s.pos = start
+		return s.syntaxError(node, "invalid number")
+	}
+	return nil
+}
+
+// checkJSON checks that the fields of the JSON value are the ones of the type t, and that their values have the
+// JSON types of the fields
+func checkJSON(n *jsonNode, t reflect.Type) *BodyError {
+	if n.kind == 'n' {
+		return nil
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if reflect.PtrTo(t).Implements(textUnmarshalerType) {
+		return expectKind(n, '"', "string") // eg. time.Time
+	}
+	if reflect.PtrTo(t).Implements(jsonUnmarshalerType) {
+		return nil
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		if err := expectKind(n, '{', "object"); err != nil {
+			return err
+		}
+		fields := jsonFields(t)
+		for i, key := range n.keys {
+			field, ok := lookupField(fields, key)
+			if !ok {
+				return &BodyError{Reason: BodyErrorUnknownField, Path: n.values[i].path(), Offset: n.keyOffsets[i], Message: fmt.Sprintf("the field %q is unknown", key), params: []string{key}}
+			}
+			if err := checkJSON(n.values[i], field); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		if err := expectKind(n, '{', "object"); err != nil {
+			return err
+		}
+		for _, value := range n.values {
+			if err := checkJSON(value, t.Elem()); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return expectKind(n, '"', "string") // base64
+		}
+		if err := expectKind(n, '[', "array"); err != nil {
+			return err
+		}
+		for _, value := range n.values {
+			if err := checkJSON(value, t.Elem()); err != nil {
+				return err
+			}
+		}
+	case reflect.String:
+		return expectKind(n, '"', "string")
+	case reflect.Bool:
+		return expectKind(n, 't', "boolean")
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if err := expectKind(n, '0', "integer"); err != nil {
+			return err
+		}
+		if _, err := strconv.ParseInt(string(n.raw), 10, 64); err != nil {
+			if _, err := strconv.ParseUint(string(n.raw), 10, 64); err != nil {
+				return typeError(n, "integer")
+			}
+		}
+	case reflect.Float32, reflect.Float64:
+		return expectKind(n, '0', "number")
+	}
+	return nil
+}
+
+// lookupField returns the type of the field of the key, matched like encoding/json: exactly, or case insensitively
+func lookupField(fields map[string]reflect.Type, key string) (reflect.Type, bool) {
+	if field, ok := fields[key]; ok {
+		return field, true
+	}
+	for name, field := range fields {
+		if strings.EqualFold(name, key) {
+			return field, true
+		}
+	}
+	return nil, false
+}
+
+func expectKind(n *jsonNode, kind byte, expected string) *BodyError {
+	if n.kind != kind {
+		return typeError(n, expected)
+	}
+	return nil
+}
+
+func typeError(n *jsonNode, expected string) *BodyError {
+	got := kindName(n.kind)
+	return &BodyError{Reason: BodyErrorType, Path: n.path(), Expected: expected, Offset: n.offset, Message: fmt.Sprintf("expected %s, got %s", expected, got), params: []string{expected, got}}
+}
+
+func kindName(kind byte) string {
+	switch kind {
+	case '{':
+		return "object"
+	case '[':
+		return "array"
+	case '"':
+		return "string"
+	case 't':
+		return "boolean"
+	case '0':
+		return "number"
+	}
+	return "null"
+}
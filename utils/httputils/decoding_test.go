@@ -0,0 +1,80 @@
+package httputils
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeJSON(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		expected *BodyError
+	}{
+		{name: "valid", body: `{"id": "a1", "count": 3, "tags": ["x"], "nested": {"label": "l"}, "expires_at": null}`},
+		{name: "case insensitive field", body: `{"ID": "a1"}`},
+		{name: "empty body", body: ``, expected: &BodyError{Reason: BodyErrorSyntax, Path: "$", Offset: 0}},
+		{name: "syntax", body: `{"id": "a1",}`, expected: &BodyError{Reason: BodyErrorSyntax, Path: "$", Offset: 12}},
+		{name: "truncated", body: `{"tags": ["x"`, expected: &BodyError{Reason: BodyErrorSyntax, Path: "$.tags", Offset: 13}},
+		{name: "trailing data", body: `{} {}`, expected: &BodyError{Reason: BodyErrorSyntax, Path: "$", Offset: 3}},
+		{name: "duplicate key", body: `{"id": "a1", "id": "a2"}`, expected: &BodyError{Reason: BodyErrorDuplicateKey, Path: "$.id", Offset: 13}},
+		{name: "unknown field", body: `{"nested": {"name": "n"}}`, expected: &BodyError{Reason: BodyErrorUnknownField, Path: "$.nested.name", Offset: 12}},
+		{name: "string expected", body: `{"tags": ["x", 2]}`, expected: &BodyError{Reason: BodyErrorType, Path: "$.tags[1]", Expected: "string", Offset: 15}},
+		{name: "integer expected", body: `{"count": 1.5}`, expected: &BodyError{Reason: BodyErrorType, Path: "$.count", Expected: "integer", Offset: 10}},
+		{name: "object expected", body: `{"labels": []}`, expected: &BodyError{Reason: BodyErrorType, Path: "$.labels", Expected: "object", Offset: 11}},
+		{name: "date expected", body: `{"expires_at": true}`, expected: &BodyError{Reason: BodyErrorType, Path: "$.expires_at", Expected: "string", Offset: 15}},
+		{name: "overflow", body: `{"nested": {"label": "l"}, "count": 99999999999999999999}`, expected: &BodyError{Reason: BodyErrorType, Path: "$.count", Expected: "integer", Offset: 36}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var v testResource
+			err := decodeJSON([]byte(test.body), &v)
+			if test.expected == nil {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.True(t, errors.Is(err, ErrInvalidBody))
+			var bodyErr *BodyError
+			require.True(t, errors.As(err, &bodyErr))
+			bodyErr.Message, bodyErr.params = "", nil
+			assert.Equal(t, test.expected, bodyErr)
+		})
+	}
+}
+
+func TestDecodeJSONDepth(t *testing.T) {
+	// nested up to the limit
+	var v interface{}
+	body := strings.Repeat("[", maxJSONDepth) + strings.Repeat("]", maxJSONDepth)
+	require.NoError(t, decodeJSON([]byte(body), &v))
+
+	// nested deeper, without closing the arrays: the scan stops at the limit
+	body = strings.Repeat("[", 10*1024*1024)
+	err := decodeJSON([]byte(body), &v)
+	var bodyErr *BodyError
+	require.True(t, errors.As(err, &bodyErr))
+	assert.Equal(t, BodyErrorSyntax, bodyErr.Reason)
+	assert.Equal(t, int64(maxJSONDepth), bodyErr.Offset)
+	assert.Equal(t, "$"+strings.Repeat("[0]", maxJSONDepth), bodyErr.Path)
+}
+
+func TestDecodeOtherFormats(t *testing.T) {
+	var v testResource
+	err := decodeYAML([]byte("id: a1\nunknown: x\n"), &v)
+	var bodyErr *BodyError
+	require.True(t, errors.As(err, &bodyErr))
+	assert.Equal(t, BodyErrorUnknownField, bodyErr.Reason)
+	assert.Equal(t, "$.unknown", bodyErr.Path)
+	assert.Nil(t, bodyErr.FieldError().Offset)
+
+	err = decodeXML([]byte("<template><count>many</count></template>"), &v)
+	require.True(t, errors.As(err, &bodyErr))
+	assert.Equal(t, BodyErrorType, bodyErr.Reason)
+	assert.Equal(t, "$.count", bodyErr.Path)
+	assert.Equal(t, "integer", bodyErr.Expected)
+}
@@ -0,0 +1,396 @@
+package httputils
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ugorji/go/codec"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	// xmlRoot and xmlItem name the root element of the XML documents, and the elements of the collections
+	xmlRoot = "response"
+	xmlItem = "item"
+
+	// csvValueColumn is the column of the collections of values which are not objects
+	csvValueColumn = "value"
+)
+
+var (
+	errNotCollection = errors.New("only collections can be written as csv")
+
+	msgpackHandle = &codec.MsgpackHandle{}
+
+	jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+func init() {
+	// the keys are sorted so that a resource always has the same representation, and the same ETag
+	msgpackHandle.Canonical = true
+	msgpackHandle.RawToString = true
+	msgpackHandle.WriteExt = true
+	msgpackHandle.MapType = reflect.TypeOf(map[string]interface{}(nil))
+}
+
+// The formats other than JSON are written from the JSON form of the resources, and read into it, so that all the
+// representations have the same field names and values, given by the json tags of the models.
+
+// toGeneric returns the JSON form of v, made of maps, slices, strings, int64, float64, bools and nils
+func toGeneric(v interface{}) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var generic interface{}
+	if err := decoder.Decode(&generic); err != nil {
+		return nil, err
+	}
+	return normalize(generic), nil
+}
+
+// fromGeneric fills v with the JSON form read from another format, as strictly as a JSON body
+func fromGeneric(generic interface{}, v interface{}) error {
+	data, err := json.Marshal(normalize(generic))
+	if err != nil {
+		return err
+	}
+	err = decodeJSON(data, v)
+	if bodyErr, ok := err.(*BodyError); ok {
+		bodyErr.Offset = -1 // the offset in the JSON form does not locate the error in the body
+	}
+	return err
+}
+
+// normalize converts the json numbers to int64 or float64, and the maps decoded by yaml to maps with string keys
+func normalize(v interface{}) interface{} {
+	switch value := v.(type) {
+	case json.Number:
+		if i, err := value.Int64(); err == nil {
+			return i
+		}
+		f, _ := value.Float64()
+		return f
+	case map[string]interface{}:
+		for key, item := range value {
+			value[key] = normalize(item)
+		}
+		return value
+	case map[interface{}]interface{}:
+		result := make(map[string]interface{}, len(value))
+		for key, item := range value {
+			result[fmt.Sprint(key)] = normalize(item)
+		}
+		return result
+	case []interface{}:
+		for i, item := range value {
+			value[i] = normalize(item)
+		}
+		return value
+	}
+	return v
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func encodeYAML(v interface{}) ([]byte, error) {
+	generic, err := toGeneric(v)
+	if err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(generic)
+}
+
+func decodeYAML(data []byte, v interface{}) error {
+	var generic interface{}
+	if err := yaml.Unmarshal(data, &generic); err != nil {
+		return err
+	}
+	return fromGeneric(generic, v)
+}
+
+func encodeMsgPack(v interface{}) ([]byte, error) {
+	generic, err := toGeneric(v)
+	if err != nil {
+		return nil, err
+	}
+	var data []byte
+	err = codec.NewEncoderBytes(&data, msgpackHandle).Encode(generic)
+	return data, err
+}
+
+func decodeMsgPack(data []byte, v interface{}) error {
+	var generic interface{}
+	if err := codec.NewDecoderBytes(data, msgpackHandle).Decode(&generic); err != nil {
+		return err
+	}
+	return fromGeneric(generic, v)
+}
+
+// encodeXML writes the JSON form of v in a response element: the fields of the objects are elements named
+// by their json name, the values of the collections are item elements, the null values are omitted
+func encodeXML(v interface{}) ([]byte, error) {
+	generic, err := toGeneric(v)
+	if err != nil {
+		return nil, err
+	}
+	var buffer bytes.Buffer
+	buffer.WriteString(xml.Header)
+	encoder := xml.NewEncoder(&buffer)
+	if err := encodeXMLElement(encoder, xmlRoot, generic); err != nil {
+		return nil, err
+	}
+	if err := encoder.Flush(); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+func encodeXMLElement(encoder *xml.Encoder, name string, v interface{}) error {
+	start := xml.StartElement{Name: xml.Name{Local: name}}
+	switch value := v.(type) {
+	case nil:
+		return nil
+	case map[string]interface{}:
+		if err := encoder.EncodeToken(start); err != nil {
+			return err
+		}
+		for _, key := range sortedKeys(value) {
+			if err := encodeXMLElement(encoder, key, value[key]); err != nil {
+				return err
+			}
+		}
+		return encoder.EncodeToken(start.End())
+	case []interface{}:
+		if err := encoder.EncodeToken(start); err != nil {
+			return err
+		}
+		for _, item := range value {
+			if err := encodeXMLElement(encoder, xmlItem, item); err != nil {
+				return err
+			}
+		}
+		return encoder.EncodeToken(start.End())
+	default:
+		return encoder.EncodeElement(fmt.Sprint(value), start)
+	}
+}
+
+// xmlNode is an element of a XML document of any shape
+type xmlNode struct {
+	XMLName xml.Name
+	Content string    `xml:",chardata"`
+	Nodes   []xmlNode `xml:",any"`
+}
+
+// generic returns the JSON form of the element: an object for an element having children, the repeated children
+// being a collection, a string otherwise
+func (n xmlNode) generic() interface{} {
+	if len(n.Nodes) == 0 {
+		return n.Content
+	}
+	result := make(map[string]interface{})
+	for _, node := range n.Nodes {
+		name, value := node.XMLName.Local, node.generic()
+		existing, ok := result[name]
+		switch {
+		case !ok:
+			result[name] = value
+		case isList(existing):
+			result[name] = append(existing.([]interface{}), value)
+		default:
+			result[name] = []interface{}{existing, value}
+		}
+	}
+	return result
+}
+
+func isList(v interface{}) bool {
+	_, ok := v.([]interface{})
+	return ok
+}
+
+// decodeXML reads a document written like encodeXML, whatever the name of its root element. As all the values
+// of a XML document are strings, they are converted to the types of the fields of v.
+func decodeXML(data []byte, v interface{}) error {
+	var root xmlNode
+	if err := xml.Unmarshal(data, &root); err != nil {
+		return err
+	}
+	return fromGeneric(coerce(root.generic(), reflect.TypeOf(v)), v)
+}
+
+// coerce converts the strings read from a XML document to the JSON form of the type t
+func coerce(v interface{}, t reflect.Type) interface{} {
+	for t.Kind() == reflect.Ptr {
+		if v == "" {
+			return nil
+		}
+		t = t.Elem()
+	}
+	if reflect.PtrTo(t).Implements(jsonUnmarshalerType) || reflect.PtrTo(t).Implements(textUnmarshalerType) {
+		return v // eg. time.Time, read from its string form
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		if m, ok := v.(map[string]interface{}); ok {
+			fields := jsonFields(t)
+			for key, value := range m {
+				if field, ok := fields[key]; ok {
+					m[key] = coerce(value, field)
+				}
+			}
+		}
+	case reflect.Map:
+		if m, ok := v.(map[string]interface{}); ok {
+			for key, value := range m {
+				m[key] = coerce(value, t.Elem())
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return v // base64 string
+		}
+		// the values of a collection are the children of its element, whatever their name
+		if m, ok := v.(map[string]interface{}); ok && len(m) == 1 {
+			for _, items := range m {
+				v = items
+			}
+		}
+		if v == "" {
+			return []interface{}{}
+		}
+		list, ok := v.([]interface{})
+		if !ok {
+			list = []interface{}{v}
+		}
+		for i, item := range list {
+			list[i] = coerce(item, t.Elem())
+		}
+		return list
+	case reflect.Bool:
+		if s, ok := v.(string); ok {
+			if b, err := strconv.ParseBool(strings.TrimSpace(s)); err == nil {
+				return b
+			}
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		if s, ok := v.(string); ok {
+			if _, err := strconv.ParseFloat(strings.TrimSpace(s), 64); err == nil {
+				return json.Number(strings.TrimSpace(s))
+			}
+		}
+	}
+	return v
+}
+
+// jsonFields returns the types of the fields of the struct type t by json name, including the embedded fields
+func jsonFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			for embedded, embeddedType := range jsonFields(field.Type) {
+				fields[embedded] = embeddedType
+			}
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fields[name] = field.Type
+	}
+	return fields
+}
+
+// encodeCSV writes a collection with a row per value, and a column per field: the fields of the nested objects
+// are flattened with dotted names, and the nested collections are written as JSON
+func encodeCSV(v interface{}) ([]byte, error) {
+	generic, err := toGeneric(v)
+	if err != nil {
+		return nil, err
+	}
+	list, ok := generic.([]interface{})
+	if !ok {
+		return nil, errNotCollection
+	}
+
+	rows := make([]map[string]string, 0, len(list))
+	columns := make(map[string]interface{})
+	for _, item := range list {
+		row := make(map[string]string)
+		flatten("", item, row)
+		for column := range row {
+			columns[column] = nil
+		}
+		rows = append(rows, row)
+	}
+	header := sortedKeys(columns)
+
+	var buffer bytes.Buffer
+	writer := csv.NewWriter(&buffer)
+	if len(header) > 0 {
+		_ = writer.Write(header)
+	}
+	for _, row := range rows {
+		record := make([]string, 0, len(header))
+		for _, column := range header {
+			record = append(record, row[column])
+		}
+		_ = writer.Write(record)
+	}
+	writer.Flush()
+	return buffer.Bytes(), writer.Error()
+}
+
+func flatten(prefix string, v interface{}, row map[string]string) {
+	if m, ok := v.(map[string]interface{}); ok {
+		for key, value := range m {
+			if prefix != "" {
+				key = prefix + "." + key
+			}
+			flatten(key, value, row)
+		}
+		return
+	}
+
+	if prefix == "" {
+		prefix = csvValueColumn
+	}
+	switch value := v.(type) {
+	case nil:
+		row[prefix] = ""
+	case []interface{}:
+		data, _ := json.Marshal(value)
+		row[prefix] = string(data)
+	default:
+		row[prefix] = fmt.Sprint(value)
+	}
+}
@@ -2,8 +2,13 @@ package httputils
 
 const (
 	HeaderNameAccept          = "accept"
+	HeaderNameAcceptEncoding  = "Accept-Encoding"
+	HeaderNameAcceptLanguage  = "Accept-Language"
 	HeaderNameAuthorization   = "authorization"
 	HeaderNameCacheControl    = "cache-control"
+	HeaderNameContentEncoding = "Content-Encoding"
+	HeaderNameContentLanguage = "Content-Language"
+	HeaderNameContentLength   = "Content-Length"
 	HeaderNameContentType     = "content-type"
 	HeaderNameCorrelationID   = "correlationID"
 	HeaderNameETag            = "ETag"
@@ -11,6 +16,8 @@ const (
 	HeaderNameIfMatch         = "If-Match"
 	HeaderNameLocation        = "location"
 	HeaderNameIfNoneMatch     = "If-None-Match"
+	HeaderNameRetryAfter      = "Retry-After"
+	HeaderNameVary            = "Vary"
 	HeaderNameWWWAuthenticate = "WWW-Authenticate"
 
 	// cors headers
@@ -21,14 +28,22 @@ const (
 	HeaderNameAccessControlAllowHeaders     = "Access-Control-Allow-Headers"
 	HeaderNameAccessControlExposeHeaders    = "access-control-expose-headers"
 
-	HeaderValueApplicationJSONUTF8 = "application/json; charset=UTF-8"
-	HeaderValueApplicationYAML     = "application/x-yaml"
+	HeaderValueApplicationJSONUTF8    = "application/json; charset=UTF-8"
+	HeaderValueApplicationProblemJSON = "application/problem+json"
+	HeaderValueApplicationHALJSON     = "application/hal+json"
+	HeaderValueApplicationJSONAPI     = "application/vnd.api+json"
+	HeaderValueApplicationYAML        = "application/x-yaml"
+	HeaderValueApplicationXMLUTF8     = "application/xml; charset=UTF-8"
+	HeaderValueApplicationMsgPack     = "application/msgpack"
+	HeaderValueTextCSVUTF8            = "text/csv; charset=UTF-8"
 )
 
 var AllowedHeaders = []string{
 	HeaderNameAuthorization,
 	HeaderNameAccept,
+	HeaderNameAcceptLanguage,
 	HeaderNameCacheControl,
+	HeaderNameContentEncoding,
 	HeaderNameContentType,
 	HeaderNameCorrelationID,
 	HeaderNameExpires,
@@ -0,0 +1,228 @@
+package httputils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// The hypermedia representations, HAL and JSON:API, are built from the JSON form of the models registered with
+// RegisterResource, their links being the routes of the resource.
+
+const (
+	halLinks    = "_links"
+	halEmbedded = "_embedded"
+
+	// the link relations of the resources
+	relSelf       = "self"
+	relCollection = "collection"
+	relRevisions  = "revisions"
+
+	// idField is the JSON field of the resources giving their id
+	idField = "id"
+)
+
+var errNotResource = errors.New("only the registered resources can be written as hypermedia")
+
+// Resource gives the routes of a model, linked from its hypermedia representations
+type Resource struct {
+	Type       string // JSON:API type of the resources and HAL relation of the collection, eg. templates
+	Collection string // path of the collection, eg. /templates
+	Item       string // path of a resource, the :id parameter being replaced by its id, eg. /templates/:id
+	Revisions  string // path of the revisions of a resource like Item, empty when the resource has no revisions
+}
+
+var (
+	resourcesMutex sync.RWMutex
+	resources      = make(map[reflect.Type]Resource)
+)
+
+// RegisterResource registers the routes of the model, given by a value of its type, so that it can be written
+// as application/hal+json and application/vnd.api+json, alone or in a collection
+func RegisterResource(value interface{}, resource Resource) {
+	resourcesMutex.Lock()
+	defer resourcesMutex.Unlock()
+	resources[indirectType(reflect.TypeOf(value))] = resource
+}
+
+// lookupResource returns the resource of v, and tells if v is a collection of resources
+func lookupResource(v interface{}) (Resource, bool, bool) {
+	t := indirectType(reflect.TypeOf(v))
+	if t == nil {
+		return Resource{}, false, false
+	}
+	collection := t.Kind() == reflect.Slice || t.Kind() == reflect.Array
+	if collection {
+		t = indirectType(t.Elem())
+	}
+
+	resourcesMutex.RLock()
+	defer resourcesMutex.RUnlock()
+	resource, ok := resources[t]
+	return resource, collection, ok
+}
+
+func indirectType(t reflect.Type) reflect.Type {
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+// links returns the links of a resource by relation, the ones of its collection when it has no id
+func (r Resource) links(item map[string]interface{}) map[string]string {
+	links := map[string]string{relCollection: r.Collection}
+	id, ok := item[idField]
+	if !ok || id == nil {
+		return links
+	}
+	links[relSelf] = expandID(r.Item, id)
+	if r.Revisions != "" {
+		links[relRevisions] = expandID(r.Revisions, id)
+	}
+	return links
+}
+
+func expandID(path string, id interface{}) string {
+	return strings.Replace(path, ":id", url.PathEscape(fmt.Sprint(id)), 1)
+}
+
+// hypermedia returns the resource and the JSON form of v, a collection being a list of objects
+func hypermedia(v interface{}) (Resource, bool, interface{}, error) {
+	resource, collection, ok := lookupResource(v)
+	if !ok {
+		return Resource{}, false, nil, errNotResource
+	}
+	generic, err := toGeneric(v)
+	if err != nil {
+		return Resource{}, false, nil, err
+	}
+	if collection && generic == nil {
+		generic = []interface{}{} // nil slice
+	}
+	return resource, collection, generic, nil
+}
+
+// encodeHAL writes a resource with its _links, and a collection with the resources in its _embedded, under the
+// type of the resource
+func encodeHAL(v interface{}) ([]byte, error) {
+	resource, collection, generic, err := hypermedia(v)
+	if err != nil {
+		return nil, err
+	}
+	if !collection {
+		return json.Marshal(halResource(resource, generic))
+	}
+
+	list, _ := generic.([]interface{})
+	items := make([]interface{}, 0, len(list))
+	for _, item := range list {
+		items = append(items, halResource(resource, item))
+	}
+	return json.Marshal(map[string]interface{}{
+		halLinks:    halLinksOf(map[string]string{relSelf: resource.Collection}),
+		halEmbedded: map[string]interface{}{resource.Type: items},
+		"count":     len(items),
+	})
+}
+
+func halResource(resource Resource, item interface{}) interface{} {
+	m, ok := item.(map[string]interface{})
+	if !ok {
+		return item
+	}
+	m[halLinks] = halLinksOf(resource.links(m))
+	return m
+}
+
+func halLinksOf(links map[string]string) map[string]interface{} {
+	result := make(map[string]interface{}, len(links))
+	for rel, href := range links {
+		result[rel] = map[string]string{"href": href}
+	}
+	return result
+}
+
+// jsonAPIDocument is a JSON:API document of resources
+type jsonAPIDocument struct {
+	Data  interface{}            `json:"data"`
+	Links map[string]string      `json:"links,omitempty"`
+	Meta  map[string]interface{} `json:"meta,omitempty"`
+}
+
+// jsonAPIResource is a JSON:API resource object, its attributes being the fields of its JSON form but its id
+type jsonAPIResource struct {
+	Type       string                 `json:"type"`
+	ID         string                 `json:"id,omitempty"`
+	Attributes map[string]interface{} `json:"attributes"`
+	Links      map[string]string      `json:"links,omitempty"`
+}
+
+// encodeJSONAPI writes a JSON:API document whose data is the resource, or the resources of the collection
+func encodeJSONAPI(v interface{}) ([]byte, error) {
+	resource, collection, generic, err := hypermedia(v)
+	if err != nil {
+		return nil, err
+	}
+	if !collection {
+		data := jsonAPIResourceOf(resource, generic)
+		document := jsonAPIDocument{Data: data}
+		if self, ok := data.Links[relSelf]; ok {
+			document.Links = map[string]string{relSelf: self}
+		}
+		return json.Marshal(document)
+	}
+
+	list, _ := generic.([]interface{})
+	data := make([]jsonAPIResource, 0, len(list))
+	for _, item := range list {
+		data = append(data, jsonAPIResourceOf(resource, item))
+	}
+	return json.Marshal(jsonAPIDocument{
+		Data:  data,
+		Links: map[string]string{relSelf: resource.Collection},
+		Meta:  map[string]interface{}{"count": len(data)},
+	})
+}
+
+func jsonAPIResourceOf(resource Resource, item interface{}) jsonAPIResource {
+	attributes, ok := item.(map[string]interface{})
+	if !ok {
+		attributes = map[string]interface{}{"value": item}
+	}
+	result := jsonAPIResource{Type: resource.Type, Attributes: attributes, Links: resource.links(attributes)}
+	delete(result.Links, relCollection) // the collection is not a link of the JSON:API resource objects
+	if id, ok := attributes[idField]; ok && id != nil {
+		result.ID = fmt.Sprint(id)
+	}
+	delete(attributes, idField)
+	return result
+}
+
+// JSONAPIWriter is the response writer of the requests negotiating JSON:API documents, whose errors are written
+// by JSONError as JSON:API error objects
+type JSONAPIWriter struct {
+	gin.ResponseWriter
+}
+
+// NewJSONAPIWriter wraps the response writer of a request
+func NewJSONAPIWriter(w gin.ResponseWriter) *JSONAPIWriter {
+	return &JSONAPIWriter{ResponseWriter: w}
+}
+
+func (w *JSONAPIWriter) unwrap() gin.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// PrefersJSONAPI tells if JSON:API is the preferred format of the Accept header
+func PrefersJSONAPI(accept string) bool {
+	accepted := acceptedFormats(accept)
+	return strings.TrimSpace(accept) != "" && len(accepted) > 0 &&
+		accepted[0].contentType == HeaderValueApplicationJSONAPI
+}
@@ -0,0 +1,128 @@
+package httputils
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/adeo/turbine-go-api-skeleton/storage/model"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	RegisterResource(testResource{}, Resource{Type: "tests", Collection: "/tests", Item: "/tests/:id", Revisions: "/tests/:id/revisions"})
+}
+
+func decodeBody(t *testing.T, w *httptest.ResponseRecorder) map[string]interface{} {
+	var body map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	return body
+}
+
+func TestRenderHAL(t *testing.T) {
+	resource := newTestResource()
+	accept := map[string]string{HeaderNameAccept: HeaderValueApplicationHALJSON}
+
+	w := renderTest(&resource, accept)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, HeaderValueApplicationHALJSON, w.Header().Get(HeaderNameContentType))
+	assert.True(t, MatchesVersion(w.Header().Get(HeaderNameETag), resource))
+	body := decodeBody(t, w)
+	assert.Equal(t, "a1", body["id"])
+	assert.Equal(t, map[string]interface{}{
+		"self":       map[string]interface{}{"href": "/tests/a1"},
+		"collection": map[string]interface{}{"href": "/tests"},
+		"revisions":  map[string]interface{}{"href": "/tests/a1/revisions"},
+	}, body["_links"])
+
+	w = renderTest([]*testResource{&resource}, accept)
+	assert.Equal(t, http.StatusOK, w.Code)
+	body = decodeBody(t, w)
+	assert.Equal(t, map[string]interface{}{"self": map[string]interface{}{"href": "/tests"}}, body["_links"])
+	assert.Equal(t, float64(1), body["count"])
+	items := body["_embedded"].(map[string]interface{})["tests"].([]interface{})
+	require.Len(t, items, 1)
+	assert.Equal(t, "a1", items[0].(map[string]interface{})["id"])
+
+	w = renderTest([]testResource(nil), accept)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, map[string]interface{}{"tests": []interface{}{}}, decodeBody(t, w)["_embedded"])
+}
+
+func TestRenderJSONAPI(t *testing.T) {
+	resource := newTestResource()
+	accept := map[string]string{HeaderNameAccept: HeaderValueApplicationJSONAPI}
+
+	w := renderTest(resource, accept)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, HeaderValueApplicationJSONAPI, w.Header().Get(HeaderNameContentType))
+	body := decodeBody(t, w)
+	assert.Equal(t, map[string]interface{}{"self": "/tests/a1"}, body["links"])
+	data := body["data"].(map[string]interface{})
+	assert.Equal(t, "tests", data["type"])
+	assert.Equal(t, "a1", data["id"])
+	assert.Equal(t, map[string]interface{}{"self": "/tests/a1", "revisions": "/tests/a1/revisions"}, data["links"])
+	attributes := data["attributes"].(map[string]interface{})
+	assert.NotContains(t, attributes, "id")
+	assert.Equal(t, float64(3), attributes["count"])
+
+	w = renderTest([]testResource{resource, resource}, accept)
+	assert.Equal(t, http.StatusOK, w.Code)
+	body = decodeBody(t, w)
+	assert.Equal(t, map[string]interface{}{"self": "/tests"}, body["links"])
+	assert.Equal(t, map[string]interface{}{"count": float64(2)}, body["meta"])
+	assert.Len(t, body["data"], 2)
+}
+
+func TestRenderHypermediaNotResource(t *testing.T) {
+	w := renderTest(testNested{Label: "a"}, map[string]string{HeaderNameAccept: HeaderValueApplicationJSONAPI})
+	assert.Equal(t, http.StatusNotAcceptable, w.Code)
+
+	w = renderTest(testNested{Label: "a"}, map[string]string{HeaderNameAccept: "application/hal+json, application/json;q=0.5"})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, HeaderValueApplicationJSONUTF8, w.Header().Get(HeaderNameContentType))
+}
+
+func TestPrefersJSONAPI(t *testing.T) {
+	assert.True(t, PrefersJSONAPI("application/vnd.api+json"))
+	assert.True(t, PrefersJSONAPI("application/json;q=0.5, application/vnd.api+json"))
+	assert.False(t, PrefersJSONAPI(""))
+	assert.False(t, PrefersJSONAPI("*/*"))
+	assert.False(t, PrefersJSONAPI("application/json, application/vnd.api+json"))
+}
+
+func TestJSONErrorJSONAPI(t *testing.T) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	apiErr := model.ErrDataValidation
+	apiErr.Details = []model.FieldError{
+		{Field: "$.tags[1]", Constraint: "type", Description: "expected string, got number"},
+		{Field: "nested.label", Constraint: "required", Description: "required"},
+	}
+	JSONError(NewJSONAPIWriter(c.Writer), apiErr)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, HeaderValueApplicationJSONAPI, w.Header().Get(HeaderNameContentType))
+	var body model.JSONAPIErrors
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	require.Len(t, body.Errors, 2)
+	assert.Equal(t, "400", body.Errors[0].Status)
+	assert.Equal(t, "data_validation", body.Errors[0].Code)
+	assert.Equal(t, "Bad Request", body.Errors[0].Title)
+	assert.Equal(t, "expected string, got number", body.Errors[0].Detail)
+	assert.Equal(t, &model.JSONAPIErrorSource{Pointer: "/tags/1"}, body.Errors[0].Source)
+	assert.Equal(t, "type", body.Errors[0].Meta.Constraint)
+	assert.Equal(t, "/nested/label", body.Errors[1].Source.Pointer)
+
+	w = httptest.NewRecorder()
+	c, _ = gin.CreateTestContext(w)
+	JSONError(NewJSONAPIWriter(c.Writer), model.ErrNotAcceptable)
+	body = model.JSONAPIErrors{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	require.Len(t, body.Errors, 1)
+	assert.Equal(t, model.ErrNotAcceptable.Description, body.Errors[0].Detail)
+	assert.Nil(t, body.Errors[0].Source)
+}
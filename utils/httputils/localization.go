@@ -0,0 +1,52 @@
+package httputils
+
+import (
+	"github.com/adeo/turbine-go-api-skeleton/storage/model"
+	"github.com/adeo/turbine-go-api-skeleton/utils/i18n"
+	"github.com/gin-gonic/gin"
+)
+
+// LocalizedWriter is the response writer of the requests whose errors are written by JSONError in the language
+// negotiated with their Accept-Language header
+type LocalizedWriter struct {
+	gin.ResponseWriter
+	catalog  *i18n.Catalog
+	language string
+}
+
+// NewLocalizedWriter wraps the response writer of a request, its errors being translated in the language
+func NewLocalizedWriter(w gin.ResponseWriter, catalog *i18n.Catalog, language string) *LocalizedWriter {
+	return &LocalizedWriter{ResponseWriter: w, catalog: catalog, language: language}
+}
+
+func (w *LocalizedWriter) unwrap() gin.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// localize returns the error with its descriptions in the language of the writer, and the language of the
+// descriptions: the messages without translation are left in the default language. The constraints of the field
+// errors are left as is, for the clients to check them.
+func (w *LocalizedWriter) localize(e model.APIError) (model.APIError, string) {
+	translated := false
+	if description, ok := w.catalog.Error(w.language, e.Type); ok {
+		e.Description, translated = description, true
+	}
+	if len(e.Details) > 0 {
+		details := make([]model.FieldError, len(e.Details))
+		for i, detail := range e.Details {
+			if description, ok := w.catalog.Constraint(w.language, detail.Constraint); ok {
+				args := make([]interface{}, len(detail.Params))
+				for j, param := range detail.Params {
+					args[j] = param
+				}
+				detail.Description, translated = i18n.Sprintf(description, args...), true
+			}
+			details[i] = detail
+		}
+		e.Details = details
+	}
+	if !translated {
+		return e, w.catalog.DefaultLanguage()
+	}
+	return e, w.language
+}
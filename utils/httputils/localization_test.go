@@ -0,0 +1,41 @@
+package httputils
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/adeo/turbine-go-api-skeleton/storage/model"
+	"github.com/adeo/turbine-go-api-skeleton/utils/i18n"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONErrorLocalized(t *testing.T) {
+	catalog := i18n.NewCatalog("en", map[string]i18n.Messages{
+		"fr": {
+			Errors:      map[string]string{"bad_format": "Le corps de la requête est invalide"},
+			Constraints: map[string]string{"type": "%v attendu, %v reçu"},
+		},
+	})
+	bodyErr := decodeJSON([]byte(`{"tags": ["x", 1]}`), &testResource{}).(*BodyError)
+	apiErr := model.ErrBadRequestFormat
+	apiErr.Details = []model.FieldError{bodyErr.FieldError(), {Field: "$.id", Constraint: "required", Description: "required"}}
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	// the wrappers of the writer apply whatever their order
+	JSONError(NewProblemWriter(NewLocalizedWriter(c.Writer, catalog, "fr"), "urn:problem-type:", "/tests"), apiErr)
+
+	assert.Equal(t, "fr", w.Header().Get(HeaderNameContentLanguage))
+	assert.Equal(t, HeaderValueApplicationProblemJSON, w.Header().Get(HeaderNameContentType))
+	var problem model.Problem
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+	assert.Equal(t, "Le corps de la requête est invalide", problem.Detail)
+	require.Len(t, problem.Errors, 2)
+	assert.Equal(t, "string attendu, number reçu", problem.Errors[0].Description)
+	assert.Equal(t, "type", problem.Errors[0].Constraint)
+	assert.Equal(t, "required", problem.Errors[1].Description)
+	assert.Equal(t, "expected string, got number", apiErr.Details[0].Description)
+}
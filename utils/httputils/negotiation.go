@@ -0,0 +1,309 @@
+package httputils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/adeo/turbine-go-api-skeleton/storage/model"
+	"github.com/adeo/turbine-go-api-skeleton/utils"
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	// ErrUnsupportedMediaType is returned by Bind when the Content-Type of the request can not be read
+	ErrUnsupportedMediaType = errors.New("unsupported media type")
+	// ErrInvalidBody is returned by Bind when the body of the request does not match its Content-Type
+	ErrInvalidBody = errors.New("invalid body")
+	// ErrBodyTooLarge is returned when reading a request body larger than allowed, eg. once decompressed
+	ErrBodyTooLarge = errors.New("request body too large")
+)
+
+// format is a representation of the resources, read and written from their JSON form
+type format struct {
+	contentType string
+	mediaTypes  []string
+	encode      func(v interface{}) ([]byte, error)
+	// decode is nil for the formats which can only be written
+	decode func(data []byte, v interface{}) error
+}
+
+// formats are the supported representations, by order of preference: JSON is the default one
+var formats = []format{
+	{
+		contentType: HeaderValueApplicationJSONUTF8,
+		mediaTypes:  []string{"application/json"},
+		encode:      json.Marshal,
+		decode:      decodeJSON,
+	},
+	{
+		contentType: HeaderValueApplicationYAML,
+		mediaTypes:  []string{"application/x-yaml", "application/yaml", "text/yaml"},
+		encode:      encodeYAML,
+		decode:      decodeYAML,
+	},
+	{
+		contentType: HeaderValueApplicationXMLUTF8,
+		mediaTypes:  []string{"application/xml", "text/xml"},
+		encode:      encodeXML,
+		decode:      decodeXML,
+	},
+	{
+		contentType: HeaderValueApplicationMsgPack,
+		mediaTypes:  []string{"application/msgpack", "application/x-msgpack"},
+		encode:      encodeMsgPack,
+		decode:      decodeMsgPack,
+	},
+	{
+		contentType: HeaderValueTextCSVUTF8,
+		mediaTypes:  []string{"text/csv"},
+		encode:      encodeCSV,
+	},
+	{
+		contentType: HeaderValueApplicationHALJSON,
+		mediaTypes:  []string{"application/hal+json"},
+		encode:      encodeHAL,
+	},
+	{
+		contentType: HeaderValueApplicationJSONAPI,
+		mediaTypes:  []string{"application/vnd.api+json"},
+		encode:      encodeJSONAPI,
+	},
+}
+
+// matches tells if the format is one of the media range, eg. application/json, application/* or */*
+func (f format) matches(pattern string) bool {
+	if pattern == "*/*" {
+		return true
+	}
+	for _, mediaType := range f.mediaTypes {
+		if mediaType == pattern || strings.HasSuffix(pattern, "/*") && strings.HasPrefix(mediaType, pattern[:len(pattern)-1]) {
+			return true
+		}
+	}
+	return false
+}
+
+// mediaRange is a media range of an Accept header, with its quality
+type mediaRange struct {
+	value string
+	q     float64
+}
+
+// parseAccept returns the media ranges of the Accept header by decreasing quality, ignoring the refused ones
+func parseAccept(accept string) []mediaRange {
+	var ranges []mediaRange
+	for _, part := range strings.Split(accept, ",") {
+		value, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if s, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(s, 64); err != nil {
+				continue
+			}
+		}
+		if q > 0 {
+			ranges = append(ranges, mediaRange{value: value, q: q})
+		}
+	}
+	sort.SliceStable(ranges, func(i, j int) bool { return ranges[i].q > ranges[j].q })
+	return ranges
+}
+
+// acceptedFormats returns the formats accepted by the Accept header, by order of preference of the client then
+// of the server. A request without Accept header accepts all the formats.
+func acceptedFormats(accept string) []format {
+	if strings.TrimSpace(accept) == "" {
+		return formats
+	}
+
+	var accepted []format
+	added := make(map[string]bool)
+	for _, r := range parseAccept(accept) {
+		for _, f := range formats {
+			if !added[f.contentType] && f.matches(r.value) {
+				accepted = append(accepted, f)
+				added[f.contentType] = true
+			}
+		}
+	}
+	return accepted
+}
+
+// mediaType returns the media type of the Content-Type of the format, without its parameters
+func (f format) mediaType() string {
+	mediaType, _, _ := mime.ParseMediaType(f.contentType)
+	return mediaType
+}
+
+// ResponseMediaTypes returns the media types of the responses writing a registered resource, or a collection of
+// them, by order of preference, to document them: CSV only writes the collections
+func ResponseMediaTypes(collection bool) []string {
+	mediaTypes := make([]string, 0, len(formats))
+	for _, f := range formats {
+		if collection || f.contentType != HeaderValueTextCSVUTF8 {
+			mediaTypes = append(mediaTypes, f.mediaType())
+		}
+	}
+	return mediaTypes
+}
+
+// RequestMediaTypes returns the main media type of each format of the request bodies, to document them
+func RequestMediaTypes() []string {
+	mediaTypes := make([]string, 0, len(formats))
+	for _, f := range formats {
+		if f.decode != nil {
+			mediaTypes = append(mediaTypes, f.mediaType())
+		}
+	}
+	return mediaTypes
+}
+
+// IsAcceptable tells if a response can be written in a format accepted by the Accept header
+func IsAcceptable(accept string) bool {
+	return len(acceptedFormats(accept)) > 0
+}
+
+// readableFormat returns the format of a request body, JSON when no Content-Type is given
+func readableFormat(contentType string) (format, bool) {
+	if strings.TrimSpace(contentType) == "" {
+		return formats[0], true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return format{}, false
+	}
+	for _, f := range formats {
+		if f.decode != nil && f.matches(mediaType) && !strings.HasSuffix(mediaType, "/*") {
+			return f, true
+		}
+	}
+	return format{}, false
+}
+
+// IsReadable tells if a request body of the Content-Type can be read
+func IsReadable(contentType string) bool {
+	_, ok := readableFormat(contentType)
+	return ok
+}
+
+// Bind reads the body of the request in v, in the format given by its Content-Type. The body must only have the
+// fields of v, once, with values of their types.
+// It returns ErrUnsupportedMediaType, ErrInvalidBody or ErrBodyTooLarge when the body can not be read, any other
+// error being an error of the connection. The invalid bodies are usually reported by a BodyError, giving the
+// location of the error.
+func Bind(c *gin.Context, v interface{}) error {
+	f, ok := readableFormat(c.GetHeader(HeaderNameContentType))
+	if !ok {
+		return ErrUnsupportedMediaType
+	}
+	body, err := c.GetRawData()
+	if err != nil {
+		return err
+	}
+	if err := f.decode(body, v); err != nil {
+		if _, ok := err.(*BodyError); ok {
+			return err
+		}
+		return fmt.Errorf("%w: %v", ErrInvalidBody, err)
+	}
+	return nil
+}
+
+// BindError writes the error returned by Bind, and tells if the error has been written: the errors of the
+// connection are left to the caller
+func BindError(w http.ResponseWriter, err error) bool {
+	apiErr, ok := BindAPIError(err)
+	if ok {
+		JSONError(w, apiErr)
+	}
+	return ok
+}
+
+// BindAPIError returns the error to send for an error returned by Bind, false for the errors of the connection
+func BindAPIError(err error) (model.APIError, bool) {
+	switch {
+	case errors.Is(err, ErrUnsupportedMediaType):
+		return model.ErrUnsupportedMediaType, true
+	case errors.Is(err, ErrInvalidBody):
+		apiErr := model.ErrBadRequestFormat
+		var bodyErr *BodyError
+		if errors.As(err, &bodyErr) {
+			apiErr.Details = []model.FieldError{bodyErr.FieldError()}
+		}
+		return apiErr, true
+	case errors.Is(err, ErrBodyTooLarge):
+		return model.ErrRequestEntityTooLarge, true
+	}
+	return model.APIError{}, false
+}
+
+// RenderOK writes the data with a 200 status, or a 304 status if the If-None-Match header is the ETag of the
+// negotiated representation
+func RenderOK(c *gin.Context, data interface{}) {
+	render(c, http.StatusOK, data, true)
+}
+
+// Render writes the data in the format negotiated with the Accept header. Each representation has its own ETag,
+// the one of the JSON representation being the ETag computed by utils.GenerateEtag.
+// A 406 error is written when no accepted format can represent the data, eg. CSV for a single resource, or HAL
+// for a model not registered with RegisterResource.
+func Render(c *gin.Context, status int, data interface{}) {
+	render(c, status, data, false)
+}
+
+func render(c *gin.Context, status int, data interface{}, conditional bool) {
+	w := c.Writer
+	w.Header().Add(HeaderNameVary, http.CanonicalHeaderKey(HeaderNameAccept))
+	if data == nil {
+		JSON(w, status, nil)
+		return
+	}
+
+	for _, f := range acceptedFormats(c.GetHeader(HeaderNameAccept)) {
+		body, err := f.encode(data)
+		if err == errNotCollection || err == errNotResource {
+			continue
+		}
+		if err != nil {
+			utils.GetLoggerFromCtx(c).WithError(err).WithField("content_type", f.contentType).Error("error while encoding response")
+			JSONError(w, model.ErrInternalServer)
+			return
+		}
+
+		etag := utils.GenerateEtagFromBytes(body)
+		w.Header().Set(HeaderNameContentType, f.contentType)
+		w.Header().Set(HeaderNameAccessControlExposeHeaders, HeaderNameETag)
+		w.Header().Set(HeaderNameETag, etag)
+		if conditional && c.GetHeader(HeaderNameIfNoneMatch) == etag {
+			w.WriteHeader(http.StatusNotModified)
+			w.WriteHeaderNow()
+			return
+		}
+		w.WriteHeader(status)
+		w.Write(body)
+		return
+	}
+	JSONError(w, model.ErrNotAcceptable)
+}
+
+// MatchesVersion tells if the ETag is the one of any representation of the resource
+func MatchesVersion(etag string, resource interface{}) bool {
+	if etag == "" {
+		return false
+	}
+	for _, f := range formats {
+		body, err := f.encode(resource)
+		if err == nil && utils.GenerateEtagFromBytes(body) == etag {
+			return true
+		}
+	}
+	return false
+}
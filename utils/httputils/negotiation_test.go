@@ -0,0 +1,189 @@
+package httputils
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adeo/turbine-go-api-skeleton/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testNested struct {
+	Label string `json:"label"`
+}
+
+type testResource struct {
+	ID        string            `json:"id"`
+	Count     int               `json:"count"`
+	Ratio     float64           `json:"ratio"`
+	Enabled   bool              `json:"enabled"`
+	Tags      []string          `json:"tags"`
+	Nested    testNested        `json:"nested"`
+	Labels    map[string]string `json:"labels"`
+	ExpiresAt *time.Time        `json:"expires_at"`
+	CreatedAt time.Time         `json:"created_at"`
+}
+
+func newTestResource() testResource {
+	expiresAt := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	return testResource{
+		ID:        "a1",
+		Count:     3,
+		Ratio:     0.5,
+		Enabled:   true,
+		Tags:      []string{"x", "y"},
+		Nested:    testNested{Label: "nested"},
+		Labels:    map[string]string{"env": "dev"},
+		ExpiresAt: &expiresAt,
+		CreatedAt: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+}
+
+func TestFormatsRoundTrip(t *testing.T) {
+	expected := newTestResource()
+	for _, f := range formats {
+		if f.decode == nil {
+			continue
+		}
+		t.Run(f.contentType, func(t *testing.T) {
+			data, err := f.encode(expected)
+			require.NoError(t, err)
+
+			var actual testResource
+			require.NoError(t, f.decode(data, &actual))
+			assert.Equal(t, expected, actual)
+		})
+	}
+}
+
+func TestXMLSingleItemCollection(t *testing.T) {
+	var actual testResource
+	require.NoError(t, decodeXML([]byte(`<template><tags><item>x</item></tags><expires_at></expires_at></template>`), &actual))
+	assert.Equal(t, []string{"x"}, actual.Tags)
+	assert.Nil(t, actual.ExpiresAt)
+}
+
+func TestEncodeCSV(t *testing.T) {
+	data, err := encodeCSV([]testNested{{Label: "a"}, {Label: "b,c"}})
+	require.NoError(t, err)
+	assert.Equal(t, "label\na\n\"b,c\"\n", string(data))
+
+	data, err = encodeCSV([]testResource{newTestResource()})
+	require.NoError(t, err)
+	lines := strings.Split(string(data), "\n")
+	assert.Equal(t, "count,created_at,enabled,expires_at,id,labels.env,nested.label,ratio,tags", lines[0])
+	assert.Equal(t, `3,2020-01-02T03:04:05Z,true,2030-01-02T03:04:05Z,a1,dev,nested,0.5,"[""x"",""y""]"`, lines[1])
+
+	_, err = encodeCSV(newTestResource())
+	assert.Equal(t, errNotCollection, err)
+}
+
+func TestAcceptedFormats(t *testing.T) {
+	contentTypes := func(accept string) []string {
+		var result []string
+		for _, f := range acceptedFormats(accept) {
+			result = append(result, f.contentType)
+		}
+		return result
+	}
+
+	assert.Equal(t, HeaderValueApplicationJSONUTF8, contentTypes("")[0])
+	assert.Equal(t, HeaderValueApplicationJSONUTF8, contentTypes("*/*")[0])
+	assert.Equal(t, []string{HeaderValueApplicationYAML}, contentTypes("text/html, application/yaml"))
+	assert.Equal(t, []string{HeaderValueApplicationXMLUTF8, HeaderValueApplicationJSONUTF8}, contentTypes("application/json;q=0.5, text/xml"))
+	assert.Equal(t, []string{HeaderValueTextCSVUTF8}, contentTypes("text/csv"))
+	assert.Equal(t, []string{HeaderValueApplicationYAML, HeaderValueApplicationXMLUTF8, HeaderValueTextCSVUTF8}, contentTypes("text/*"))
+	assert.Empty(t, contentTypes("text/html, application/json;q=0"))
+}
+
+func TestMediaTypes(t *testing.T) {
+	assert.Equal(t, []string{"application/json", "application/x-yaml", "application/xml", "application/msgpack", "text/csv", "application/hal+json", "application/vnd.api+json"}, ResponseMediaTypes(true))
+	assert.NotContains(t, ResponseMediaTypes(false), "text/csv")
+	assert.Equal(t, []string{"application/json", "application/x-yaml", "application/xml", "application/msgpack"}, RequestMediaTypes())
+}
+
+func TestIsReadable(t *testing.T) {
+	assert.True(t, IsReadable(""))
+	assert.True(t, IsReadable("application/json; charset=UTF-8"))
+	assert.True(t, IsReadable("application/msgpack"))
+	assert.False(t, IsReadable("text/csv"))
+	assert.False(t, IsReadable("application/*"))
+	assert.False(t, IsReadable("not a media type"))
+}
+
+// renderTest renders the data for a request with the given headers
+func renderTest(data interface{}, headers map[string]string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/templates", nil)
+	for name, value := range headers {
+		c.Request.Header.Set(name, value)
+	}
+	RenderOK(c, data)
+	return w
+}
+
+func TestRender(t *testing.T) {
+	resource := newTestResource()
+
+	w := renderTest(resource, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, HeaderValueApplicationJSONUTF8, w.Header().Get(HeaderNameContentType))
+	jsonEtag, err := utils.GenerateEtag(resource)
+	require.NoError(t, err)
+	assert.Equal(t, jsonEtag, w.Header().Get(HeaderNameETag))
+	assert.Equal(t, "Accept", w.Header().Get(HeaderNameVary))
+
+	w = renderTest(resource, map[string]string{HeaderNameAccept: "application/x-yaml"})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, HeaderValueApplicationYAML, w.Header().Get(HeaderNameContentType))
+	yamlEtag := w.Header().Get(HeaderNameETag)
+	assert.NotEqual(t, jsonEtag, yamlEtag)
+	assert.True(t, MatchesVersion(yamlEtag, resource))
+	assert.True(t, MatchesVersion(jsonEtag, resource))
+	assert.False(t, MatchesVersion("\"0-0\"", resource))
+
+	w = renderTest(resource, map[string]string{HeaderNameAccept: "application/x-yaml", HeaderNameIfNoneMatch: yamlEtag})
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	w = renderTest(resource, map[string]string{HeaderNameAccept: "application/json", HeaderNameIfNoneMatch: yamlEtag})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = renderTest(resource, map[string]string{HeaderNameAccept: "text/csv"})
+	assert.Equal(t, http.StatusNotAcceptable, w.Code)
+	w = renderTest([]testResource{resource}, map[string]string{HeaderNameAccept: "text/csv"})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, HeaderValueTextCSVUTF8, w.Header().Get(HeaderNameContentType))
+	w = renderTest(resource, map[string]string{HeaderNameAccept: "text/csv, application/xml;q=0.5"})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, HeaderValueApplicationXMLUTF8, w.Header().Get(HeaderNameContentType))
+}
+
+func TestBind(t *testing.T) {
+	bind := func(contentType, body string) (testNested, error) {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodPost, "/templates", strings.NewReader(body))
+		if contentType != "" {
+			c.Request.Header.Set(HeaderNameContentType, contentType)
+		}
+		var v testNested
+		err := Bind(c, &v)
+		return v, err
+	}
+
+	v, err := bind("", `{"label":"json"}`)
+	assert.NoError(t, err)
+	assert.Equal(t, "json", v.Label)
+	v, err = bind("application/xml", `<template><label>xml</label></template>`)
+	assert.NoError(t, err)
+	assert.Equal(t, "xml", v.Label)
+	_, err = bind("application/json", `{`)
+	assert.True(t, errors.Is(err, ErrInvalidBody))
+	_, err = bind("text/csv", "label\ncsv")
+	assert.Equal(t, ErrUnsupportedMediaType, err)
+}
@@ -0,0 +1,43 @@
+package httputils
+
+import (
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ProblemWriter is the response writer of the requests whose errors are written by JSONError as RFC 7807
+// problems, instead of the APIError format
+type ProblemWriter struct {
+	gin.ResponseWriter
+	typeBaseURI string
+	instance    string
+}
+
+// NewProblemWriter wraps the response writer of a request, typeBaseURI prefixing the error types to build the
+// problem types, and instance identifying the request
+func NewProblemWriter(w gin.ResponseWriter, typeBaseURI, instance string) *ProblemWriter {
+	return &ProblemWriter{ResponseWriter: w, typeBaseURI: typeBaseURI, instance: instance}
+}
+
+func (w *ProblemWriter) unwrap() gin.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// ProblemInstance returns the problem instance of a request, its path and its correlation id
+func ProblemInstance(path, correlationID string) string {
+	if correlationID == "" {
+		return path
+	}
+	return fmt.Sprintf("%s#%s", path, correlationID)
+}
+
+// AcceptsProblem tells if the Accept header asks for the errors as application/problem+json
+func AcceptsProblem(accept string) bool {
+	for _, r := range parseAccept(accept) {
+		if r.value == HeaderValueApplicationProblemJSON {
+			return true
+		}
+	}
+	return false
+}
@@ -19,15 +19,18 @@ func JSONOK(c *gin.Context, data interface{}) {
 
 func JSON(w http.ResponseWriter, status int, data interface{}) {
 	w.Header().Set(HeaderNameContentType, HeaderValueApplicationJSONUTF8)
-	w.WriteHeader(status)
-	if data != nil {
-		etag, err := utils.GenerateEtag(data)
-		if err == nil {
-			w.Header().Set(HeaderNameAccessControlExposeHeaders, HeaderNameETag)
-			w.Header().Set(HeaderNameETag, etag)
-		}
-		json.NewEncoder(w).Encode(data)
+	if data == nil {
+		w.WriteHeader(status)
+		return
+	}
+	// the headers must be set before writing the status
+	etag, err := utils.GenerateEtag(data)
+	if err == nil {
+		w.Header().Set(HeaderNameAccessControlExposeHeaders, HeaderNameETag)
+		w.Header().Set(HeaderNameETag, etag)
 	}
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
 }
 
 func JSONError(w http.ResponseWriter, e model.APIError) {
@@ -38,7 +41,45 @@ func JSONError(w http.ResponseWriter, e model.APIError) {
 			}
 		}
 	}
-	JSON(w, e.HTTPCode, e)
+
+	// the format and the language of the error are given by the writers wrapping the response writer
+	var problemWriter *ProblemWriter
+	jsonAPI := false
+	for current := http.ResponseWriter(w); current != nil; {
+		switch writer := current.(type) {
+		case *ProblemWriter:
+			problemWriter = writer
+		case *JSONAPIWriter:
+			jsonAPI = true
+		case *LocalizedWriter:
+			var language string
+			e, language = writer.localize(e)
+			w.Header().Set(HeaderNameContentLanguage, language)
+		}
+		wrapper, ok := current.(writerWrapper)
+		if !ok {
+			break
+		}
+		current = wrapper.unwrap()
+	}
+
+	switch {
+	case jsonAPI:
+		w.Header().Set(HeaderNameContentType, HeaderValueApplicationJSONAPI)
+		w.WriteHeader(e.HTTPCode)
+		json.NewEncoder(w).Encode(e.JSONAPIErrors())
+	case problemWriter != nil:
+		w.Header().Set(HeaderNameContentType, HeaderValueApplicationProblemJSON)
+		w.WriteHeader(e.HTTPCode)
+		json.NewEncoder(w).Encode(e.Problem(problemWriter.typeBaseURI, problemWriter.instance))
+	default:
+		JSON(w, e.HTTPCode, e)
+	}
+}
+
+// writerWrapper is implemented by the response writers of this package wrapping the response writer of a request
+type writerWrapper interface {
+	unwrap() gin.ResponseWriter
 }
 
 func JSONErrorWithMessage(w http.ResponseWriter, e model.APIError, message string) {
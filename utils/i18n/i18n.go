@@ -0,0 +1,171 @@
+// Package i18n translates the messages of the errors sent to the clients in their language
+package i18n
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Messages are the translations of a language, read from a file of the translations directory, eg. fr.yaml:
+//
+//	errors:
+//	  not_found: "La ressource n'existe pas"
+//	constraints:
+//	  required: "Ce champ est obligatoire"
+//	  regexp: "Ce champ doit respecter le format : %v"
+type Messages struct {
+	Errors      map[string]string `yaml:"errors"`      // descriptions of the APIErrors, by error type
+	Constraints map[string]string `yaml:"constraints"` // descriptions of the FieldErrors, by validator tag or body error reason
+}
+
+// Catalog holds the translations of the messages by language
+type Catalog struct {
+	defaultLanguage string
+	languages       map[string]Messages // by lower case language tag
+	tags            map[string]string   // language tags as named by their file, by lower case language tag
+}
+
+// NewCatalog returns a catalog of the given translations by language tag. The messages of the code are the ones
+// of the default language, which may have its own translations too.
+func NewCatalog(defaultLanguage string, translations map[string]Messages) *Catalog {
+	c := &Catalog{
+		defaultLanguage: defaultLanguage,
+		languages:       make(map[string]Messages, len(translations)),
+		tags:            map[string]string{strings.ToLower(defaultLanguage): defaultLanguage},
+	}
+	for tag, messages := range translations {
+		c.languages[strings.ToLower(tag)] = messages
+		c.tags[strings.ToLower(tag)] = tag
+	}
+	return c
+}
+
+// Load reads the translations of the directory, a YAML or JSON file per language named by its tag, eg. fr.yaml
+// or pt-BR.json
+func Load(dir, defaultLanguage string) (*Catalog, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	translations := make(map[string]Messages)
+	for _, file := range files {
+		ext := filepath.Ext(file.Name())
+		if file.IsDir() || ext != ".yaml" && ext != ".yml" && ext != ".json" {
+			continue
+		}
+		data, err := ioutil.ReadFile(filepath.Join(dir, file.Name()))
+		if err != nil {
+			return nil, err
+		}
+		var messages Messages
+		if err := yaml.UnmarshalStrict(data, &messages); err != nil {
+			return nil, fmt.Errorf("translations %s: %v", file.Name(), err)
+		}
+		translations[strings.TrimSuffix(file.Name(), ext)] = messages
+	}
+	if len(translations) == 0 {
+		return nil, fmt.Errorf("no translations in %s", dir)
+	}
+	return NewCatalog(defaultLanguage, translations), nil
+}
+
+// DefaultLanguage returns the language of the messages of the code
+func (c *Catalog) DefaultLanguage() string {
+	return c.defaultLanguage
+}
+
+// languageRange is a language range of an Accept-Language header, with its quality
+type languageRange struct {
+	tag string
+	q   float64
+}
+
+// Negotiate returns the language of the catalog preferred by the Accept-Language header, the default language
+// when none is accepted. A range matches the languages it prefixes, eg. fr matches fr-CA, and falls back to its
+// prefixes, eg. fr-CA falls back to fr.
+func (c *Catalog) Negotiate(acceptLanguage string) string {
+	var ranges []languageRange
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		fields := strings.Split(part, ";")
+		tag := strings.ToLower(strings.TrimSpace(fields[0]))
+		if tag == "" {
+			continue
+		}
+		q := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if value, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = value
+				}
+			}
+		}
+		if q > 0 {
+			ranges = append(ranges, languageRange{tag: tag, q: q})
+		}
+	}
+	sort.SliceStable(ranges, func(i, j int) bool { return ranges[i].q > ranges[j].q })
+
+	for _, r := range ranges {
+		if r.tag == "*" {
+			return c.defaultLanguage
+		}
+		if tag, ok := c.tags[r.tag]; ok {
+			return tag
+		}
+		if tag, ok := c.prefixed(r.tag); ok {
+			return tag
+		}
+		for i := strings.LastIndex(r.tag, "-"); i > 0; i = strings.LastIndex(r.tag, "-") {
+			r.tag = r.tag[:i]
+			if tag, ok := c.tags[r.tag]; ok {
+				return tag
+			}
+		}
+	}
+	return c.defaultLanguage
+}
+
+// prefixed returns the first language, by name, prefixed by the range
+func (c *Catalog) prefixed(prefix string) (string, bool) {
+	var matches []string
+	for lower, tag := range c.tags {
+		if strings.HasPrefix(lower, prefix+"-") {
+			matches = append(matches, tag)
+		}
+	}
+	if len(matches) == 0 {
+		return "", false
+	}
+	sort.Strings(matches)
+	return matches[0], true
+}
+
+// Error returns the description of the error type in the language, false if it is not translated
+func (c *Catalog) Error(language, errorType string) (string, bool) {
+	message, ok := c.languages[strings.ToLower(language)].Errors[errorType]
+	return message, ok
+}
+
+// Constraint returns the description of the field errors of the constraint in the language, false if it is not
+// translated. Its %v are the parameters of the constraint, see Sprintf.
+func (c *Catalog) Constraint(language, constraint string) (string, bool) {
+	message, ok := c.languages[strings.ToLower(language)].Constraints[constraint]
+	return message, ok
+}
+
+// Sprintf formats the message like fmt.Sprintf with %v verbs, ignoring the arguments the message does not use
+func Sprintf(message string, args ...interface{}) string {
+	n := strings.Count(message, "%v")
+	if n > len(args) {
+		n = len(args)
+	}
+	return fmt.Sprintf(message, args[:n]...)
+}
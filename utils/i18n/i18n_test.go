@@ -0,0 +1,78 @@
+package i18n
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNegotiate(t *testing.T) {
+	catalog := NewCatalog("en", map[string]Messages{"fr": {}, "pt-BR": {}, "de-CH": {}})
+
+	tests := []struct {
+		acceptLanguage string
+		expected       string
+	}{
+		{acceptLanguage: "", expected: "en"},
+		{acceptLanguage: "fr", expected: "fr"},
+		{acceptLanguage: "FR-ca", expected: "fr"},
+		{acceptLanguage: "pt-br", expected: "pt-BR"},
+		{acceptLanguage: "pt", expected: "pt-BR"},
+		{acceptLanguage: "de", expected: "de-CH"},
+		{acceptLanguage: "it, fr;q=0.5", expected: "fr"},
+		{acceptLanguage: "fr;q=0.5, pt-BR", expected: "pt-BR"},
+		{acceptLanguage: "en-US, fr;q=0.8", expected: "en"},
+		{acceptLanguage: "it, *;q=0.1", expected: "en"},
+		{acceptLanguage: "fr;q=0, it", expected: "en"},
+	}
+	for _, test := range tests {
+		t.Run(test.acceptLanguage, func(t *testing.T) {
+			assert.Equal(t, test.expected, catalog.Negotiate(test.acceptLanguage))
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "translations")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "fr.yaml"), []byte("errors:\n  not_found: \"Introuvable\"\nconstraints:\n  regexp: \"Format attendu : %v\"\n"), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "pt-BR.json"), []byte(`{"errors": {"not_found": "Não encontrado"}}`), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "README.md"), []byte("# translations"), 0644))
+
+	catalog, err := Load(dir, "en")
+	require.NoError(t, err)
+	message, ok := catalog.Error("fr", "not_found")
+	assert.True(t, ok)
+	assert.Equal(t, "Introuvable", message)
+	message, ok = catalog.Error("pt-br", "not_found")
+	assert.True(t, ok)
+	assert.Equal(t, "Não encontrado", message)
+	message, ok = catalog.Constraint("fr", "regexp")
+	assert.True(t, ok)
+	assert.Equal(t, "Format attendu : ^a$", Sprintf(message, "^a$"))
+	_, ok = catalog.Constraint("fr", "required")
+	assert.False(t, ok)
+	_, ok = catalog.Error("en", "not_found")
+	assert.False(t, ok)
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "de.yaml"), []byte("unknown: {}\n"), 0644))
+	_, err = Load(dir, "en")
+	assert.Error(t, err)
+
+	empty, err := ioutil.TempDir("", "translations")
+	require.NoError(t, err)
+	defer os.RemoveAll(empty)
+	_, err = Load(empty, "en")
+	assert.Error(t, err)
+}
+
+func TestSprintf(t *testing.T) {
+	assert.Equal(t, "required", Sprintf("required", "param"))
+	assert.Equal(t, "expected string, got number", Sprintf("expected %v, got %v", "string", "number"))
+}
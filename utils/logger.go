@@ -14,6 +14,7 @@ const (
 
 	ContextKeyLogger         = "logger"
 	ContextKeyAuthIntrospect = "ContextKeyAuthIntrospect"
+	ContextKeyCorrelationID  = "correlationID"
 )
 
 var (